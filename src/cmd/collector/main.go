@@ -11,6 +11,7 @@ import (
 	"flag"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"log"
 	"net/http"
 	"os"
@@ -20,10 +21,9 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
-	_ "github.com/mattn/go-sqlite3"
 )
 
-func runAPIServer(ctxWithCancel context.Context, db *sql.DB, config *utils.Config, wg *sync.WaitGroup) {
+func runAPIServer(ctxWithCancel context.Context, db *sql.DB, config *utils.Config, fetcher *gatherer.Fetcher, scheduler *gatherer.Scheduler, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	port := config.Server.Port
@@ -31,7 +31,7 @@ func runAPIServer(ctxWithCancel context.Context, db *sql.DB, config *utils.Confi
 		port = "8080" // Default port if not specified in config
 	}
 	log.Println("Starting web server on :", port)
-	apiInstance := api.NewAPI(db)
+	apiInstance := api.NewAPI(db, fetcher, scheduler)
 
 	router := mux.NewRouter()
 	apiRouter := router.PathPrefix("/api").Subrouter()
@@ -59,49 +59,78 @@ func runAPIServer(ctxWithCancel context.Context, db *sql.DB, config *utils.Confi
 	}
 }
 
-func runGathererLoop(ctx context.Context, db *sql.DB, config *utils.Config, wg *sync.WaitGroup) {
+func runGatherer(ctx context.Context, scheduler *gatherer.Scheduler, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	gatherer.FetchListFeedChannels(ctx, db)
+	scheduler.Run(ctx)
+}
 
-	ticker := time.NewTicker(config.FeedsUpdateInterval)
-	defer ticker.Stop()
+// The migrations whose data changes are made in Go after the migrations are run
+const (
+	sanitizeMigration      = 11 // Added description_raw, the HTML stored before it is sanitized
+	canonicalLinkMigration = 14 // Added canonical_link, the links stored before it are canonicalized
+)
 
-	for {
-		select {
-		case <-ticker.C:
-			gatherer.FetchListFeedChannels(ctx, db)
-		case <-ctx.Done():
-			return
-		default:
-			time.Sleep(10 * time.Second)
-		}
-	}
-}
+// migrationsDir holds the schema migrations and the search migrations
+const migrationsDir = "db"
 
-func runMigrations(db *sql.DB) error {
+// runMigrations brings the database up to date at startup, along with the full-text index of the items
+func runMigrations(db *sql.DB, dir string) error {
 	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
 	if err != nil {
 		return err
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
-		"file://db/migrations",
+		"file://"+dir+"/migrations",
 		"sqlite3", driver)
 	if err != nil {
 		return err
 	}
 
+	// The version before the upgrade, a new database has none and no items to backfill
+	version, _, err := m.Version()
+	upgrade := err == nil
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return err
+	}
+
 	err = m.Up()
 	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return err
 	}
 
+	if upgrade && version < sanitizeMigration {
+		updated, err := gatherer.BackfillSanitizedItems(context.Background(), db)
+		if err != nil {
+			return err
+		}
+		internal.InfoLogger.Printf("Sanitized the HTML of %d item(s) stored before the sanitizing", updated)
+	}
+
+	if upgrade && version < canonicalLinkMigration {
+		updated, err := gatherer.BackfillCanonicalLinks(context.Background(), db)
+		if err != nil {
+			return err
+		}
+		internal.InfoLogger.Printf("Canonicalized the links of %d item(s) stored before the deduplication", updated)
+	}
+
+	if err := utils.MigrateSearch(db, "file://"+dir+"/search_migrations"); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func main() {
 	configPath := flag.String("config", "config.yaml", "path to config file")
+	backfillCanonicalLinks := flag.Bool("backfill-canonical-links", false,
+		"canonicalize the links of the items stored before migration 14 and exit, needed once after the upgrade "+
+			"if the migrations were run with the migrate CLI, the collector does it when it runs them at startup")
+	backfillSanitizedItems := flag.Bool("backfill-sanitized-items", false,
+		"sanitize the HTML of the items stored before migration 11 and exit, needed once after the upgrade "+
+			"if the migrations were run with the migrate CLI, the collector does it when it runs them at startup")
 	flag.Parse()
 
 	config, err := utils.ReadConfig(*configPath)
@@ -127,7 +156,7 @@ func main() {
 		defer closeErrorLogFiles()
 	}
 
-	db, err := sql.Open("sqlite3", config.Database.Path)
+	db, err := utils.OpenDatabase(config.Database.Path, config.Database.BusyTimeout)
 	if err != nil {
 		internal.ErrorLogger.Fatalf("Error opening database: %v", err)
 	}
@@ -138,15 +167,43 @@ func main() {
 		}
 	}(db)
 
+	if *backfillCanonicalLinks {
+		updated, err := gatherer.BackfillCanonicalLinks(context.Background(), db)
+		if err != nil {
+			internal.ErrorLogger.Fatalf("Error canonicalizing item links: %v", err)
+		}
+		log.Printf("Canonicalized the links of %d item(s)", updated)
+		return
+	}
+	if *backfillSanitizedItems {
+		updated, err := gatherer.BackfillSanitizedItems(context.Background(), db)
+		if err != nil {
+			internal.ErrorLogger.Fatalf("Error sanitizing item HTML: %v", err)
+		}
+		log.Printf("Sanitized the HTML of %d item(s)", updated)
+		return
+	}
+
+	if err := runMigrations(db, migrationsDir); err != nil {
+		internal.ErrorLogger.Fatalf("Error migrating database: %v", err)
+	}
+
+	fetcher, err := gatherer.NewFetcher(config)
+	if err != nil {
+		internal.ErrorLogger.Fatalf("Error creating HTTP client: %v", err)
+	}
+
 	var wg sync.WaitGroup // нужна ли мне эта WaitGroup?
 	ctx := context.Background()
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 
+	scheduler := gatherer.NewScheduler(db, config, fetcher)
+
 	wg.Add(1)
-	go runAPIServer(ctxWithCancel, db, config, &wg)
+	go runAPIServer(ctxWithCancel, db, config, fetcher, scheduler, &wg)
 
 	wg.Add(1)
-	go runGathererLoop(ctxWithCancel, db, config, &wg)
+	go runGatherer(ctxWithCancel, scheduler, &wg)
 
 	// Handle graceful shutdown on Ctrl+C
 	sigCh := make(chan os.Signal, 1)
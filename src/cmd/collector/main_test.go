@@ -0,0 +1,128 @@
+package main
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/api"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+func TestMain(m *testing.M) {
+	if _, err := internal.InitLogging("", internal.InfoLogLevel); err != nil {
+		panic(err)
+	}
+	if _, err := internal.InitLogging("", internal.ErrorLogLevel); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestRunMigrationsEnablesSearch(t *testing.T) {
+	db, err := utils.OpenDatabase(t.TempDir()+"/feeds.db", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := runMigrations(db, "../../db"); err != nil {
+		t.Fatal(err)
+	}
+	// Running them again on the migrated database changes nothing
+	if err := runMigrations(db, "../../db"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	queries := models.New(db)
+	channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+		Title: "Startup channel",
+		Link:  "http://startup.example.com/rss",
+		Host:  "startup.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+		Guid:  null.StringFrom("startup"),
+		Title: "Collector starts up",
+		Link:  "http://startup.example.com/1",
+		Text:  null.StringFrom("The migrations run before the server starts"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channel.ID, ItemID: item.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	api.NewAPI(db, nil, nil).RegisterRoutes(router)
+	req, err := http.NewRequest("GET", "/search?q=migrations", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if !utils.SearchEnabled {
+		// Without FTS5 the items are still stored, and the search reports it's unavailable
+		if rr.Code != http.StatusNotImplemented {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotImplemented)
+		}
+		return
+	}
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var results []api.SearchResult
+	if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ID != item.ID {
+		t.Errorf("Expected the item found by its text, got %+v", results)
+	}
+}
+
+func TestRunMigrationsBackfillsCanonicalLinks(t *testing.T) {
+	db, err := utils.OpenDatabase(t.TempDir()+"/feeds.db", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../db/migrations", "sqlite3", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A database from before the deduplication
+	if err := m.Migrate(canonicalLinkMigration - 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO feed_item (title, link) VALUES ('Upgraded', 'HTTP://Example.com/story/?utm_source=feed')"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runMigrations(db, "../../db"); err != nil {
+		t.Fatal(err)
+	}
+	var canonicalLink string
+	if err := db.QueryRow("SELECT canonical_link FROM feed_item WHERE title = 'Upgraded'").Scan(&canonicalLink); err != nil {
+		t.Fatal(err)
+	}
+	if canonicalLink != "http://example.com/story" {
+		t.Errorf("Expected the link canonicalized at startup, got %q", canonicalLink)
+	}
+}
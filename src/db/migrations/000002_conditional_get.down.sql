@@ -0,0 +1,4 @@
+ALTER TABLE feed_channel_log DROP COLUMN status_code;
+
+ALTER TABLE feed_channel DROP COLUMN last_modified;
+ALTER TABLE feed_channel DROP COLUMN etag;
@@ -0,0 +1,4 @@
+ALTER TABLE feed_channel ADD COLUMN etag TEXT;
+ALTER TABLE feed_channel ADD COLUMN last_modified TEXT;
+
+ALTER TABLE feed_channel_log ADD COLUMN status_code INTEGER;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS feed_channel_log_channel_id_idx;
+
+ALTER TABLE feed_channel_log DROP COLUMN items_unchanged;
+ALTER TABLE feed_channel_log DROP COLUMN items_updated;
+ALTER TABLE feed_channel_log DROP COLUMN items_new;
+ALTER TABLE feed_channel_log DROP COLUMN error_message;
+ALTER TABLE feed_channel_log DROP COLUMN error_class;
+ALTER TABLE feed_channel_log DROP COLUMN bytes_received;
+ALTER TABLE feed_channel_log DROP COLUMN started;
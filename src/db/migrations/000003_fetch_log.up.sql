@@ -0,0 +1,9 @@
+ALTER TABLE feed_channel_log ADD COLUMN started DATETIME;
+ALTER TABLE feed_channel_log ADD COLUMN bytes_received INTEGER NOT NULL DEFAULT (0);
+ALTER TABLE feed_channel_log ADD COLUMN error_class TEXT;
+ALTER TABLE feed_channel_log ADD COLUMN error_message TEXT;
+ALTER TABLE feed_channel_log ADD COLUMN items_new INTEGER NOT NULL DEFAULT (0);
+ALTER TABLE feed_channel_log ADD COLUMN items_updated INTEGER NOT NULL DEFAULT (0);
+ALTER TABLE feed_channel_log ADD COLUMN items_unchanged INTEGER NOT NULL DEFAULT (0);
+
+CREATE INDEX IF NOT EXISTS feed_channel_log_channel_id_idx ON feed_channel_log (channel_id, id);
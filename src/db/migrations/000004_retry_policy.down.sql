@@ -0,0 +1,3 @@
+ALTER TABLE feed_channel DROP COLUMN disabled_reason;
+ALTER TABLE feed_channel DROP COLUMN next_fetch_at;
+ALTER TABLE feed_channel DROP COLUMN failure_count;
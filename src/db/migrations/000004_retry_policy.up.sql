@@ -0,0 +1,3 @@
+ALTER TABLE feed_channel ADD COLUMN failure_count INTEGER NOT NULL DEFAULT (0);
+ALTER TABLE feed_channel ADD COLUMN next_fetch_at DATETIME;
+ALTER TABLE feed_channel ADD COLUMN disabled_reason TEXT;
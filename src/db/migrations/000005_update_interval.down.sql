@@ -0,0 +1,3 @@
+ALTER TABLE feed_channel DROP COLUMN learned_interval;
+ALTER TABLE feed_channel DROP COLUMN adaptive_interval;
+ALTER TABLE feed_channel DROP COLUMN update_interval;
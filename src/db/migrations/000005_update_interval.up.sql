@@ -0,0 +1,3 @@
+ALTER TABLE feed_channel ADD COLUMN update_interval INTEGER;
+ALTER TABLE feed_channel ADD COLUMN adaptive_interval INTEGER NOT NULL DEFAULT (0);
+ALTER TABLE feed_channel ADD COLUMN learned_interval INTEGER;
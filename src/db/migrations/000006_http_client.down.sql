@@ -0,0 +1,2 @@
+ALTER TABLE feed_channel DROP COLUMN tls_insecure;
+ALTER TABLE feed_channel DROP COLUMN proxy;
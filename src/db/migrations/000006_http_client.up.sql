@@ -0,0 +1,2 @@
+ALTER TABLE feed_channel ADD COLUMN proxy TEXT;
+ALTER TABLE feed_channel ADD COLUMN tls_insecure INTEGER NOT NULL DEFAULT (0);
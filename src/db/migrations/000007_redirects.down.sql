@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS feed_channel_link;
+
+ALTER TABLE feed_channel_log DROP COLUMN redirects;
+ALTER TABLE feed_channel_log DROP COLUMN effective_url;
+ALTER TABLE feed_channel_log DROP COLUMN url;
+
+ALTER TABLE feed_channel DROP COLUMN gone;
+ALTER TABLE feed_channel DROP COLUMN moved_count;
+ALTER TABLE feed_channel DROP COLUMN moved_to;
+ALTER TABLE feed_channel DROP COLUMN original_link;
@@ -0,0 +1,19 @@
+ALTER TABLE feed_channel ADD COLUMN original_link TEXT;
+ALTER TABLE feed_channel ADD COLUMN moved_to TEXT;
+ALTER TABLE feed_channel ADD COLUMN moved_count INTEGER NOT NULL DEFAULT (0);
+ALTER TABLE feed_channel ADD COLUMN gone INTEGER NOT NULL DEFAULT (0);
+UPDATE feed_channel SET original_link = link;
+
+ALTER TABLE feed_channel_log ADD COLUMN url TEXT;
+ALTER TABLE feed_channel_log ADD COLUMN effective_url TEXT;
+ALTER TABLE feed_channel_log ADD COLUMN redirects TEXT;
+
+CREATE TABLE IF NOT EXISTS feed_channel_link (
+    id INTEGER PRIMARY KEY,
+    channel_id INTEGER NOT NULL,
+    old_link TEXT NOT NULL,
+    new_link TEXT NOT NULL,
+    redirects TEXT,
+    changed DATETIME NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (channel_id) REFERENCES feed_channel(id) ON DELETE CASCADE
+);
@@ -0,0 +1,6 @@
+ALTER TABLE feed_channel DROP COLUMN metadata_locked;
+ALTER TABLE feed_channel DROP COLUMN last_build_date;
+ALTER TABLE feed_channel DROP COLUMN generator;
+ALTER TABLE feed_channel DROP COLUMN image_url;
+ALTER TABLE feed_channel DROP COLUMN language;
+ALTER TABLE feed_channel DROP COLUMN site_link;
@@ -0,0 +1,6 @@
+ALTER TABLE feed_channel ADD COLUMN site_link TEXT;
+ALTER TABLE feed_channel ADD COLUMN language TEXT;
+ALTER TABLE feed_channel ADD COLUMN image_url TEXT;
+ALTER TABLE feed_channel ADD COLUMN generator TEXT;
+ALTER TABLE feed_channel ADD COLUMN last_build_date DATETIME;
+ALTER TABLE feed_channel ADD COLUMN metadata_locked INTEGER NOT NULL DEFAULT (0);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS item_enclosure (
+    id INTEGER PRIMARY KEY,
+    item_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    mime_type TEXT,
+    medium TEXT,
+    length INTEGER,
+    duration INTEGER,
+    thumbnail TEXT,
+    FOREIGN KEY (item_id) REFERENCES feed_item(id) ON DELETE CASCADE,
+    UNIQUE (item_id, url)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE feed_item DROP COLUMN source_updated;
+ALTER TABLE feed_item DROP COLUMN content;
@@ -0,0 +1,2 @@
+ALTER TABLE feed_item ADD COLUMN content TEXT;
+ALTER TABLE feed_item ADD COLUMN source_updated DATETIME;
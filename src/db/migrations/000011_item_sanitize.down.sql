@@ -0,0 +1,3 @@
+ALTER TABLE feed_item DROP COLUMN text;
+ALTER TABLE feed_item DROP COLUMN content_raw;
+ALTER TABLE feed_item DROP COLUMN description_raw;
@@ -0,0 +1,5 @@
+-- The HTML stored before is kept as published, gatherer.BackfillSanitizedItems sanitizes it after the migrations
+-- (collector -backfill-sanitized-items when they are run with the migrate CLI).
+ALTER TABLE feed_item ADD COLUMN description_raw TEXT;
+ALTER TABLE feed_item ADD COLUMN content_raw TEXT;
+ALTER TABLE feed_item ADD COLUMN text TEXT;
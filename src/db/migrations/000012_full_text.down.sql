@@ -0,0 +1,6 @@
+ALTER TABLE feed_item DROP COLUMN extracted;
+ALTER TABLE feed_item DROP COLUMN extraction_error;
+ALTER TABLE feed_item DROP COLUMN extraction_status;
+ALTER TABLE feed_item DROP COLUMN extracted_content;
+ALTER TABLE feed_channel DROP COLUMN content_selector;
+ALTER TABLE feed_channel DROP COLUMN full_text;
@@ -0,0 +1,6 @@
+ALTER TABLE feed_channel ADD COLUMN full_text INTEGER NOT NULL DEFAULT (0);
+ALTER TABLE feed_channel ADD COLUMN content_selector TEXT;
+ALTER TABLE feed_item ADD COLUMN extracted_content TEXT;
+ALTER TABLE feed_item ADD COLUMN extraction_status TEXT;
+ALTER TABLE feed_item ADD COLUMN extraction_error TEXT;
+ALTER TABLE feed_item ADD COLUMN extracted DATETIME;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS feed_item_revision_item_id;
+DROP TABLE IF EXISTS feed_item_revision;
+ALTER TABLE feed_item DROP COLUMN edited;
@@ -0,0 +1,17 @@
+ALTER TABLE feed_item ADD COLUMN edited INTEGER NOT NULL DEFAULT (0);
+
+CREATE TABLE IF NOT EXISTS feed_item_revision (
+    id INTEGER PRIMARY KEY,
+    item_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    link TEXT NOT NULL,
+    content TEXT,
+    text TEXT,
+    source_updated DATETIME,
+    valid_from DATETIME NOT NULL,
+    replaced DATETIME NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (item_id) REFERENCES feed_item(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS feed_item_revision_item_id ON feed_item_revision (item_id);
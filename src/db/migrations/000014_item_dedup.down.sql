@@ -0,0 +1,43 @@
+ALTER TABLE feed_channel_log DROP COLUMN merges;
+ALTER TABLE feed_channel_log DROP COLUMN items_merged;
+
+-- Fails if several items share a link, they have to be removed first
+CREATE TABLE feed_item_old (
+    id INTEGER PRIMARY KEY,
+    guid TEXT,
+    guid_is_permalink BOOLEAN DEFAULT FALSE,
+    title TEXT NOT NULL,
+    description TEXT,
+    link TEXT NOT NULL UNIQUE,
+    author TEXT,
+    published DATETIME,
+    read INTEGER DEFAULT (0),
+    deleted INTEGER DEFAULT (0),
+    created DATETIME DEFAULT (datetime('now')),
+    updated DATETIME,
+    content TEXT,
+    source_updated DATETIME,
+    description_raw TEXT,
+    content_raw TEXT,
+    text TEXT,
+    extracted_content TEXT,
+    extraction_status TEXT,
+    extraction_error TEXT,
+    extracted DATETIME,
+    edited INTEGER NOT NULL DEFAULT (0)
+);
+
+INSERT INTO feed_item_old (
+    id, guid, guid_is_permalink, title, description, link, author, published, read, deleted, created, updated,
+    content, source_updated, description_raw, content_raw, text, extracted_content, extraction_status,
+    extraction_error, extracted, edited
+)
+SELECT id, guid, guid_is_permalink, title, description, link, author, published, read, deleted, created, updated,
+       content, source_updated, description_raw, content_raw, text, extracted_content, extraction_status,
+       extraction_error, extracted, edited
+FROM feed_item;
+
+DROP INDEX IF EXISTS feed_item_canonical_link;
+DROP INDEX IF EXISTS feed_item_guid;
+DROP TABLE feed_item;
+ALTER TABLE feed_item_old RENAME TO feed_item;
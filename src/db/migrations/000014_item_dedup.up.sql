@@ -0,0 +1,50 @@
+-- feed_item.link is no longer unique: distinct items may share a link and duplicates are found by canonical_link.
+-- SQLite can't drop a constraint, so the table is rebuilt. Foreign keys are not enforced on our connections.
+CREATE TABLE feed_item_new (
+    id INTEGER PRIMARY KEY,
+    guid TEXT,
+    guid_is_permalink BOOLEAN DEFAULT FALSE,
+    title TEXT NOT NULL,
+    description TEXT,
+    link TEXT NOT NULL,
+    author TEXT,
+    published DATETIME,
+    read INTEGER DEFAULT (0),
+    deleted INTEGER DEFAULT (0),
+    created DATETIME DEFAULT (datetime('now')),
+    updated DATETIME,
+    content TEXT,
+    source_updated DATETIME,
+    description_raw TEXT,
+    content_raw TEXT,
+    text TEXT,
+    extracted_content TEXT,
+    extraction_status TEXT,
+    extraction_error TEXT,
+    extracted DATETIME,
+    edited INTEGER NOT NULL DEFAULT (0),
+    canonical_link TEXT,
+    origin_channel_id INTEGER
+);
+
+-- The links are copied into canonical_link as they are, gatherer.BackfillCanonicalLinks canonicalizes them
+-- after the migrations (collector -backfill-canonical-links when they are run with the migrate CLI).
+INSERT INTO feed_item_new (
+    id, guid, guid_is_permalink, title, description, link, author, published, read, deleted, created, updated,
+    content, source_updated, description_raw, content_raw, text, extracted_content, extraction_status,
+    extraction_error, extracted, edited, canonical_link, origin_channel_id
+)
+SELECT id, guid, guid_is_permalink, title, description, link, author, published, read, deleted, created, updated,
+       content, source_updated, description_raw, content_raw, text, extracted_content, extraction_status,
+       extraction_error, extracted, edited, link,
+       (SELECT MIN(fci.channel_id) FROM feed_channel_item AS fci WHERE fci.item_id = feed_item.id)
+FROM feed_item;
+
+DROP TABLE feed_item;
+ALTER TABLE feed_item_new RENAME TO feed_item;
+
+CREATE INDEX IF NOT EXISTS feed_item_guid ON feed_item (guid);
+CREATE INDEX IF NOT EXISTS feed_item_canonical_link ON feed_item (canonical_link);
+
+ALTER TABLE feed_channel_log ADD COLUMN items_merged INTEGER NOT NULL DEFAULT (0);
+ALTER TABLE feed_channel_log ADD COLUMN merges TEXT;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS feed_item_created;
+DROP INDEX IF EXISTS feed_item_cluster_id;
+ALTER TABLE feed_item DROP COLUMN cluster_id;
+ALTER TABLE feed_item DROP COLUMN simhash;
+DROP TABLE IF EXISTS story_cluster;
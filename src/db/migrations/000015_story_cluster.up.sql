@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS story_cluster (
+    id INTEGER PRIMARY KEY,
+    title TEXT NOT NULL,
+    item_count INTEGER NOT NULL DEFAULT (0),
+    created DATETIME NOT NULL DEFAULT (datetime('now')),
+    updated DATETIME NOT NULL DEFAULT (datetime('now'))
+);
+
+ALTER TABLE feed_item ADD COLUMN simhash INTEGER;
+ALTER TABLE feed_item ADD COLUMN cluster_id INTEGER REFERENCES story_cluster(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS feed_item_cluster_id ON feed_item (cluster_id);
+CREATE INDEX IF NOT EXISTS feed_item_created ON feed_item (created);
@@ -0,0 +1,2 @@
+ALTER TABLE feed_channel_log DROP COLUMN failures;
+ALTER TABLE feed_channel_log DROP COLUMN items_failed;
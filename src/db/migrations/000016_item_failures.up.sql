@@ -0,0 +1,2 @@
+ALTER TABLE feed_channel_log ADD COLUMN items_failed INTEGER NOT NULL DEFAULT (0);
+ALTER TABLE feed_channel_log ADD COLUMN failures TEXT;
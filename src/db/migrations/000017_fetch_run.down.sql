@@ -0,0 +1,4 @@
+DROP INDEX feed_channel_log_run_id;
+ALTER TABLE feed_channel_log DROP COLUMN duration_ms;
+ALTER TABLE feed_channel_log DROP COLUMN run_id;
+DROP TABLE fetch_run;
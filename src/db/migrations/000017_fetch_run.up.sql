@@ -0,0 +1,15 @@
+CREATE TABLE fetch_run (
+    id INTEGER PRIMARY KEY,
+    started DATETIME NOT NULL,
+    finished DATETIME, -- NULL while the run is in progress
+    succeeded INTEGER NOT NULL DEFAULT (0),
+    failed INTEGER NOT NULL DEFAULT (0),
+    skipped INTEGER NOT NULL DEFAULT (0), -- Channels deleted before their fetch started
+    fetch_time_ms INTEGER NOT NULL DEFAULT (0), -- Total time of the fetches, they overlap with parallel workers
+    slowest_channel_id INTEGER,
+    slowest_fetch_ms INTEGER NOT NULL DEFAULT (0)
+);
+
+ALTER TABLE feed_channel_log ADD COLUMN run_id INTEGER REFERENCES fetch_run(id) ON DELETE SET NULL;
+ALTER TABLE feed_channel_log ADD COLUMN duration_ms INTEGER;
+CREATE INDEX feed_channel_log_run_id ON feed_channel_log(run_id);
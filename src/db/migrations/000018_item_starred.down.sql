@@ -0,0 +1 @@
+ALTER TABLE feed_item DROP COLUMN starred;
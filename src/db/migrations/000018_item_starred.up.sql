@@ -0,0 +1 @@
+ALTER TABLE feed_item ADD COLUMN starred INTEGER NOT NULL DEFAULT (0);
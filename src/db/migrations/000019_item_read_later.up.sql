@@ -0,0 +1 @@
+ALTER TABLE feed_item ADD COLUMN read_later INTEGER NOT NULL DEFAULT (0);
@@ -0,0 +1,5 @@
+DROP INDEX feed_item_tombstone_channel_id;
+DROP TABLE feed_item_tombstone;
+DROP INDEX feed_item_deleted_at;
+ALTER TABLE feed_channel DROP COLUMN deleted_at;
+ALTER TABLE feed_item DROP COLUMN deleted_at;
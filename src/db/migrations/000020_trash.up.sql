@@ -0,0 +1,18 @@
+ALTER TABLE feed_item ADD COLUMN deleted_at DATETIME;
+ALTER TABLE feed_channel ADD COLUMN deleted_at DATETIME;
+
+-- Items deleted before the trash existed are purged after the retention period from now on
+UPDATE feed_item SET deleted_at = datetime('now') WHERE deleted = 1;
+
+CREATE INDEX feed_item_deleted_at ON feed_item(deleted_at);
+
+CREATE TABLE feed_item_tombstone (
+    id INTEGER PRIMARY KEY,
+    channel_id INTEGER NOT NULL,
+    guid TEXT,
+    canonical_link TEXT,
+    purged DATETIME NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (channel_id) REFERENCES feed_channel(id) ON DELETE CASCADE
+);
+
+CREATE INDEX feed_item_tombstone_channel_id ON feed_item_tombstone(channel_id);
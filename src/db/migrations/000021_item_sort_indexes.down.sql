@@ -0,0 +1,5 @@
+DROP INDEX feed_channel_item_item_id;
+DROP INDEX feed_item_title;
+DROP INDEX feed_item_created;
+CREATE INDEX feed_item_created ON feed_item (created);
+DROP INDEX feed_item_published;
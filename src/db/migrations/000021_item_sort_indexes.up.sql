@@ -0,0 +1,9 @@
+-- The item pages compare the sort keys as stored, so the publication dates are stored in UTC like the creation dates
+UPDATE feed_item SET published = datetime(published) WHERE published IS NOT NULL AND datetime(published) IS NOT NULL;
+
+-- Indexes in the order of each sort of the item pages, the id breaks the ties
+CREATE INDEX feed_item_published ON feed_item (CAST(COALESCE(published, created) AS TEXT), id);
+DROP INDEX feed_item_created;
+CREATE INDEX feed_item_created ON feed_item (created, id);
+CREATE INDEX feed_item_title ON feed_item (title, id);
+CREATE INDEX feed_channel_item_item_id ON feed_channel_item (item_id);
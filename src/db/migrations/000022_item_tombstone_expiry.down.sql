@@ -0,0 +1 @@
+DROP INDEX feed_item_tombstone_canonical_link;
@@ -0,0 +1,2 @@
+-- Tombstones are matched by canonical link across the channels, and expire after trash.tombstone_retention
+CREATE INDEX feed_item_tombstone_canonical_link ON feed_item_tombstone (canonical_link);
@@ -0,0 +1,2 @@
+DROP TRIGGER feed_item_published_update;
+DROP TRIGGER feed_item_published_insert;
@@ -0,0 +1,14 @@
+-- The publication dates are written with their offset, the item pages compare them as stored,
+-- so the triggers store them in UTC like the creation dates, whichever query writes them
+CREATE TRIGGER feed_item_published_insert AFTER INSERT ON feed_item
+WHEN new.published IS NOT datetime(new.published) AND datetime(new.published) IS NOT NULL BEGIN
+    UPDATE feed_item SET published = datetime(new.published) WHERE id = new.id;
+END;
+
+CREATE TRIGGER feed_item_published_update AFTER UPDATE OF published ON feed_item
+WHEN new.published IS NOT datetime(new.published) AND datetime(new.published) IS NOT NULL BEGIN
+    UPDATE feed_item SET published = datetime(new.published) WHERE id = new.id;
+END;
+
+-- The items stored since the publication dates were last normalized
+UPDATE feed_item SET published = datetime(published) WHERE published IS NOT datetime(published) AND datetime(published) IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP TRIGGER item_search_update;
+DROP TRIGGER item_search_delete;
+DROP TRIGGER item_search_insert;
+DROP TABLE item_search;
@@ -0,0 +1,28 @@
+-- The index reads the indexed text from feed_item, the triggers keep it in sync.
+-- text holds the plain text of either the content or the description, so both are indexed as well,
+-- together with the article extracted from the item page.
+CREATE VIRTUAL TABLE item_search USING fts5(
+    title, text, author, description, content, extracted_content,
+    content = 'feed_item', content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3'
+);
+
+CREATE TRIGGER item_search_insert AFTER INSERT ON feed_item BEGIN
+    INSERT INTO item_search (rowid, title, text, author, description, content, extracted_content)
+    VALUES (new.id, new.title, new.text, new.author, new.description, new.content, new.extracted_content);
+END;
+
+CREATE TRIGGER item_search_delete AFTER DELETE ON feed_item BEGIN
+    INSERT INTO item_search (item_search, rowid, title, text, author, description, content, extracted_content)
+    VALUES ('delete', old.id, old.title, old.text, old.author, old.description, old.content, old.extracted_content);
+END;
+
+CREATE TRIGGER item_search_update AFTER UPDATE OF title, text, author, description, content, extracted_content ON feed_item BEGIN
+    INSERT INTO item_search (item_search, rowid, title, text, author, description, content, extracted_content)
+    VALUES ('delete', old.id, old.title, old.text, old.author, old.description, old.content, old.extracted_content);
+    INSERT INTO item_search (rowid, title, text, author, description, content, extracted_content)
+    VALUES (new.id, new.title, new.text, new.author, new.description, new.content, new.extracted_content);
+END;
+
+-- Indexes the items stored before the search
+INSERT INTO item_search (item_search) VALUES ('rebuild');
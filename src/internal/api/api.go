@@ -1,7 +1,9 @@
 package api
 
 import (
+	"FeedsCollector/internal/gatherer"
 	"FeedsCollector/internal/models"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"net/http"
@@ -10,25 +12,54 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// API struct holds the database connection and router
+// API struct holds the database connection, the HTTP client for requests to publishers
+// and the scheduler running on-demand fetches, which is nil if the gatherer is not running
 type API struct {
-	DB *sql.DB
+	DB        *sql.DB
+	Fetcher   *gatherer.Fetcher
+	Scheduler *gatherer.Scheduler
 }
 
-func NewAPI(db *sql.DB) *API {
-	return &API{DB: db}
+func NewAPI(db *sql.DB, fetcher *gatherer.Fetcher, scheduler *gatherer.Scheduler) *API {
+	return &API{DB: db, Fetcher: fetcher, Scheduler: scheduler}
 }
 
 func (api *API) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/channels", api.ListChannels).Methods("GET")
 	router.HandleFunc("/channels", api.AddChannel).Methods("POST")
+	router.HandleFunc("/channels/discover", api.DiscoverChannels).Methods("POST")
 	router.HandleFunc("/channels/{id}", api.UpdateChannel).Methods("PUT")
 	router.HandleFunc("/channels/{id}", api.PatchChannel).Methods("PATCH")
 	router.HandleFunc("/channels/{id}", api.DeleteChannel).Methods("DELETE")
 	router.HandleFunc("/channels/{id}/items", api.listItems).Methods("GET")
+	router.HandleFunc("/channels/{id}/log", api.ListChannelLog).Methods("GET")
+	router.HandleFunc("/channels/{id}/health", api.GetChannelHealth).Methods("GET")
+	router.HandleFunc("/channels/{id}/enable", api.EnableChannel).Methods("POST")
+	router.HandleFunc("/channels/{id}/links", api.ListChannelLinks).Methods("GET")
+	router.HandleFunc("/channels/{id}/refresh", api.RefreshChannel).Methods("POST")
+	router.HandleFunc("/channels/{id}/read", api.MarkChannelRead).Methods("POST")
+	router.HandleFunc("/channels/{id}/restore", api.RestoreChannel).Methods("POST")
 	router.HandleFunc("/channels/{channel_id}/items/{item_id}", api.RemoveItemFromChannel).Methods("DELETE")
+	router.HandleFunc("/items", api.ListTimelineItems).Methods("GET")
+	router.HandleFunc("/items/read", api.MarkAllRead).Methods("POST")
 	router.HandleFunc("/items/{id}", api.PatchItem).Methods("PATCH")
 	router.HandleFunc("/items/{id}", api.DeleteItem).Methods("DELETE")
+	router.HandleFunc("/items/{id}/raw", api.GetItemRaw).Methods("GET")
+	router.HandleFunc("/items/{id}/revisions", api.ListItemRevisions).Methods("GET")
+	router.HandleFunc("/items/{id}/read", api.MarkItemRead).Methods("PUT")
+	router.HandleFunc("/items/{id}/read", api.MarkItemUnread).Methods("DELETE")
+	router.HandleFunc("/items/{id}/star", api.StarItem).Methods("PUT")
+	router.HandleFunc("/items/{id}/star", api.UnstarItem).Methods("DELETE")
+	router.HandleFunc("/items/{id}/later", api.SaveItemForLater).Methods("PUT")
+	router.HandleFunc("/items/{id}/later", api.RemoveItemFromLater).Methods("DELETE")
+	router.HandleFunc("/items/{id}/restore", api.RestoreItem).Methods("POST")
+	router.HandleFunc("/trash", api.ListTrash).Methods("GET")
+	router.HandleFunc("/counters", api.GetCounters).Methods("GET")
+	router.HandleFunc("/stories", api.ListStories).Methods("GET")
+	router.HandleFunc("/search", api.SearchItems).Methods("GET")
+	router.HandleFunc("/fetch/runs", api.ListFetchRuns).Methods("GET")
+	router.HandleFunc("/refresh", api.RefreshAll).Methods("POST")
+	router.HandleFunc("/jobs/{id}", api.GetJob).Methods("GET")
 	// TODO: router.HandleFunc("/tags", api.ListTags).Methods("GET")
 	// TODO: add tag to channel
 	// TODO: remove tag from channel
@@ -38,6 +69,8 @@ func (api *API) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/groups", api.AddGroup).Methods("POST")
 	router.HandleFunc("/groups/{id}", api.UpdateGroup).Methods("PUT")
 	router.HandleFunc("/groups/{id}", api.DeleteGroup).Methods("DELETE")
+	router.HandleFunc("/groups/{id}/refresh", api.RefreshGroup).Methods("POST")
+	router.HandleFunc("/groups/{id}/read", api.MarkGroupRead).Methods("POST")
 	router.HandleFunc("/groups", api.AddChannelToGroup).Methods("POST")
 	router.HandleFunc("/groups", api.RemoveChannelFromGroup).Methods("DELETE")
 }
@@ -63,9 +96,21 @@ func (api *API) AddChannel(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := api.fillChannelFromFeed(w, r, &params); err != nil {
+		return
+	}
 	if err := ValidateStruct(w, &params); err != nil {
 		return
 	}
+	if err := ValidateUpdateInterval(w, params.UpdateInterval); err != nil {
+		return
+	}
+	if err := ValidateProxy(w, params.Proxy); err != nil {
+		return
+	}
+	if err := ValidateContentSelector(w, params.ContentSelector); err != nil {
+		return
+	}
 	ctx := r.Context()
 	queries := models.New(api.DB)
 	if _, err := queries.CreateFeedChannel(ctx, params); err != nil {
@@ -84,16 +129,30 @@ func (api *API) UpdateChannel(w http.ResponseWriter, r *http.Request) {
 	if err := ValidateStruct(w, &params); err != nil {
 		return
 	}
+	if err := ValidateUpdateInterval(w, params.UpdateInterval); err != nil {
+		return
+	}
+	if err := ValidateProxy(w, params.Proxy); err != nil {
+		return
+	}
+	if err := ValidateContentSelector(w, params.ContentSelector); err != nil {
+		return
+	}
 	ctx := r.Context()
 	queries := models.New(api.DB)
-	if err := queries.UpdateFeedChannel(ctx, params); err != nil {
+	updated, err := queries.UpdateFeedChannel(ctx, params)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if updated == 0 {
+		http.Error(w, "channel not found", http.StatusNotFound)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// DeleteChannel handles DELETE requests to delete a channel
+// DeleteChannel handles DELETE requests to move a channel to the trash
 func (api *API) DeleteChannel(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
@@ -103,14 +162,24 @@ func (api *API) DeleteChannel(w http.ResponseWriter, r *http.Request) {
 	}
 	ctx := r.Context()
 	queries := models.New(api.DB)
-	if err := queries.DeleteFeedChannel(ctx, id); err != nil {
+	trashed, err := queries.TrashFeedChannel(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if trashed == 0 {
+		http.Error(w, "channel not found", http.StatusNotFound)
+		return
+	}
+	// The stories don't count the items of the channels in the trash
+	if err := queries.RecountStoryClusters(ctx); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ListItems handles GET requests to list all items of a channel
+// ListItems handles GET requests to list a page of the items of a channel, with the full content in the full view
 func (api *API) listItems(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.ParseInt(vars["id"], 10, 64)
@@ -118,14 +187,43 @@ func (api *API) listItems(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	params, err := parseItemFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params.ChannelID = sql.NullInt64{Int64: id, Valid: true}
+	api.writeItemPage(w, r, params)
+}
+
+// ListChannelLog handles GET requests to list fetch attempts of a channel, newest first
+func (api *API) ListChannelLog(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	ctx := r.Context()
 	queries := models.New(api.DB)
-	items, err := queries.ListFeedItem(ctx, id)
+	logs, err := queries.ListFeedChannelLog(ctx, models.ListFeedChannelLogParams{
+		ChannelID: id,
+		Limit:     limit,
+		Offset:    offset,
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = json.NewEncoder(w).Encode(items)
+	if logs == nil {
+		logs = []models.FeedChannelLog{}
+	}
+	err = json.NewEncoder(w).Encode(logs)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
@@ -156,21 +254,15 @@ func (api *API) RemoveItemFromChannel(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// DeleteItem handles DELETE requests to delete an item
+// DeleteItem handles DELETE requests to move an item to the trash
 func (api *API) DeleteItem(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.ParseInt(vars["id"], 10, 64)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	ctx := r.Context()
-	queries := models.New(api.DB)
-	if err := queries.DeleteFeedItem(ctx, id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.WriteHeader(http.StatusNoContent)
+	api.setItemState(w, r, func(ctx context.Context, queries *models.Queries, id int64) (int64, error) {
+		trashed, err := queries.TrashFeedItem(ctx, id)
+		if err != nil || trashed == 0 {
+			return trashed, err
+		}
+		return trashed, queries.RecountItemStoryCluster(ctx, id)
+	})
 }
 
 func (api *API) PatchChannel(w http.ResponseWriter, r *http.Request) {
@@ -182,12 +274,26 @@ func (api *API) PatchChannel(w http.ResponseWriter, r *http.Request) {
 	if err := ValidateStruct(w, &params); err != nil {
 		return
 	}
+	if err := ValidateUpdateInterval(w, params.UpdateInterval); err != nil {
+		return
+	}
+	if err := ValidateProxy(w, params.Proxy); err != nil {
+		return
+	}
+	if err := ValidateContentSelector(w, params.ContentSelector); err != nil {
+		return
+	}
 	ctx := r.Context()
 	queries := models.New(api.DB)
-	if err := queries.UpdateFeedChannel(ctx, params); err != nil {
+	updated, err := queries.UpdateFeedChannel(ctx, params)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if updated == 0 {
+		http.Error(w, "channel not found", http.StatusNotFound)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -210,9 +316,14 @@ func (api *API) PatchItem(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *API) ListGroups(w http.ResponseWriter, r *http.Request) {
+	sort, descending, err := parseSort(r, []string{"name", "id"})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	ctx := r.Context()
 	queries := models.New(api.DB)
-	groups, err := queries.ListGroup(ctx)
+	groups, err := queries.ListGroup(ctx, models.ListGroupParams{Sort: sort, Descending: descending})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
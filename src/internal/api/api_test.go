@@ -1,6 +1,8 @@
 package api
 
 import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/gatherer"
 	"FeedsCollector/internal/models"
 	"FeedsCollector/internal/utils"
 	"bytes"
@@ -15,6 +17,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,11 +34,27 @@ type initialDataStruct struct {
 }
 
 var testDB *sql.DB
+var testFetcher *gatherer.Fetcher
 var initialData initialDataStruct
 
 func TestMain(m *testing.M) {
-	// Setup database connection
+	if _, err := internal.InitLogging("", internal.InfoLogLevel); err != nil {
+		panic(err)
+	}
+	if _, err := internal.InitLogging("", internal.ErrorLogLevel); err != nil {
+		panic(err)
+	}
+	config := &utils.Config{}
+	if err := utils.ValidateConfig(config); err != nil {
+		panic(err)
+	}
 	var err error
+	testFetcher, err = gatherer.NewFetcher(config)
+	if err != nil {
+		panic(err)
+	}
+
+	// Setup database connection
 	testDB, err = sql.Open("sqlite3", ":memory:")
 	if err != nil {
 		panic(err)
@@ -135,11 +154,30 @@ func runMigrations(db *sql.DB) error {
 		return fmt.Errorf("could not run up migrations: %w", err)
 	}
 
+	if err := utils.MigrateSearch(db, "file://../../db/search_migrations"); err != nil {
+		return fmt.Errorf("could not run search migrations: %w", err)
+	}
+
 	return nil
 }
 
+// newTestDB opens a migrated database of the test's own, for the tests which span all channels
+// or need several connections to the database
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := utils.OpenDatabase(t.TempDir()+"/feeds.db", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := runMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
 func TestListChannels(t *testing.T) {
-	apiInstance := NewAPI(testDB)
+	apiInstance := NewAPI(testDB, testFetcher, nil)
 	router := mux.NewRouter()
 	apiInstance.RegisterRoutes(router)
 
@@ -192,7 +230,7 @@ func TestListChannels(t *testing.T) {
 }
 
 func TestAddChannel(t *testing.T) {
-	apiInstance := NewAPI(testDB)
+	apiInstance := NewAPI(testDB, testFetcher, nil)
 	router := mux.NewRouter()
 	apiInstance.RegisterRoutes(router)
 
@@ -222,7 +260,7 @@ func TestAddChannel(t *testing.T) {
 }
 
 func TestUpdateChannel(t *testing.T) {
-	apiInstance := NewAPI(testDB)
+	apiInstance := NewAPI(testDB, testFetcher, nil)
 	router := mux.NewRouter()
 	apiInstance.RegisterRoutes(router)
 
@@ -266,7 +304,7 @@ func TestUpdateChannel(t *testing.T) {
 }
 
 func TestDeleteChannel(t *testing.T) {
-	apiInstance := NewAPI(testDB)
+	apiInstance := NewAPI(testDB, testFetcher, nil)
 	router := mux.NewRouter()
 	apiInstance.RegisterRoutes(router)
 
@@ -296,7 +334,7 @@ func TestDeleteChannel(t *testing.T) {
 }
 
 func TestGetChannelItemList(t *testing.T) {
-	apiInstance := NewAPI(testDB)
+	apiInstance := NewAPI(testDB, testFetcher, nil)
 	router := mux.NewRouter()
 	apiInstance.RegisterRoutes(router)
 
@@ -312,3 +350,1433 @@ func TestGetChannelItemList(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 }
+
+func TestListChannelLog(t *testing.T) {
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+
+	err := models.New(testDB).CreateFeedChannelLog(context.Background(), models.CreateFeedChannelLogParams{
+		ChannelID:    initialData.channels[1].ID,
+		ErrorClass:   sql.NullString{String: "timeout", Valid: true},
+		ErrorMessage: sql.NullString{String: "context deadline exceeded", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create channel log: %v", err)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/channels/%d/log?limit=10", initialData.channels[1].ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var logs []models.FeedChannelLog
+	if err := json.NewDecoder(rr.Body).Decode(&logs); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(logs) != 1 || logs[0].ErrorClass.String != "timeout" {
+		t.Errorf("Expected 1 timeout log record, got %v", logs)
+	}
+
+	req, err = http.NewRequest("GET", fmt.Sprintf("/channels/%d/log?limit=0", initialData.channels[1].ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestGetChannelHealth(t *testing.T) {
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+		Title:       "Broken Channel",
+		Description: "A channel that keeps failing",
+		Link:        "http://broken.example.com/rss",
+		Host:        "broken.example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to add channel: %v", err)
+	}
+	err = queries.DisableFeedChannel(ctx, models.DisableFeedChannelParams{
+		DisabledReason: sql.NullString{String: "10 consecutive failures", Valid: true},
+		ID:             channel.ID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to disable channel: %v", err)
+	}
+
+	getHealth := func() ChannelHealth {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/channels/%d/health", channel.ID), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var health ChannelHealth
+		if err := json.NewDecoder(rr.Body).Decode(&health); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return health
+	}
+
+	if health := getHealth(); health.State != channelStateDisabled || !health.DisabledReason.Valid {
+		t.Errorf("Expected a disabled channel with a reason, got %+v", health)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("/channels/%d/enable", channel.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+
+	if health := getHealth(); health.State != channelStatePending || health.DisabledReason.Valid {
+		t.Errorf("Expected a pending channel without a reason, got %+v", health)
+	}
+}
+
+func TestAddChannelUpdateInterval(t *testing.T) {
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+
+	for interval, want := range map[int64]int{15: http.StatusCreated, 0: http.StatusBadRequest, 100000: http.StatusBadRequest} {
+		newChannel := models.CreateFeedChannelParams{
+			Title:            "Busy Channel",
+			Description:      "A channel with its own interval",
+			Link:             "http://busy.example.com/rss",
+			Host:             "busy.example.com",
+			UpdateInterval:   null.IntFrom(interval),
+			AdaptiveInterval: true,
+		}
+		body, err := json.Marshal(newChannel)
+		if err != nil {
+			t.Fatalf("Failed to marshal JSON: %v", err)
+		}
+
+		req, err := http.NewRequest("POST", "/channels", bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != want {
+			t.Errorf("update_interval %d: handler returned wrong status code: got %v want %v", interval, status, want)
+		}
+	}
+}
+
+func TestDiscoverChannels(t *testing.T) {
+	const feed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+  <title>Discovered Blog Feed Title</title>
+  <link>http://blog.example.com/</link>
+  <description>Posts of the discovered blog</description>
+</channel>
+</rss>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="/posts.rss"></head></html>`))
+		case "/posts.rss":
+			_, _ = w.Write([]byte(feed))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	post := func(path string, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("/channels/discover", fmt.Sprintf(`{"url": %q}`, server.URL+"/"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var candidates []gatherer.FeedCandidate
+	if err := json.NewDecoder(rr.Body).Decode(&candidates); err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].Link != server.URL+"/posts.rss" || candidates[0].Source != "link" {
+		t.Fatalf("Expected the advertised feed, got %+v", candidates)
+	}
+
+	// Only the link is needed to add the selected feed
+	rr = post("/channels", fmt.Sprintf(`{"link": %q}`, candidates[0].Link))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, http.StatusCreated, rr.Body)
+	}
+	var title, description, host string
+	err := testDB.QueryRow("SELECT title, description, host FROM feed_channel WHERE link = ?", candidates[0].Link).Scan(&title, &description, &host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "Discovered Blog Feed" || description != "Posts of the discovered blog" || host != "blog.example.com" {
+		t.Errorf("Expected the channel to be filled from the feed, got %q, %q, %q", title, description, host)
+	}
+}
+
+func TestGetChannelItemListAttachments(t *testing.T) {
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channelID := initialData.channels[1].ID
+	item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+		Guid:  null.StringFrom("episode"),
+		Title: "Episode",
+		Link:  "http://example2.com/episode",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channelID, ItemID: item.ID}); err != nil {
+		t.Fatal(err)
+	}
+	err = queries.UpsertItemEnclosure(ctx, models.UpsertItemEnclosureParams{
+		ItemID:   item.ID,
+		Url:      "http://example2.com/episode.mp3",
+		MimeType: null.StringFrom("audio/mpeg"),
+		Medium:   null.StringFrom("audio"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	req, err := http.NewRequest("GET", fmt.Sprintf("/channels/%d/items", channelID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var page ItemPage
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	items := page.Items
+	if len(items) != 1 || len(items[0].Attachments) != 1 || items[0].Attachments[0].Url != "http://example2.com/episode.mp3" {
+		t.Errorf("Expected the item with its attachment, got %+v", items)
+	}
+}
+
+func TestGetChannelItemListView(t *testing.T) {
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+		Title:       "Content Channel",
+		Description: "A channel with full content",
+		Link:        "http://content.example.com/rss",
+		Host:        "content.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+		Guid:        null.StringFrom("article"),
+		Title:       "Article",
+		Description: null.StringFrom("Teaser"),
+		Link:        "http://content.example.com/article",
+		Content:     null.StringFrom("<p>Full article</p>"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channel.ID, ItemID: item.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	for view, want := range map[string]bool{"": false, "light": false, "full": true} {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/channels/%d/items?view=%s", channel.ID, view), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var page ItemPage
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		items := page.Items
+		if len(items) != 1 || (items[0].Content != nil) != want {
+			t.Errorf("view %q: expected content=%v, got %+v", view, want, items)
+		}
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/channels/%d/items?view=huge", channel.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestListItemRevisions(t *testing.T) {
+	ctx := context.Background()
+	queries := models.New(testDB)
+	item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+		Guid:        null.StringFrom("corrected"),
+		Title:       "Minister resigns",
+		Description: null.StringFrom("The minister resigned on Monday"),
+		Link:        "http://news.example.com/minister",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.CreateFeedItemRevision(ctx, item.ID); err != nil {
+		t.Fatal(err)
+	}
+	err = queries.UpdateFeedItemShort(ctx, models.UpdateFeedItemShortParams{
+		Title:       "Minister resigns",
+		Description: null.StringFrom("The minister resigned on Tuesday"),
+		Link:        "http://news.example.com/minister",
+		Edited:      true,
+		ID:          item.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	req, err := http.NewRequest("GET", fmt.Sprintf("/items/%d/revisions", item.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var history ItemHistory
+	if err := json.NewDecoder(rr.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if !history.Edited || len(history.Revisions) != 2 || !history.Revisions[1].Current {
+		t.Fatalf("Expected the original and the current version, got %+v", history)
+	}
+	changes := history.Revisions[1].Changes
+	if len(changes) != 1 || changes[0].Field != "description" {
+		t.Fatalf("Expected only the description changed, got %+v", changes)
+	}
+	want := []DiffOp{
+		{Op: diffEqual, Text: "The minister resigned on "},
+		{Op: diffDelete, Text: "Monday"},
+		{Op: diffInsert, Text: "Tuesday"},
+	}
+	if !reflect.DeepEqual(changes[0].Diff, want) {
+		t.Errorf("Unexpected diff %+v", changes[0].Diff)
+	}
+
+	req, err = http.NewRequest("GET", "/items/999999/revisions", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestListStories(t *testing.T) {
+	ctx := context.Background()
+	queries := models.New(testDB)
+	clusterID, err := queries.CreateStoryCluster(ctx, "Election results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var channelIDs []int64
+	for _, host := range []string{"first.example.com", "second.example.com"} {
+		channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+			Title:       "Story " + host[:5],
+			Description: "A channel covering the story",
+			Link:        "http://" + host + "/rss",
+			Host:        host,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+			Guid:  null.StringFrom("election-" + host),
+			Title: "Election results",
+			Link:  "http://" + host + "/election",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channel.ID, ItemID: item.ID}); err != nil {
+			t.Fatal(err)
+		}
+		err = queries.AddItemToStoryCluster(ctx, models.AddItemToStoryClusterParams{ClusterID: null.IntFrom(clusterID), ID: item.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		channelIDs = append(channelIDs, channel.ID)
+	}
+	if err := queries.RefreshStoryCluster(ctx, clusterID); err != nil {
+		t.Fatal(err)
+	}
+
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	req, err := http.NewRequest("GET", "/stories", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var stories []Story
+	if err := json.NewDecoder(rr.Body).Decode(&stories); err != nil {
+		t.Fatal(err)
+	}
+	if len(stories) != 1 || stories[0].ItemCount != 2 || len(stories[0].Items) != 2 || len(stories[0].Channels) != 2 {
+		t.Fatalf("Expected one story of two items from two channels, got %+v", stories)
+	}
+	if stories[0].Channels[0].ID != channelIDs[0] || !reflect.DeepEqual(stories[0].Items[1].Channels, channelIDs[1:]) {
+		t.Errorf("Unexpected channels of the story %+v", stories[0])
+	}
+
+	req, err = http.NewRequest("GET", "/stories?min_items=3", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if body := strings.TrimSpace(rr.Body.String()); body != "[]" {
+		t.Errorf("Expected no stories of three items, got %s", body)
+	}
+}
+
+func TestListFetchRuns(t *testing.T) {
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+		Title:       "Runs Channel",
+		Description: "A channel fetched in a run",
+		Link:        "http://runs.example.com/rss",
+		Host:        "runs.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now().UTC().Add(-time.Minute)
+	runID, err := queries.CreateFetchRun(ctx, started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = queries.CreateFeedChannelLog(ctx, models.CreateFeedChannelLogParams{
+		ChannelID:    channel.ID,
+		Started:      sql.NullTime{Time: started, Valid: true},
+		ErrorClass:   sql.NullString{String: "timeout", Valid: true},
+		ErrorMessage: sql.NullString{String: "context deadline exceeded", Valid: true},
+		RunID:        sql.NullInt64{Int64: runID, Valid: true},
+		DurationMs:   sql.NullInt64{Int64: 30000, Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = queries.UpdateFetchRun(ctx, models.UpdateFetchRunParams{
+		Finished:         sql.NullTime{Time: started.Add(30 * time.Second), Valid: true},
+		Failed:           1,
+		FetchTimeMs:      30000,
+		SlowestChannelID: sql.NullInt64{Int64: channel.ID, Valid: true},
+		SlowestFetchMs:   30000,
+		ID:               runID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	req, err := http.NewRequest("GET", "/fetch/runs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var runs FetchRuns
+	if err := json.NewDecoder(rr.Body).Decode(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs.Runs) == 0 || runs.Latest == nil || runs.Latest.ID != runID {
+		t.Fatalf("Expected the run to be the latest one, got %+v", runs)
+	}
+	latest := runs.Latest
+	if latest.InProgress || latest.DurationMs != 30000 || latest.Failed != 1 || len(latest.Channels) != 1 ||
+		latest.Channels[0].ChannelTitle.String != "Runs Channel" || latest.Channels[0].ErrorClass.String != "timeout" {
+		t.Errorf("Unexpected details of the latest run %+v", latest)
+	}
+
+	req, err = http.NewRequest("GET", "/fetch/runs?limit=0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	db := newTestDB(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Refresh</title>
+<item><guid>%[1]s-1</guid><title>First</title><link>http://refresh.example.com%[1]s/1</link></item>
+<item><guid>%[1]s-2</guid><title>Second</title><link>http://refresh.example.com%[1]s/2</link></item>
+</channel></rss>`, r.URL.Path)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	queries := models.New(db)
+	channelIDs := make(map[string]int64)
+	for _, path := range []string{"/disabled", "/grouped", "/other"} {
+		channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+			Title:       "Refresh " + path,
+			Description: "A channel refreshed on demand",
+			Link:        server.URL + path,
+			Host:        "127.0.0.1",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		channelIDs[path] = channel.ID
+	}
+	var parentID, childID int64
+	err := db.QueryRow("INSERT INTO feed_group (name) VALUES ('News') RETURNING id").Scan(&parentID)
+	if err == nil {
+		err = db.QueryRow("INSERT INTO feed_group (name, parent_id) VALUES ('World', ?) RETURNING id", parentID).Scan(&childID)
+	}
+	if err == nil {
+		err = queries.AddChannelToGroup(ctx, models.AddChannelToGroupParams{GroupID: childID, ChannelID: channelIDs["/grouped"]})
+	}
+	if err == nil {
+		_, err = db.Exec("UPDATE feed_channel SET enabled = 0 WHERE id = ?", channelIDs["/disabled"])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &utils.Config{}
+	config.Politeness.HostDelay = time.Millisecond
+	if err := utils.ValidateConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	scheduler := gatherer.NewScheduler(db, config, testFetcher)
+	runCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.Run(runCtx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	router := mux.NewRouter()
+	NewAPI(db, testFetcher, scheduler).RegisterRoutes(router)
+	request := func(method, target string) (int, gatherer.Job) {
+		t.Helper()
+		req, err := http.NewRequest(method, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var job gatherer.Job
+		if rr.Code == http.StatusOK || rr.Code == http.StatusAccepted {
+			if err := json.NewDecoder(rr.Body).Decode(&job); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr.Code, job
+	}
+
+	// A disabled channel is fetched when it's requested explicitly
+	status, job := request("POST", fmt.Sprintf("/channels/%d/refresh?timeout=10s", channelIDs["/disabled"]))
+	if status != http.StatusOK || job.Status != gatherer.JobDone || job.ItemsNew != 2 || job.Succeeded != 1 ||
+		len(job.Channels) != 1 || job.Channels[0].Status != gatherer.JobChannelSucceeded {
+		t.Errorf("Expected the channel refreshed with two new items, got %v %+v", status, job)
+	}
+
+	status, job = request("POST", fmt.Sprintf("/groups/%d/refresh", parentID))
+	if status != http.StatusAccepted && status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
+	}
+	status, job = request("GET", fmt.Sprintf("/jobs/%d?timeout=10s", job.ID))
+	if status != http.StatusOK || job.Status != gatherer.JobDone || len(job.Channels) != 1 ||
+		job.Channels[0].ChannelID != channelIDs["/grouped"] || !job.Finished.Valid {
+		t.Errorf("Expected the channel of the subgroup refreshed, got %v %+v", status, job)
+	}
+
+	status, job = request("POST", "/refresh?timeout=10s")
+	if status != http.StatusOK || job.Succeeded != 2 {
+		t.Errorf("Expected both enabled channels refreshed, got %v %+v", status, job)
+	}
+
+	for target, want := range map[string]int{
+		"/channels/999999/refresh": http.StatusNotFound,
+		"/groups/999999/refresh":   http.StatusNotFound,
+		"/refresh?timeout=1h":      http.StatusBadRequest,
+	} {
+		if status, _ := request("POST", target); status != want {
+			t.Errorf("POST %s returned wrong status code: got %v want %v", target, status, want)
+		}
+	}
+	if status, _ := request("GET", "/jobs/999999"); status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestListChannelItemsPages(t *testing.T) {
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+		Title:       "Paged Channel",
+		Description: "A channel with many items",
+		Link:        "http://paged.example.com/rss",
+		Host:        "paged.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two items share the publication date, so the pages only stay stable with the id as a tiebreaker
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var itemIDs []int64
+	for i, offset := range []time.Duration{0, time.Hour, time.Hour, 2 * time.Hour, 3 * time.Hour} {
+		item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+			Guid:      null.StringFrom(fmt.Sprintf("paged-%d", i)),
+			Title:     fmt.Sprintf("Item %c", 'E'-i),
+			Link:      fmt.Sprintf("http://paged.example.com/%d", i),
+			Published: null.TimeFrom(published.Add(offset)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channel.ID, ItemID: item.ID}); err != nil {
+			t.Fatal(err)
+		}
+		itemIDs = append(itemIDs, item.ID)
+	}
+
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	listAll := func(query string) []ChannelItem {
+		t.Helper()
+		var items []ChannelItem
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > 5 {
+				t.Fatalf("%s: the cursor doesn't advance", query)
+			}
+			url := fmt.Sprintf("/channels/%d/items?limit=2&%s&cursor=%s", channel.ID, query, cursor)
+			req, err := http.NewRequest("GET", url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s: handler returned wrong status code: got %v want %v (%s)", query, rr.Code, http.StatusOK, rr.Body)
+			}
+			var page ItemPage
+			if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, page.Items...)
+			if !page.NextCursor.Valid {
+				return items
+			}
+			cursor = page.NextCursor.String
+		}
+	}
+	titles := func(items []ChannelItem) string {
+		var titles []string
+		for _, item := range items {
+			titles = append(titles, item.Title)
+		}
+		return strings.Join(titles, ",")
+	}
+
+	// Item C was created after item D
+	if got, want := titles(listAll("")), "Item A,Item B,Item C,Item D,Item E"; got != want {
+		t.Errorf("Expected the newest items first, got %v want %v", got, want)
+	}
+	if got, want := titles(listAll("sort=published&order=asc")), "Item E,Item D,Item C,Item B,Item A"; got != want {
+		t.Errorf("Expected the oldest items first, got %v want %v", got, want)
+	}
+	if got, want := titles(listAll("sort=title")), "Item A,Item B,Item C,Item D,Item E"; got != want {
+		t.Errorf("Expected the items by title, got %v want %v", got, want)
+	}
+	if got, want := titles(listAll("sort=title&order=desc")), "Item E,Item D,Item C,Item B,Item A"; got != want {
+		t.Errorf("Expected the items by descending title, got %v want %v", got, want)
+	}
+	// The items are created within a second, the id keeps the order they were stored in
+	if got, want := titles(listAll("sort=created&order=asc")), "Item E,Item D,Item C,Item B,Item A"; got != want {
+		t.Errorf("Expected the first created items first, got %v want %v", got, want)
+	}
+	if got, want := titles(listAll("sort=created")), "Item A,Item B,Item C,Item D,Item E"; got != want {
+		t.Errorf("Expected the last created items first, got %v want %v", got, want)
+	}
+
+	// An edited publication date with an offset is stored in UTC, between the ones of item A and item B
+	body := fmt.Sprintf(`{"id": %d, "guid": "paged-0", "title": "Item E", "link": "http://paged.example.com/0",
+		"description": "The oldest item of the paged channel, published again on the same afternoon",
+		"author": "Editor", "published": "2024-05-01T16:30:00+02:00"}`, itemIDs[0])
+	req, err := http.NewRequest("PATCH", fmt.Sprintf("/items/%d", itemIDs[0]), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("handler returned wrong status code: got %v want %v (%s)", rr.Code, http.StatusNoContent, rr.Body)
+	}
+	var stored string
+	if err := testDB.QueryRow("SELECT CAST(published AS TEXT) FROM feed_item WHERE id = ?", itemIDs[0]).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored != "2024-05-01 14:30:00" {
+		t.Errorf("Expected the publication date stored in UTC, got %q", stored)
+	}
+	if got, want := titles(listAll("")), "Item A,Item E,Item B,Item C,Item D"; got != want {
+		t.Errorf("Expected the edited item by its new publication date, got %v want %v", got, want)
+	}
+
+	req, err = http.NewRequest("GET", fmt.Sprintf("/channels/%d/items?limit=2", channel.ID), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var page ItemPage
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{
+		"sort=title&cursor=" + page.NextCursor.String, // The cursor of another sort order
+		"cursor=garbage",
+		"sort=link",
+		"order=up",
+		"limit=0",
+	} {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/channels/%d/items?%s", channel.ID, query), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestListChannelItemsFilters(t *testing.T) {
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+		Title:       "Filtered Channel",
+		Description: "A channel with various items",
+		Link:        "http://filtered.example.com/rss",
+		Host:        "filtered.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, bob := "Alice Smith", "Bob Jones"
+	createItem := func(guid string, author *string, published time.Time) int64 {
+		t.Helper()
+		item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+			Guid:      null.StringFrom(guid),
+			Title:     guid,
+			Link:      "http://filtered.example.com/" + guid,
+			Author:    author,
+			Published: null.TimeFrom(published),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channel.ID, ItemID: item.ID}); err != nil {
+			t.Fatal(err)
+		}
+		return item.ID
+	}
+	readID := createItem("read", &alice, time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC))
+	starredID := createItem("starred", &bob, time.Date(2024, 2, 10, 8, 0, 0, 0, time.UTC))
+	deletedID := createItem("deleted", nil, time.Date(2024, 3, 10, 8, 0, 0, 0, time.FixedZone("CET", 3600)))
+	podcastID := createItem("podcast", &alice, time.Date(2024, 4, 10, 8, 0, 0, 0, time.UTC))
+	for query, id := range map[string]int64{
+		"UPDATE feed_item SET read = 1 WHERE id = ?":    readID,
+		"UPDATE feed_item SET starred = 1 WHERE id = ?": starredID,
+		"UPDATE feed_item SET deleted = 1 WHERE id = ?": deletedID,
+	} {
+		if _, err := testDB.Exec(query, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = queries.UpsertItemEnclosure(ctx, models.UpsertItemEnclosureParams{ItemID: podcastID, Url: "http://filtered.example.com/podcast.mp3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	for query, want := range map[string]string{
+		// Items in the trash are left out unless they are asked for
+		"":                               "podcast,starred,read",
+		"read=true":                      "read",
+		"read=false":                     "podcast,starred",
+		"read=false&deleted=true":        "deleted",
+		"starred=1":                      "starred",
+		"deleted=false":                  "podcast,starred,read",
+		"has_enclosures=true":            "podcast",
+		"has_enclosures=false&deleted=0": "starred,read",
+		"author=alice":                   "podcast,read",
+		"published_from=2024-02-10T08:00:00Z&published_to=2024-04-10":              "starred",
+		"published_from=2024-02-10T08:00:00Z&published_to=2024-04-10&deleted=true": "deleted",
+		// 08:00 CET is 07:00 UTC
+		"published_from=2024-03-10T07:00:01Z": "podcast",
+	} {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/channels/%d/items?%s", channel.ID, query), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q: handler returned wrong status code: got %v want %v (%s)", query, rr.Code, http.StatusOK, rr.Body)
+		}
+		var page ItemPage
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		var guids []string
+		for _, item := range page.Items {
+			guids = append(guids, item.Guid.String)
+		}
+		if got := strings.Join(guids, ","); got != want {
+			t.Errorf("%q: got items %v want %v", query, got, want)
+		}
+	}
+
+	for _, query := range []string{"read=maybe", "published_from=yesterday"} {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/channels/%d/items?%s", channel.ID, query), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: handler returned wrong status code: got %v want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestListGroupsSort(t *testing.T) {
+	queries := models.New(testDB)
+	for _, name := range []string{"Sorted B", "Sorted C", "Sorted A"} {
+		if err := queries.CreateGroup(context.Background(), models.CreateGroupParams{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apiInstance := NewAPI(testDB, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	list := func(query string) []models.ListGroupRow {
+		t.Helper()
+		req, err := http.NewRequest("GET", "/groups?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%q: handler returned wrong status code: got %v want %v (%s)", query, rr.Code, http.StatusOK, rr.Body)
+		}
+		var groups []models.ListGroupRow
+		if err := json.NewDecoder(rr.Body).Decode(&groups); err != nil {
+			t.Fatal(err)
+		}
+		return groups
+	}
+	sorted := func(query string, less func(a, b models.ListGroupRow) bool) {
+		t.Helper()
+		groups := list(query)
+		if len(groups) < 3 {
+			t.Fatalf("%q: expected the groups, got %+v", query, groups)
+		}
+		for i := 1; i < len(groups); i++ {
+			if !less(groups[i-1], groups[i]) {
+				t.Errorf("%q: groups out of order: %+v", query, groups)
+				return
+			}
+		}
+	}
+	sorted("", func(a, b models.ListGroupRow) bool { return a.Name < b.Name || a.Name == b.Name && a.ID < b.ID })
+	sorted("order=desc", func(a, b models.ListGroupRow) bool { return a.Name > b.Name || a.Name == b.Name && a.ID > b.ID })
+	sorted("sort=id", func(a, b models.ListGroupRow) bool { return a.ID < b.ID })
+	sorted("sort=id&order=desc", func(a, b models.ListGroupRow) bool { return a.ID > b.ID })
+
+	req, err := http.NewRequest("GET", "/groups?sort=parent_id", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestListTimelineItems(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	queries := models.New(db)
+	insert := func(query string, args ...any) int64 {
+		t.Helper()
+		result, err := db.Exec(query, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	parentID := insert("INSERT INTO feed_group (name) VALUES ('Parent')")
+	childID := insert("INSERT INTO feed_group (name, parent_id) VALUES ('Child', ?)", parentID)
+	tagID := insert("INSERT INTO tag (name) VALUES ('news')")
+
+	channelIDs := make(map[string]int64)
+	for _, name := range []string{"alpha", "beta", "gamma", "delta"} {
+		channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+			Title:       "Channel " + name,
+			Description: "A timeline channel",
+			Link:        "http://" + name + ".example.com/rss",
+			Host:        name + ".example.com",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		channelIDs[name] = channel.ID
+	}
+	for name, groupID := range map[string]int64{"alpha": parentID, "beta": childID, "delta": parentID} {
+		if err := queries.AddChannelToGroup(ctx, models.AddChannelToGroupParams{GroupID: groupID, ChannelID: channelIDs[name]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert("INSERT INTO feed_channel_tag (channel_id, tag_id) VALUES (?, ?)", channelIDs["gamma"], tagID)
+	insert("UPDATE feed_channel SET enabled = 0 WHERE id = ?", channelIDs["delta"])
+
+	published := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i, item := range []struct {
+		guid     string
+		channels []string
+	}{
+		{"alpha-1", []string{"alpha"}},
+		{"beta-1", []string{"beta"}},
+		{"shared", []string{"alpha", "beta"}},
+		{"gamma-1", []string{"gamma"}},
+		{"delta-1", []string{"delta"}},
+	} {
+		created, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+			Guid:      null.StringFrom(item.guid),
+			Title:     item.guid,
+			Link:      "http://example.com/" + item.guid,
+			Published: null.TimeFrom(published.Add(time.Duration(i) * time.Hour)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range item.channels {
+			err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channelIDs[name], ItemID: created.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if item.guid == "alpha-1" {
+			insert("UPDATE feed_item SET read = 1 WHERE id = ?", created.ID)
+		}
+	}
+
+	apiInstance := NewAPI(db, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	request := func(query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest("GET", "/items?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	listAll := func(query string) []ChannelItem {
+		t.Helper()
+		var items []ChannelItem
+		cursor := ""
+		for pages := 0; pages < 5; pages++ {
+			rr := request(fmt.Sprintf("limit=2&%s&cursor=%s", query, cursor))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%q: handler returned wrong status code: got %v want %v (%s)", query, rr.Code, http.StatusOK, rr.Body)
+			}
+			var page ItemPage
+			if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, page.Items...)
+			if !page.NextCursor.Valid {
+				return items
+			}
+			cursor = page.NextCursor.String
+		}
+		t.Fatalf("%q: the cursor doesn't advance", query)
+		return nil
+	}
+	guids := func(items []ChannelItem) string {
+		var guids []string
+		for _, item := range items {
+			guids = append(guids, item.Guid.String)
+		}
+		return strings.Join(guids, ",")
+	}
+
+	for query, want := range map[string]string{
+		"":                                "gamma-1,shared,beta-1,alpha-1",
+		fmt.Sprintf("group=%d", parentID): "shared,beta-1,alpha-1",
+		fmt.Sprintf("group=%d", childID):  "shared,beta-1",
+		fmt.Sprintf("tag=%d", tagID):      "gamma-1",
+		"read=false":                      "gamma-1,shared,beta-1",
+		fmt.Sprintf("group=%d&tag=%d", parentID, tagID): "",
+	} {
+		if got := guids(listAll(query)); got != want {
+			t.Errorf("%q: got items %v want %v", query, got, want)
+		}
+	}
+
+	items := listAll(fmt.Sprintf("group=%d", childID))
+	want := []ItemChannel{{ID: channelIDs["alpha"], Title: "Channel alpha"}, {ID: channelIDs["beta"], Title: "Channel beta"}}
+	if len(items) == 0 || !reflect.DeepEqual(items[0].Channels, want) {
+		t.Errorf("Expected the shared item with both channels, got %+v", items)
+	}
+
+	for query, status := range map[string]int{
+		"group=999999": http.StatusNotFound,
+		"tag=999999":   http.StatusNotFound,
+		"group=parent": http.StatusBadRequest,
+	} {
+		if rr := request(query); rr.Code != status {
+			t.Errorf("%q: handler returned wrong status code: got %v want %v", query, rr.Code, status)
+		}
+	}
+}
+
+func TestItemState(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	queries := models.New(db)
+	result, err := db.Exec("INSERT INTO feed_group (name) VALUES ('Parent')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentID, _ := result.LastInsertId()
+	result, err = db.Exec("INSERT INTO feed_group (name, parent_id) VALUES ('Child', ?)", parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	childID, _ := result.LastInsertId()
+
+	var channelIDs []int64
+	for i, groupID := range []int64{parentID, childID} {
+		channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+			Title:       fmt.Sprintf("State channel %d", i),
+			Description: "A state channel",
+			Link:        fmt.Sprintf("http://state%d.example.com/rss", i),
+			Host:        fmt.Sprintf("state%d.example.com", i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := queries.AddChannelToGroup(ctx, models.AddChannelToGroupParams{GroupID: groupID, ChannelID: channel.ID}); err != nil {
+			t.Fatal(err)
+		}
+		channelIDs = append(channelIDs, channel.ID)
+	}
+	// Three items in the first channel and two in the second, published an hour apart
+	published := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var itemIDs []int64
+	for i := 0; i < 5; i++ {
+		item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+			Guid:      null.StringFrom(fmt.Sprintf("state-%d", i)),
+			Title:     fmt.Sprintf("State item %d", i),
+			Link:      fmt.Sprintf("http://example.com/state-%d", i),
+			Published: null.TimeFrom(published.Add(time.Duration(i) * time.Hour)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channelIDs[i/3], ItemID: item.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		itemIDs = append(itemIDs, item.ID)
+	}
+
+	apiInstance := NewAPI(db, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	request := func(method, url, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	counters := func() Counters {
+		t.Helper()
+		rr := request("GET", "/counters", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		var counters Counters
+		if err := json.NewDecoder(rr.Body).Decode(&counters); err != nil {
+			t.Fatal(err)
+		}
+		return counters
+	}
+
+	for _, test := range []struct {
+		method, url string
+		status      int
+	}{
+		{"PUT", fmt.Sprintf("/items/%d/read", itemIDs[0]), http.StatusNoContent},
+		{"PUT", fmt.Sprintf("/items/%d/star", itemIDs[1]), http.StatusNoContent},
+		{"PUT", fmt.Sprintf("/items/%d/star", itemIDs[2]), http.StatusNoContent},
+		{"DELETE", fmt.Sprintf("/items/%d/star", itemIDs[2]), http.StatusNoContent},
+		{"PUT", fmt.Sprintf("/items/%d/later", itemIDs[3]), http.StatusNoContent},
+		{"PUT", "/items/999999/read", http.StatusNotFound},
+		{"DELETE", "/items/999999/later", http.StatusNotFound},
+		{"PUT", "/items/first/star", http.StatusBadRequest},
+	} {
+		if rr := request(test.method, test.url, ""); rr.Code != test.status {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", test.method, test.url, rr.Code, test.status)
+		}
+	}
+	want := Counters{
+		Unread: 4, Starred: 1, ReadLater: 1,
+		Channels: []models.ListChannelUnreadCountsRow{{ChannelID: channelIDs[0], Unread: 2}, {ChannelID: channelIDs[1], Unread: 2}},
+		Groups:   []models.ListGroupUnreadCountsRow{{GroupID: parentID, Unread: 4}, {GroupID: childID, Unread: 2}},
+	}
+	if got := counters(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got counters %+v want %+v", got, want)
+	}
+	if rr := request("DELETE", fmt.Sprintf("/items/%d/read", itemIDs[0]), ""); rr.Code != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if got := counters(); got.Unread != 5 {
+		t.Errorf("got %d unread items want 5", got.Unread)
+	}
+
+	// The first item got the id of a purged one and was stored after the client loaded its list
+	if _, err := db.Exec("UPDATE feed_item SET created = datetime('now', '+1 hour') WHERE id = ?", itemIDs[0]); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		url, body string
+		status    int
+		marked    int64
+	}{
+		{fmt.Sprintf("/channels/%d/read", channelIDs[0]), "{}", http.StatusBadRequest, 0},
+		{fmt.Sprintf("/channels/%d/read", channelIDs[0]), "", http.StatusBadRequest, 0},
+		{fmt.Sprintf("/channels/%d/read", channelIDs[0]), "not json", http.StatusBadRequest, 0},
+		{"/channels/999999/read", `{"max_item_id": 1}`, http.StatusNotFound, 0},
+		{"/groups/999999/read", `{"max_item_id": 1}`, http.StatusNotFound, 0},
+		// Up to the second item of the first channel, the first one arrived later
+		{fmt.Sprintf("/channels/%d/read", channelIDs[0]), fmt.Sprintf(`{"max_item_id": %d}`, itemIDs[1]), http.StatusOK, 1},
+		// The child group holds the second channel, whose first item was published before 04:00
+		{fmt.Sprintf("/groups/%d/read", childID), `{"before": "2024-06-01T04:00:00Z"}`, http.StatusOK, 1},
+		{"/items/read", fmt.Sprintf(`{"max_item_id": %d, "before": "2024-06-01T06:00:00+02:00"}`, itemIDs[4]), http.StatusOK, 1},
+		{fmt.Sprintf("/groups/%d/read", parentID), fmt.Sprintf(`{"max_item_id": %d}`, itemIDs[4]), http.StatusOK, 1},
+		{"/items/read", fmt.Sprintf(`{"max_item_id": %d}`, itemIDs[0]), http.StatusOK, 1},
+	} {
+		rr := request("POST", test.url, test.body)
+		if rr.Code != test.status {
+			t.Errorf("%s %s: handler returned wrong status code: got %v want %v", test.url, test.body, rr.Code, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			if test.body == "" && strings.TrimSpace(rr.Body.String()) != "max_item_id or before is required" {
+				t.Errorf("%s: got error %q for an empty body", test.url, rr.Body.String())
+			}
+			continue
+		}
+		var got MarkReadResult
+		if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Marked != test.marked {
+			t.Errorf("%s %s: got %d marked items want %d", test.url, test.body, got.Marked, test.marked)
+		}
+	}
+	if got := counters(); got.Unread != 0 {
+		t.Errorf("got %d unread items want 0", got.Unread)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/channels/%d/items?read_later=true", channelIDs[1]), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var page ItemPage
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != itemIDs[3] || !page.Items[0].ReadLater {
+		t.Errorf("Expected the item saved for later, got %+v", page.Items)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	queries := models.New(db)
+	var channelIDs, itemIDs []int64
+	for i := 0; i < 2; i++ {
+		channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+			Title:       fmt.Sprintf("Trash channel %d", i),
+			Description: "A trash channel",
+			Link:        fmt.Sprintf("http://trash%d.example.com/rss", i),
+			Host:        fmt.Sprintf("trash%d.example.com", i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		channelIDs = append(channelIDs, channel.ID)
+		item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+			Guid:  null.StringFrom(fmt.Sprintf("trash-%d", i)),
+			Title: fmt.Sprintf("Trash item %d", i),
+			Link:  fmt.Sprintf("http://example.com/trash-%d", i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channel.ID, ItemID: item.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		itemIDs = append(itemIDs, item.ID)
+	}
+	// The first item is published in both channels, the items are copies of a story
+	err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channelIDs[1], ItemID: itemIDs[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusterID, err := queries.CreateStoryCluster(ctx, "Trash story")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, itemID := range itemIDs {
+		err := queries.AddItemToStoryCluster(ctx, models.AddItemToStoryClusterParams{ClusterID: null.IntFrom(clusterID), ID: itemID})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queries.RefreshStoryCluster(ctx, clusterID); err != nil {
+		t.Fatal(err)
+	}
+
+	apiInstance := NewAPI(db, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	request := func(method, url string, status int) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != status {
+			t.Fatalf("%s %s: handler returned wrong status code: got %v want %v (%s)", method, url, rr.Code, status, rr.Body)
+		}
+		return rr
+	}
+	timeline := func() []int64 {
+		t.Helper()
+		var page ItemPage
+		if err := json.NewDecoder(request("GET", "/items?sort=created&order=asc", http.StatusOK).Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, item := range page.Items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+	storyItems := func() int64 {
+		t.Helper()
+		var stories []Story
+		if err := json.NewDecoder(request("GET", "/stories?min_items=1", http.StatusOK).Body).Decode(&stories); err != nil {
+			t.Fatal(err)
+		}
+		if len(stories) == 0 {
+			return 0
+		}
+		return stories[0].ItemCount
+	}
+	trash := func() Trash {
+		t.Helper()
+		var trash Trash
+		if err := json.NewDecoder(request("GET", "/trash", http.StatusOK).Body).Decode(&trash); err != nil {
+			t.Fatal(err)
+		}
+		return trash
+	}
+
+	request("DELETE", fmt.Sprintf("/items/%d", itemIDs[0]), http.StatusNoContent)
+	request("DELETE", "/items/999999", http.StatusNotFound)
+	if got := timeline(); !reflect.DeepEqual(got, itemIDs[1:]) {
+		t.Errorf("Expected the deleted item left out of the timeline, got %v", got)
+	}
+	got := trash()
+	wantChannels := []ItemChannel{{ID: channelIDs[0], Title: "Trash channel 0"}, {ID: channelIDs[1], Title: "Trash channel 1"}}
+	if len(got.Channels) != 0 || len(got.Items) != 1 || got.Items[0].ID != itemIDs[0] || !got.Items[0].DeletedAt.Valid ||
+		!reflect.DeepEqual(got.Items[0].Channels, wantChannels) {
+		t.Errorf("Expected the deleted item in the trash, got %+v", got)
+	}
+	if got := storyItems(); got != 1 {
+		t.Errorf("Expected the story to count the items out of the trash, got %d", got)
+	}
+	request("POST", fmt.Sprintf("/items/%d/restore", itemIDs[0]), http.StatusNoContent)
+	request("POST", fmt.Sprintf("/items/%d/restore", itemIDs[0]), http.StatusNotFound)
+	if got := timeline(); !reflect.DeepEqual(got, itemIDs) {
+		t.Errorf("Expected the restored item in the timeline, got %v", got)
+	}
+	if got := storyItems(); got != 2 {
+		t.Errorf("Expected the story to count the restored item, got %d", got)
+	}
+
+	request("DELETE", fmt.Sprintf("/channels/%d", channelIDs[1]), http.StatusNoContent)
+	request("DELETE", "/channels/999999", http.StatusNotFound)
+	if got := timeline(); !reflect.DeepEqual(got, itemIDs[:1]) {
+		t.Errorf("Expected the items of the deleted channel left out of the timeline, got %v", got)
+	}
+	var page ItemPage
+	if err := json.NewDecoder(request("GET", "/items", http.StatusOK).Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || !reflect.DeepEqual(page.Items[0].Channels, []ItemChannel{{ID: channelIDs[0], Title: "Trash channel 0"}}) {
+		t.Errorf("Expected the deleted channel left out of the item channels, got %+v", page.Items)
+	}
+	if got := storyItems(); got != 1 {
+		t.Errorf("Expected the story not to count the items of the deleted channel, got %d", got)
+	}
+	var channels []models.ListAllFeedChannelRow
+	if err := json.NewDecoder(request("GET", "/channels", http.StatusOK).Body).Decode(&channels); err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 1 || channels[0].ID != channelIDs[0] {
+		t.Errorf("Expected the deleted channel left out of the channels, got %+v", channels)
+	}
+	request("POST", fmt.Sprintf("/channels/%d/refresh", channelIDs[1]), http.StatusNotFound)
+	request("GET", fmt.Sprintf("/channels/%d/health", channelIDs[1]), http.StatusNotFound)
+	edit := func(method string, status int) {
+		t.Helper()
+		body := fmt.Sprintf(`{"id": %d, "title": "Edited channel", "link": "http://trash1.example.com/rss"}`, channelIDs[1])
+		req, err := http.NewRequest(method, fmt.Sprintf("/channels/%d", channelIDs[1]), strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != status {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v (%s)", method, rr.Code, status, rr.Body)
+		}
+	}
+	edit("PUT", http.StatusNotFound)
+	edit("PATCH", http.StatusNotFound)
+	if got := trash(); len(got.Channels) != 1 || got.Channels[0].ID != channelIDs[1] || len(got.Items) != 0 {
+		t.Errorf("Expected the deleted channel in the trash, got %+v", got)
+	}
+	request("POST", fmt.Sprintf("/channels/%d/restore", channelIDs[1]), http.StatusNoContent)
+	request("POST", fmt.Sprintf("/channels/%d/restore", channelIDs[1]), http.StatusNotFound)
+	if got := timeline(); !reflect.DeepEqual(got, itemIDs) {
+		t.Errorf("Expected the items of the restored channel in the timeline, got %v", got)
+	}
+	if got := storyItems(); got != 2 {
+		t.Errorf("Expected the story to count the items of the restored channel, got %d", got)
+	}
+	request("GET", fmt.Sprintf("/channels/%d/health", channelIDs[1]), http.StatusOK)
+	edit("PUT", http.StatusNoContent)
+}
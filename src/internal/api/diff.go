@@ -0,0 +1,108 @@
+package api
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Operations of a text diff
+const (
+	diffEqual  = "equal"
+	diffInsert = "insert"
+	diffDelete = "delete"
+)
+
+// maxDiffCells limits the LCS table of a diff, longer changes are reported as a whole replacement
+const maxDiffCells = 4_000_000
+
+// diffTokens splits a text into words and the whitespace between them
+var diffTokens = regexp.MustCompile(`\s+|\S+`)
+
+// DiffOp is a run of words kept, inserted or deleted by an edit
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// diffWords returns the word-level diff turning the old text into the new one
+func diffWords(old, new string) []DiffOp {
+	a := diffTokens.FindAllString(old, -1)
+	b := diffTokens.FindAllString(new, -1)
+
+	// The common prefix and suffix are kept as they are, only the middle is compared
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffOp
+	add := func(op string, token string) {
+		if len(ops) > 0 && ops[len(ops)-1].Op == op {
+			ops[len(ops)-1].Text += token
+			return
+		}
+		ops = append(ops, DiffOp{Op: op, Text: token})
+	}
+	for _, token := range a[:prefix] {
+		add(diffEqual, token)
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(middleA)*len(middleB) > maxDiffCells {
+		add(diffDelete, strings.Join(middleA, ""))
+		add(diffInsert, strings.Join(middleB, ""))
+	} else {
+		for _, op := range lcsDiff(middleA, middleB) {
+			add(op.Op, op.Text)
+		}
+	}
+	for _, token := range a[len(a)-suffix:] {
+		add(diffEqual, token)
+	}
+	return ops
+}
+
+// lcsDiff diffs the tokens through their longest common subsequence
+func lcsDiff(a, b []string) []DiffOp {
+	// lengths[i][j] is the LCS length of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var ops []DiffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, DiffOp{Op: diffEqual, Text: a[i]})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			ops = append(ops, DiffOp{Op: diffDelete, Text: a[i]})
+			i++
+		default:
+			ops = append(ops, DiffOp{Op: diffInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, DiffOp{Op: diffDelete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, DiffOp{Op: diffInsert, Text: b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/gatherer"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type discoverParams struct {
+	URL string `json:"url" validate:"required,url"`
+}
+
+// DiscoverChannels handles POST requests to find the feeds of a website page
+func (api *API) DiscoverChannels(w http.ResponseWriter, r *http.Request) {
+	var params discoverParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ValidateStruct(w, &params); err != nil {
+		return
+	}
+	candidates, err := gatherer.Discover(r.Context(), api.Fetcher, params.URL)
+	if err != nil {
+		if errors.Is(err, gatherer.ErrNoFeeds) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	err = json.NewEncoder(w).Encode(candidates)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// fillChannelFromFeed takes the missing title, description and host of a new channel from its feed.
+// It fails only if the title is still missing, the rest can be left empty.
+func (api *API) fillChannelFromFeed(w http.ResponseWriter, r *http.Request, params *models.CreateFeedChannelParams) error {
+	if params.Title != "" && params.Description != "" && params.Host != "" {
+		return nil
+	}
+	if err := validate.Var(params.Link, "required,url"); err != nil {
+		// ValidateStruct reports the invalid link
+		return nil
+	}
+	feed, err := gatherer.DescribeFeed(r.Context(), api.Fetcher, params.Link)
+	if err != nil {
+		if params.Title == "" {
+			http.Error(w, "could not take the title from the feed: "+err.Error(), http.StatusBadGateway)
+			return err
+		}
+		internal.InfoLogger.Printf("Could not describe feed %s: %v", params.Link, err)
+		return nil
+	}
+	if params.Title == "" {
+		params.Title = strings.TrimSpace(utils.Truncate(feed.Title, utils.MaxChannelTitle))
+	}
+	if params.Description == "" {
+		params.Description = feed.Description
+	}
+	if params.Host == "" {
+		params.Host = feed.Host
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"FeedsCollector/internal/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Channel health states
+const (
+	channelStateOK       = "ok"       // The last fetch succeeded
+	channelStatePending  = "pending"  // The channel has never been fetched successfully
+	channelStateFailing  = "failing"  // The last fetches failed, the gatherer is backing off
+	channelStateDisabled = "disabled" // The channel is not fetched at all
+	channelStateGone     = "gone"     // The publisher removed the feed, the channel is disabled
+)
+
+// ChannelHealth describes how the gatherer is doing with a channel
+type ChannelHealth struct {
+	models.GetFeedChannelHealthRow
+	State       string                         `json:"state"`
+	Moved       bool                           `json:"moved"` // The link differs from the one the channel was subscribed with
+	LastSuccess sql.NullTime                   `json:"last_success"`
+	LastError   *models.GetLastChannelErrorRow `json:"last_error,omitempty"`
+}
+
+// GetChannelHealth handles GET requests to report the health state of a channel
+func (api *API) GetChannelHealth(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	channel, err := queries.GetFeedChannelHealth(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "channel not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	health := ChannelHealth{
+		GetFeedChannelHealthRow: channel,
+		Moved:                   channel.OriginalLink.Valid && channel.OriginalLink.String != channel.Link,
+	}
+
+	health.LastSuccess, err = queries.GetLastChannelUpdateDate(ctx, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lastError, err := queries.GetLastChannelError(ctx, id)
+	switch {
+	case err == nil:
+		health.LastError = &lastError
+	case !errors.Is(err, sql.ErrNoRows):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case channel.Gone:
+		health.State = channelStateGone
+	case !channel.Enabled:
+		health.State = channelStateDisabled
+	case channel.FailureCount > 0:
+		health.State = channelStateFailing
+	case !health.LastSuccess.Valid:
+		health.State = channelStatePending
+	default:
+		health.State = channelStateOK
+	}
+
+	err = json.NewEncoder(w).Encode(health)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// EnableChannel handles POST requests to re-enable a channel and reset its failure counter
+func (api *API) EnableChannel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	if err := queries.EnableFeedChannel(ctx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListChannelLinks handles GET requests to list link changes of a channel caused by permanent redirects, newest first
+func (api *API) ListChannelLinks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	links, err := queries.ListFeedChannelLink(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = json.NewEncoder(w).Encode(links)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
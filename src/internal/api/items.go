@@ -0,0 +1,362 @@
+package api
+
+import (
+	"FeedsCollector/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+// Item list views selected with the "view" query parameter
+const (
+	itemViewLight = "light" // Without the full content
+	itemViewFull  = "full"
+)
+
+// Item sort fields selected with the "sort" query parameter
+const (
+	itemSortPublished = "published" // Items without a publication date are sorted by the creation date
+	itemSortCreated   = "created"
+	itemSortTitle     = "title"
+)
+
+// maxSortKey is the upper bound of the sort keys for the first page in descending order.
+// It sorts after any UTF-8 text in SQLite's binary collation, UTF-8 never has the byte 0xff.
+const maxSortKey = "\xff"
+
+// itemPageQuery selects a page of items and the sort they're listed in
+type itemPageQuery struct {
+	models.ListFeedItemPageParams
+	Sort       string
+	Descending bool
+}
+
+// itemSortKeys are the sort keys as the item pages compare them, each one is backed by an index ending with the id
+var itemSortKeys = map[string]string{
+	itemSortPublished: "CAST(COALESCE(fi.published, fi.created) AS TEXT)",
+	itemSortCreated:   "fi.created",
+	itemSortTitle:     "fi.title",
+}
+
+// ascendingItemPage turns the cursor conditions and the order of ListFeedItemPage around
+var ascendingItemPage = strings.NewReplacer(" <= CAST(", " >= CAST(", " < ?", " > ?", " DESC", " ASC")
+
+// sortedItemPages runs ListFeedItemPage in the sort of the page. The query is written for the newest published
+// first, the other sorts replace its sort key and direction, so the filters are written once and every sort
+// still reads the page in the order of its index.
+type sortedItemPages struct {
+	models.DBTX
+	sort       string
+	descending bool
+}
+
+func (db sortedItemPages) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	// The key is selected as stored for the cursor, the dates would be read as times otherwise
+	publishedKey, key := itemSortKeys[itemSortPublished], itemSortKeys[db.sort]
+	query = strings.Replace(query, publishedKey+" AS sort_key", "CAST("+key+" AS TEXT) AS sort_key", 1)
+	query = strings.ReplaceAll(query, publishedKey, key)
+	if !db.descending {
+		query = ascendingItemPage.Replace(query)
+	}
+	return db.DBTX.QueryContext(ctx, query, args...)
+}
+
+// ItemPage is a page of the items of a channel or of the timeline
+type ItemPage struct {
+	Items      []ChannelItem `json:"items"`
+	NextCursor null.String   `json:"next_cursor"` // Null on the last page
+}
+
+// ChannelItem is an item with its media attachments and the channels it's published in
+type ChannelItem struct {
+	models.ListFeedItemPageRow
+	Content          *string                `json:"content,omitempty"`           // Only in the full view
+	ExtractedContent *string                `json:"extracted_content,omitempty"` // Only in the full view
+	Attachments      []models.ItemEnclosure `json:"attachments"`
+	Channels         []ItemChannel          `json:"channels"`
+}
+
+// ItemChannel is a channel an item is published in
+type ItemChannel struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// ListTimelineItems handles GET requests to list a page of the items of all enabled channels, or of the channels
+// of a group and its subgroups or of a tag. An item of several channels is listed once.
+func (api *API) ListTimelineItems(w http.ResponseWriter, r *http.Request) {
+	params, err := parseItemFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	query := r.URL.Query()
+	if value := query.Get("group"); value != "" {
+		groupID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "group must be a group ID", http.StatusBadRequest)
+			return
+		}
+		if _, err := queries.GetGroup(ctx, groupID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "group not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		params.GroupID = sql.NullInt64{Int64: groupID, Valid: true}
+	}
+	if value := query.Get("tag"); value != "" {
+		tagID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			http.Error(w, "tag must be a tag ID", http.StatusBadRequest)
+			return
+		}
+		if _, err := queries.GetTag(ctx, tagID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "tag not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		params.TagID = sql.NullInt64{Int64: tagID, Valid: true}
+	}
+	api.writeItemPage(w, r, params)
+}
+
+// writeItemPage lists the page of items selected by the parameters. The next page starts
+// after the item the cursor of the response points to.
+func (api *API) writeItemPage(w http.ResponseWriter, r *http.Request, params itemPageQuery) {
+	view, err := parseItemView(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := params.Limit
+	// One more item tells whether there is a next page
+	params.Limit++
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	items, err := listItemPage(ctx, api.DB, params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var response ItemPage
+	if int64(len(items)) > limit {
+		items = items[:limit]
+		last := items[len(items)-1]
+		cursor := pageCursor{Sort: params.Sort, Descending: params.Descending, Key: last.SortKey, ID: last.ID}
+		response.NextCursor = null.StringFrom(cursor.encode())
+	}
+
+	itemIDs := make([]int64, 0, len(items))
+	for _, item := range items {
+		itemIDs = append(itemIDs, item.ID)
+	}
+	enclosures, err := queries.ListItemEnclosuresByItems(ctx, itemIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response.Items = withAttachments(items, enclosures)
+	channels, err := queries.ListItemChannels(ctx, itemIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	withChannels(response.Items, channels)
+	if view == itemViewFull {
+		contents, err := queries.ListFeedItemContentByIDs(ctx, itemIDs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		withContent(response.Items, contents)
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// listItemPage runs ListFeedItemPage in the sort of the page
+func listItemPage(ctx context.Context, db models.DBTX, page itemPageQuery) ([]models.ListFeedItemPageRow, error) {
+	sorted := sortedItemPages{DBTX: db, sort: page.Sort, descending: page.Descending}
+	return models.New(sorted).ListFeedItemPage(ctx, page.ListFeedItemPageParams)
+}
+
+// parseItemView reads the "view" query parameter, the light view is the default one
+func parseItemView(r *http.Request) (string, error) {
+	switch view := r.URL.Query().Get("view"); view {
+	case "", itemViewLight:
+		return itemViewLight, nil
+	case itemViewFull:
+		return itemViewFull, nil
+	default:
+		return "", fmt.Errorf("view must be %s or %s", itemViewLight, itemViewFull)
+	}
+}
+
+// parseItemFilter reads the filters, the sort order and the cursor of an item list from the query parameters.
+// Items in the trash are left out unless the deleted filter is set.
+func parseItemFilter(r *http.Request) (itemPageQuery, error) {
+	var params itemPageQuery
+	var err error
+	if params.Sort, params.Descending, err = parseSort(r, []string{itemSortPublished, itemSortCreated, itemSortTitle},
+		itemSortPublished, itemSortCreated); err != nil {
+		return params, err
+	}
+	cursor, err := parseCursor(r, params.Sort, params.Descending)
+	if err != nil {
+		return params, err
+	}
+	switch {
+	case cursor != nil:
+		params.CursorKey, params.CursorID = cursor.Key, cursor.ID
+	case params.Descending:
+		params.CursorKey, params.CursorID = maxSortKey, math.MaxInt64
+	default:
+		// Any key sorts after the empty one and any id after 0
+		params.CursorKey, params.CursorID = "", 0
+	}
+	if params.Limit, err = parseLimit(r); err != nil {
+		return params, err
+	}
+
+	query := r.URL.Query()
+	for name, filter := range map[string]*sql.NullBool{
+		"read":           &params.Read,
+		"starred":        &params.Starred,
+		"read_later":     &params.ReadLater,
+		"deleted":        &params.Deleted,
+		"has_enclosures": &params.HasEnclosures,
+	} {
+		if value := query.Get(name); value != "" {
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				return params, fmt.Errorf("%s must be true or false", name)
+			}
+			*filter = sql.NullBool{Bool: flag, Valid: true}
+		}
+	}
+	if !params.Deleted.Valid {
+		params.Deleted = sql.NullBool{Bool: false, Valid: true}
+	}
+	if params.PublishedFrom, err = parseDateFilter(r, "published_from"); err != nil {
+		return params, err
+	}
+	if params.PublishedTo, err = parseDateFilter(r, "published_to"); err != nil {
+		return params, err
+	}
+	if author := query.Get("author"); author != "" {
+		params.Author = sql.NullString{String: author, Valid: true}
+	}
+	return params, nil
+}
+
+// parseDateFilter reads a date query parameter in the format of SQLite's datetime(),
+// which normalizes the stored dates in the queries
+func parseDateFilter(r *http.Request, name string) (sql.NullString, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return sql.NullString{}, nil
+	}
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		date, err = time.Parse(time.RFC3339, value)
+	}
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("%s must be a RFC 3339 date-time or a YYYY-MM-DD date", name)
+	}
+	return sql.NullString{String: date.UTC().Format(time.DateTime), Valid: true}, nil
+}
+
+func withAttachments(items []models.ListFeedItemPageRow, enclosures []models.ItemEnclosure) []ChannelItem {
+	attachments := make(map[int64][]models.ItemEnclosure)
+	for _, enclosure := range enclosures {
+		attachments[enclosure.ItemID] = append(attachments[enclosure.ItemID], enclosure)
+	}
+	channelItems := make([]ChannelItem, 0, len(items))
+	for _, item := range items {
+		itemAttachments := attachments[item.ID]
+		if itemAttachments == nil {
+			itemAttachments = []models.ItemEnclosure{}
+		}
+		channelItems = append(channelItems, ChannelItem{ListFeedItemPageRow: item, Attachments: itemAttachments})
+	}
+	return channelItems
+}
+
+// groupItemChannels maps the items to their channels
+func groupItemChannels(channels []models.ListItemChannelsRow) map[int64][]ItemChannel {
+	itemChannels := make(map[int64][]ItemChannel)
+	for _, channel := range channels {
+		itemChannels[channel.ItemID] = append(itemChannels[channel.ItemID], ItemChannel{ID: channel.ChannelID, Title: channel.Title})
+	}
+	return itemChannels
+}
+
+func withChannels(items []ChannelItem, channels []models.ListItemChannelsRow) {
+	itemChannels := groupItemChannels(channels)
+	for i := range items {
+		items[i].Channels = itemChannels[items[i].ID]
+		if items[i].Channels == nil {
+			items[i].Channels = []ItemChannel{}
+		}
+	}
+}
+
+func withContent(items []ChannelItem, contents []models.ListFeedItemContentByIDsRow) {
+	content := make(map[int64]models.ListFeedItemContentByIDsRow, len(contents))
+	for _, row := range contents {
+		content[row.ID] = row
+	}
+	for i := range items {
+		row, ok := content[items[i].ID]
+		if !ok {
+			continue
+		}
+		items[i].Content = row.Content.Ptr()
+		items[i].ExtractedContent = row.ExtractedContent.Ptr()
+	}
+}
+
+// GetItemRaw returns the item description and content as the publisher sent them, before sanitization
+func (api *API) GetItemRaw(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	raw, err := queries.GetFeedItemRaw(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "item not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = json.NewEncoder(w).Encode(raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
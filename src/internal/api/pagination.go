@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// Sort directions selected with the "order" query parameter
+const (
+	orderAsc  = "asc"
+	orderDesc = "desc"
+)
+
+// parsePagination reads the "limit" and "offset" query parameters
+func parsePagination(r *http.Request) (limit int64, offset int64, err error) {
+	limit, err = parseLimit(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		offset, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// parseLimit reads the "limit" query parameter
+func parseLimit(r *http.Request) (int64, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, fmt.Errorf("limit must be an integer between 1 and %d", maxPageLimit)
+	}
+	return limit, nil
+}
+
+// parseSort reads the "sort" query parameter, which must be one of the allowed fields, the first one is the default.
+// The "order" query parameter defaults to descending for the fields in descByDefault.
+func parseSort(r *http.Request, allowed []string, descByDefault ...string) (sort string, descending bool, err error) {
+	query := r.URL.Query()
+	sort = query.Get("sort")
+	if sort == "" {
+		sort = allowed[0]
+	} else if !slices.Contains(allowed, sort) {
+		return "", false, fmt.Errorf("sort must be one of %s", strings.Join(allowed, ", "))
+	}
+	switch order := query.Get("order"); order {
+	case "":
+		descending = slices.Contains(descByDefault, sort)
+	case orderAsc:
+	case orderDesc:
+		descending = true
+	default:
+		return "", false, fmt.Errorf("order must be %s or %s", orderAsc, orderDesc)
+	}
+	return sort, descending, nil
+}
+
+// pageCursor points after the last item of a page. It's handed to clients as an opaque string
+// and is only valid for the sort it was created with.
+type pageCursor struct {
+	Sort       string `json:"sort"`
+	Descending bool   `json:"desc"`
+	Key        string `json:"key"` // Sort key of the last item
+	ID         int64  `json:"id"`
+}
+
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseCursor reads the "cursor" query parameter, nil means the first page
+func parseCursor(r *http.Request, sort string, descending bool) (*pageCursor, error) {
+	value := r.URL.Query().Get("cursor")
+	if value == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if cursor.Sort != sort || cursor.Descending != descending {
+		return nil, fmt.Errorf("the cursor was created for another sort order")
+	}
+	return &cursor, nil
+}
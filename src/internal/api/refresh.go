@@ -0,0 +1,145 @@
+package api
+
+import (
+	"FeedsCollector/internal/gatherer"
+	"FeedsCollector/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxRefreshTimeout limits how long a request waits for its fetches
+const maxRefreshTimeout = 5 * time.Minute
+
+// RefreshChannel handles POST requests to fetch a channel right away, even a disabled one
+func (api *API) RefreshChannel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, err = models.New(api.DB).GetFeedChannel(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "channel not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.refresh(w, r, []int64{id})
+}
+
+// RefreshGroup handles POST requests to fetch the enabled channels of a group and its subgroups right away
+func (api *API) RefreshGroup(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	if _, err := queries.GetGroup(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "group not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	channelIDs, err := queries.ListGroupChannelIDs(ctx, models.ListGroupChannelIDsParams{GroupID: id, EnabledOnly: true})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.refresh(w, r, channelIDs)
+}
+
+// RefreshAll handles POST requests to fetch all enabled channels right away
+func (api *API) RefreshAll(w http.ResponseWriter, r *http.Request) {
+	channelIDs, err := models.New(api.DB).ListFeedChannelIDs(r.Context(), true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.refresh(w, r, channelIDs)
+}
+
+// GetJob handles GET requests for the state of a refresh job
+func (api *API) GetJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	timeout, err := parseRefreshTimeout(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if api.Scheduler == nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	api.writeJob(w, r, id, timeout)
+}
+
+// refresh starts a job fetching the channels. The job is returned right away with 202 Accepted,
+// or once it's done with 200 OK if the request waits for it with the timeout parameter.
+func (api *API) refresh(w http.ResponseWriter, r *http.Request, channelIDs []int64) {
+	timeout, err := parseRefreshTimeout(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if api.Scheduler == nil {
+		http.Error(w, "the scheduler is not running", http.StatusServiceUnavailable)
+		return
+	}
+	jobID, err := api.Scheduler.Refresh(channelIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	api.writeJob(w, r, jobID, timeout)
+}
+
+func (api *API) writeJob(w http.ResponseWriter, r *http.Request, jobID int64, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	job, ok := api.Scheduler.WaitJob(ctx, jobID)
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	if job.Status != gatherer.JobDone {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	err := json.NewEncoder(w).Encode(job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseRefreshTimeout reads the "timeout" query parameter, e.g. "30s", zero means no waiting
+func parseRefreshTimeout(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("timeout")
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 || timeout > maxRefreshTimeout {
+		return 0, fmt.Errorf("timeout must be a duration between 0s and %v", maxRefreshTimeout)
+	}
+	return timeout, nil
+}
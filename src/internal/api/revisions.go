@@ -0,0 +1,143 @@
+package api
+
+import (
+	"FeedsCollector/internal/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+// ItemHistory lists the versions of an item from the first one we saw to the current one
+type ItemHistory struct {
+	ItemID    int64          `json:"item_id"`
+	Edited    bool           `json:"edited"`
+	Revisions []ItemRevision `json:"revisions"`
+}
+
+// ItemRevision is a version of an item with its changes since the previous version
+type ItemRevision struct {
+	Revision      int           `json:"revision"` // Starts with 1
+	Current       bool          `json:"current"`
+	Title         string        `json:"title"`
+	Description   null.String   `json:"description"`
+	Link          string        `json:"link"`
+	Content       null.String   `json:"content"`
+	Text          null.String   `json:"text"`
+	SourceUpdated null.Time     `json:"source_updated"`
+	ValidFrom     time.Time     `json:"valid_from"`
+	ValidTo       null.Time     `json:"valid_to"` // Null for the current version
+	Changes       []FieldChange `json:"changes"`
+}
+
+// FieldChange is a field edited by the publisher, text fields come with a word-level diff
+type FieldChange struct {
+	Field string   `json:"field"`
+	Old   string   `json:"old"`
+	New   string   `json:"new"`
+	Diff  []DiffOp `json:"diff,omitempty"`
+}
+
+// ListItemRevisions handles GET requests for the edit history of an item
+func (api *API) ListItemRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	item, err := queries.GetFeedItemVersion(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "item not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	revisions, err := queries.ListFeedItemRevision(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = json.NewEncoder(w).Encode(itemHistory(item, revisions))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func itemHistory(item models.GetFeedItemVersionRow, revisions []models.FeedItemRevision) ItemHistory {
+	history := ItemHistory{ItemID: item.ID, Edited: item.Edited, Revisions: make([]ItemRevision, 0, len(revisions)+1)}
+	for _, revision := range revisions {
+		history.Revisions = append(history.Revisions, ItemRevision{
+			Title:         revision.Title,
+			Description:   revision.Description,
+			Link:          revision.Link,
+			Content:       revision.Content,
+			Text:          revision.Text,
+			SourceUpdated: revision.SourceUpdated,
+			ValidFrom:     revision.ValidFrom,
+			ValidTo:       null.TimeFrom(revision.Replaced),
+		})
+	}
+	validFrom := item.Created
+	if item.Updated.Valid {
+		validFrom = item.Updated.Time
+	}
+	history.Revisions = append(history.Revisions, ItemRevision{
+		Current:       true,
+		Title:         item.Title,
+		Description:   item.Description,
+		Link:          item.Link,
+		Content:       item.Content,
+		Text:          item.Text,
+		SourceUpdated: item.SourceUpdated,
+		ValidFrom:     validFrom,
+	})
+
+	for i := range history.Revisions {
+		history.Revisions[i].Revision = i + 1
+		history.Revisions[i].Changes = []FieldChange{}
+		if i > 0 {
+			history.Revisions[i].Changes = revisionChanges(&history.Revisions[i-1], &history.Revisions[i])
+		}
+	}
+	return history
+}
+
+// revisionChanges compares two consecutive versions of an item
+func revisionChanges(previous, next *ItemRevision) []FieldChange {
+	changes := []FieldChange{}
+	compare := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new, Diff: diffWords(old, new)})
+		}
+	}
+	compare("title", previous.Title, next.Title)
+	compare("link", previous.Link, next.Link)
+	compare("description", previous.Description.String, next.Description.String)
+	compare("content", previous.Content.String, next.Content.String)
+	compare("text", previous.Text.String, next.Text.String)
+	if !previous.SourceUpdated.Time.Equal(next.SourceUpdated.Time) {
+		changes = append(changes, FieldChange{
+			Field: "source_updated",
+			Old:   formatNullTime(previous.SourceUpdated),
+			New:   formatNullTime(next.SourceUpdated),
+		})
+	}
+	return changes
+}
+
+func formatNullTime(value null.Time) string {
+	if !value.Valid {
+		return ""
+	}
+	return value.Time.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"FeedsCollector/internal/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// FetchRuns lists the fetch runs of the scheduler, newest first, and details the latest one
+type FetchRuns struct {
+	Latest *FetchRunDetails `json:"latest"` // Null if nothing has been fetched yet
+	Runs   []FetchRun       `json:"runs"`
+}
+
+// FetchRun is the summary of the fetches from the moment the scheduler starts fetching while idle
+// until all fetches are done
+type FetchRun struct {
+	models.FetchRun
+	InProgress bool  `json:"in_progress"`
+	DurationMs int64 `json:"duration_ms"` // Up to now for a run in progress
+}
+
+// FetchRunDetails is a fetch run with the outcome of every fetch, the failed ones first
+type FetchRunDetails struct {
+	FetchRun
+	Channels []models.ListFetchRunChannelsRow `json:"channels"`
+}
+
+// ListFetchRuns handles GET requests for the fetch runs
+func (api *API) ListFetchRuns(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	runs, err := queries.ListFetchRun(ctx, models.ListFetchRunParams{Limit: limit, Offset: offset})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := FetchRuns{Runs: make([]FetchRun, 0, len(runs))}
+	for _, run := range runs {
+		response.Runs = append(response.Runs, fetchRunSummary(run))
+	}
+
+	latest := runs
+	if offset > 0 {
+		latest, err = queries.ListFetchRun(ctx, models.ListFetchRunParams{Limit: 1})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if len(latest) > 0 {
+		channels, err := queries.ListFetchRunChannels(ctx, sql.NullInt64{Int64: latest[0].ID, Valid: true})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if channels == nil {
+			channels = []models.ListFetchRunChannelsRow{}
+		}
+		response.Latest = &FetchRunDetails{FetchRun: fetchRunSummary(latest[0]), Channels: channels}
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func fetchRunSummary(run models.FetchRun) FetchRun {
+	summary := FetchRun{FetchRun: run, InProgress: !run.Finished.Valid}
+	finished := time.Now()
+	if run.Finished.Valid {
+		finished = run.Finished.Time
+	}
+	summary.DurationMs = max(finished.Sub(run.Started).Milliseconds(), 0)
+	return summary
+}
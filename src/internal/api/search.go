@@ -0,0 +1,118 @@
+package api
+
+import (
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"database/sql"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/guregu/null"
+)
+
+// SearchResult is an item matching a search, the matched terms of the title and the snippet are marked with <mark>
+type SearchResult struct {
+	ID        int64         `json:"id"`
+	Title     string        `json:"title"`   // Escaped HTML
+	Snippet   string        `json:"snippet"` // Escaped HTML, the part of the text with the most matches
+	Link      string        `json:"link"`
+	Author    *string       `json:"author"`
+	Published null.Time     `json:"published"`
+	Score     float64       `json:"score"` // BM25 rank, lower is better
+	Channels  []ItemChannel `json:"channels"`
+}
+
+// matchMarks replaces the markers of the matched terms set by the search query
+var matchMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// SearchItems handles GET requests to search the items with an FTS5 query: words, "phrases", prefix* matches,
+// AND, OR and NOT operators and column filters such as title:word. Deleted items are left out.
+func (api *API) SearchItems(w http.ResponseWriter, r *http.Request) {
+	if !utils.SearchEnabled {
+		http.Error(w, "search is not available, the server is built without the sqlite_fts5 tag", http.StatusNotImplemented)
+		return
+	}
+	query := r.URL.Query()
+	params := models.SearchFeedItemParams{Query: strings.TrimSpace(query.Get("q"))}
+	if params.Query == "" {
+		http.Error(w, "q must be a search query", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if params.Limit, params.Offset, err = parsePagination(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for name, filter := range map[string]*sql.NullInt64{"channel": &params.ChannelID, "group": &params.GroupID} {
+		if value := query.Get(name); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				http.Error(w, name+" must be an ID", http.StatusBadRequest)
+				return
+			}
+			*filter = sql.NullInt64{Int64: id, Valid: true}
+		}
+	}
+	if params.PublishedFrom, err = parseDateFilter(r, "published_from"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if params.PublishedTo, err = parseDateFilter(r, "published_to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	rows, err := queries.SearchFeedItem(ctx, params)
+	if err != nil {
+		if isSearchQueryError(err) {
+			http.Error(w, "invalid search query: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	itemIDs := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		itemIDs = append(itemIDs, row.ID)
+	}
+	channels, err := queries.ListItemChannels(ctx, itemIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	itemChannels := groupItemChannels(channels)
+
+	results := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		result := SearchResult{
+			ID:        row.ID,
+			Title:     matchMarks.Replace(html.EscapeString(row.TitleHighlight)),
+			Snippet:   matchMarks.Replace(html.EscapeString(row.Snippet)),
+			Link:      row.Link,
+			Author:    row.Author,
+			Published: row.Published,
+			Score:     row.Score,
+			Channels:  itemChannels[row.ID],
+		}
+		if result.Channels == nil {
+			result.Channels = []ItemChannel{}
+		}
+		results = append(results, result)
+	}
+	err = json.NewEncoder(w).Encode(results)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// isSearchQueryError tells whether FTS5 rejected the query, e.g. for unbalanced quotes or an unknown column
+func isSearchQueryError(err error) bool {
+	message := err.Error()
+	return strings.HasPrefix(message, "fts5:") || strings.HasPrefix(message, "no such column:") ||
+		strings.HasPrefix(message, "unknown special query:") || message == "unterminated string"
+}
@@ -0,0 +1,219 @@
+//go:build sqlite_fts5
+
+package api
+
+import (
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+func TestSearchItems(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	queries := models.New(db)
+	// Items stored before the search is enabled are indexed by its migration
+	_, err := db.Exec(`DROP TRIGGER item_search_insert; DROP TRIGGER item_search_delete; DROP TRIGGER item_search_update;
+DROP TABLE item_search; DROP TABLE search_schema_migrations`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.Exec("INSERT INTO feed_group (name) VALUES ('Languages')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupID, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	channelIDs := make(map[string]int64)
+	for _, name := range []string{"rust", "golang"} {
+		channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+			Title:       "Channel " + name,
+			Description: "A searched channel",
+			Link:        "http://" + name + ".example.com/rss",
+			Host:        name + ".example.com",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		channelIDs[name] = channel.ID
+	}
+	if err := queries.AddChannelToGroup(ctx, models.AddChannelToGroupParams{GroupID: groupID, ChannelID: channelIDs["rust"]}); err != nil {
+		t.Fatal(err)
+	}
+	itemIDs := make(map[string]int64)
+	createItem := func(guid, channel, title, text string, published time.Time) {
+		t.Helper()
+		item, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+			Guid:      null.StringFrom(guid),
+			Title:     title,
+			Link:      "http://example.com/" + guid,
+			Text:      null.StringFrom(text),
+			Published: null.TimeFrom(published),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channelIDs[channel], ItemID: item.ID}); err != nil {
+			t.Fatal(err)
+		}
+		itemIDs[guid] = item.ID
+	}
+	createItem("release", "rust", "Rust compiler release", "The new compiler improves incremental builds", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	if err := utils.MigrateSearch(db, "file://../../db/search_migrations"); err != nil {
+		t.Fatal(err)
+	}
+	// Items stored afterwards are indexed by the triggers
+	createItem("generics", "golang", "Go generics in practice", "Type parameters <script>alert(1)</script>", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	createItem("weekly", "golang", "Compilers weekly", "A weekly digest", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	createItem("digest", "rust", "Release notes", "Mostly about the borrow checker, the compiler got faster", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	createItem("deleted", "rust", "Compiler bug", "A compiler crash", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if _, err := db.Exec("UPDATE feed_item SET deleted = 1 WHERE id = ?", itemIDs["deleted"]); err != nil {
+		t.Fatal(err)
+	}
+
+	apiInstance := NewAPI(db, testFetcher, nil)
+	router := mux.NewRouter()
+	apiInstance.RegisterRoutes(router)
+	search := func(query string) (int, []SearchResult) {
+		t.Helper()
+		req, err := http.NewRequest("GET", "/search?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var results []SearchResult
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&results); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr.Code, results
+	}
+	guids := func(results []SearchResult) string {
+		byID := make(map[int64]string)
+		for guid, id := range itemIDs {
+			byID[id] = guid
+		}
+		var guids []string
+		for _, result := range results {
+			guids = append(guids, byID[result.ID])
+		}
+		return strings.Join(guids, ",")
+	}
+
+	for query, want := range map[string]string{
+		"compiler":                          "release,digest", // The title match ranks first
+		`"incremental builds"`:              "release",
+		"compil*":                           "weekly,release,digest",
+		"compiler NOT rust":                 "digest",
+		"rust OR generics":                  "release,generics",
+		"title:release":                     "release,digest",
+		"compil* channel=golang":            "weekly",
+		"compil* group=languages":           "release,digest",
+		"compil* published_from=2024-04-15": "weekly",
+		"compil* published_to=2024-03-01":   "digest",
+	} {
+		values := url.Values{}
+		words := strings.Fields(query)
+		values.Set("q", words[0])
+		for _, word := range words[1:] {
+			name, value, ok := strings.Cut(word, "=")
+			switch {
+			case !ok:
+				values.Set("q", values.Get("q")+" "+word)
+			case name == "channel":
+				values.Set(name, fmt.Sprint(channelIDs[value]))
+			case name == "group":
+				values.Set(name, fmt.Sprint(groupID))
+			default:
+				values.Set(name, value)
+			}
+		}
+		status, results := search(values.Encode())
+		if status != http.StatusOK {
+			t.Fatalf("%q: handler returned wrong status code: got %v want %v", query, status, http.StatusOK)
+		}
+		got := guids(results)
+		if query == "compil*" || strings.HasPrefix(query, "rust OR") {
+			// The ties of the ranking are not part of the test
+			got = strings.Join(sortedFields(got), ",")
+			want = strings.Join(sortedFields(want), ",")
+		}
+		if got != want {
+			t.Errorf("%q: got items %v want %v", query, got, want)
+		}
+	}
+
+	_, results := search("q=alert")
+	if len(results) != 1 || results[0].Snippet != "Type parameters &lt;script&gt;<mark>alert</mark>(1)&lt;/script&gt;" {
+		t.Errorf("Expected the escaped snippet with the match marked, got %+v", results)
+	}
+	_, results = search("q=generics")
+	if len(results) != 1 || results[0].Title != "Go <mark>generics</mark> in practice" ||
+		len(results[0].Channels) != 1 || results[0].Channels[0].ID != channelIDs["golang"] {
+		t.Errorf("Expected the title with the match marked and the channel, got %+v", results)
+	}
+
+	// The description and the article are indexed besides the plain text
+	summary, err := queries.CreateFeedItem(ctx, models.CreateFeedItemParams{
+		Guid:        null.StringFrom("summary"),
+		Title:       "Weekly summary",
+		Link:        "http://example.com/summary",
+		Description: null.StringFrom("<p>A look at the linker</p>"),
+		Text:        null.StringFrom("The full story"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channelIDs["golang"], ItemID: summary.ID}); err != nil {
+		t.Fatal(err)
+	}
+	itemIDs["summary"] = summary.ID
+	if _, results := search("q=linker"); guids(results) != "summary" {
+		t.Errorf("Expected the item found by its description, got %v", guids(results))
+	}
+	if _, err := db.Exec("UPDATE feed_item SET extracted_content = '<p>Escape analysis explained</p>' WHERE id = ?", summary.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, results := search("q=escape"); guids(results) != "summary" {
+		t.Errorf("Expected the item found by its extracted article, got %v", guids(results))
+	}
+
+	if _, err := db.Exec("UPDATE feed_item SET title = 'Borrow checker internals' WHERE id = ?", itemIDs["digest"]); err != nil {
+		t.Fatal(err)
+	}
+	if _, results := search("q=internals"); guids(results) != "digest" {
+		t.Errorf("Expected the updated title to be indexed, got %v", guids(results))
+	}
+	if _, results := search("q=release"); guids(results) != "release" {
+		t.Errorf("Expected the old title to be removed from the index, got %v", guids(results))
+	}
+
+	for _, query := range []string{"", "q=%22unbalanced", "q=nowhere:word", "q=word&group=languages"} {
+		if status, _ := search(query); status != http.StatusBadRequest {
+			t.Errorf("%q: handler returned wrong status code: got %v want %v", query, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func sortedFields(list string) []string {
+	fields := strings.Split(list, ",")
+	slices.Sort(fields)
+	return fields
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"FeedsCollector/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+// MarkReadParams limits the items marked as read, at least one of the limits is required so that
+// the items which arrive after the client loaded its list stay unread
+type MarkReadParams struct {
+	MaxItemID null.Int  `json:"max_item_id"` // The newest item the client has seen, the items stored after it stay unread
+	Before    null.Time `json:"before"`      // Only the items published before
+}
+
+// MarkReadResult is the number of items marked as read
+type MarkReadResult struct {
+	Marked int64 `json:"marked"`
+}
+
+// Counters are the numbers of items in each state, deleted items are not counted
+type Counters struct {
+	Unread    int64                               `json:"unread"`
+	Starred   int64                               `json:"starred"`
+	ReadLater int64                               `json:"read_later"`
+	Channels  []models.ListChannelUnreadCountsRow `json:"channels"`
+	Groups    []models.ListGroupUnreadCountsRow   `json:"groups"` // With the items of the subgroups
+}
+
+// MarkItemRead handles PUT requests to mark an item as read
+func (api *API) MarkItemRead(w http.ResponseWriter, r *http.Request) {
+	api.setItemState(w, r, func(ctx context.Context, queries *models.Queries, id int64) (int64, error) {
+		return queries.SetFeedItemRead(ctx, models.SetFeedItemReadParams{Read: true, ID: id})
+	})
+}
+
+// MarkItemUnread handles DELETE requests to mark an item as unread
+func (api *API) MarkItemUnread(w http.ResponseWriter, r *http.Request) {
+	api.setItemState(w, r, func(ctx context.Context, queries *models.Queries, id int64) (int64, error) {
+		return queries.SetFeedItemRead(ctx, models.SetFeedItemReadParams{Read: false, ID: id})
+	})
+}
+
+// StarItem handles PUT requests to star an item
+func (api *API) StarItem(w http.ResponseWriter, r *http.Request) {
+	api.setItemState(w, r, func(ctx context.Context, queries *models.Queries, id int64) (int64, error) {
+		return queries.SetFeedItemStarred(ctx, models.SetFeedItemStarredParams{Starred: true, ID: id})
+	})
+}
+
+// UnstarItem handles DELETE requests to unstar an item
+func (api *API) UnstarItem(w http.ResponseWriter, r *http.Request) {
+	api.setItemState(w, r, func(ctx context.Context, queries *models.Queries, id int64) (int64, error) {
+		return queries.SetFeedItemStarred(ctx, models.SetFeedItemStarredParams{Starred: false, ID: id})
+	})
+}
+
+// SaveItemForLater handles PUT requests to save an item to read later
+func (api *API) SaveItemForLater(w http.ResponseWriter, r *http.Request) {
+	api.setItemState(w, r, func(ctx context.Context, queries *models.Queries, id int64) (int64, error) {
+		return queries.SetFeedItemReadLater(ctx, models.SetFeedItemReadLaterParams{ReadLater: true, ID: id})
+	})
+}
+
+// RemoveItemFromLater handles DELETE requests to remove an item from the items to read later
+func (api *API) RemoveItemFromLater(w http.ResponseWriter, r *http.Request) {
+	api.setItemState(w, r, func(ctx context.Context, queries *models.Queries, id int64) (int64, error) {
+		return queries.SetFeedItemReadLater(ctx, models.SetFeedItemReadLaterParams{ReadLater: false, ID: id})
+	})
+}
+
+// setItemState updates the item with the query, which returns the number of updated items
+func (api *API) setItemState(w http.ResponseWriter, r *http.Request,
+	update func(ctx context.Context, queries *models.Queries, id int64) (int64, error)) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updated, err := update(r.Context(), models.New(api.DB), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if updated == 0 {
+		http.Error(w, "item not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkChannelRead handles POST requests to mark the items of a channel as read
+func (api *API) MarkChannelRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := models.New(api.DB).GetFeedChannel(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "channel not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.markRead(w, r, []int64{id})
+}
+
+// MarkGroupRead handles POST requests to mark the items of the channels of a group and its subgroups as read
+func (api *API) MarkGroupRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	if _, err := queries.GetGroup(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "group not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	channelIDs, err := queries.ListGroupChannelIDs(ctx, models.ListGroupChannelIDsParams{GroupID: id, EnabledOnly: false})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.markRead(w, r, channelIDs)
+}
+
+// MarkAllRead handles POST requests to mark the items of all channels as read
+func (api *API) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	channelIDs, err := models.New(api.DB).ListFeedChannelIDs(r.Context(), false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.markRead(w, r, channelIDs)
+}
+
+func (api *API) markRead(w http.ResponseWriter, r *http.Request, channelIDs []int64) {
+	var params MarkReadParams
+	// An empty body is missing the limits like an empty object
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !params.MaxItemID.Valid && !params.Before.Valid {
+		http.Error(w, "max_item_id or before is required", http.StatusBadRequest)
+		return
+	}
+	args := models.MarkFeedItemsReadParams{ChannelIds: channelIDs, MaxItemID: params.MaxItemID.NullInt64}
+	if params.Before.Valid {
+		// The format of SQLite's datetime(), which normalizes the stored dates in the query
+		args.Before = sql.NullString{String: params.Before.Time.UTC().Format(time.DateTime), Valid: true}
+	}
+	marked, err := models.New(api.DB).MarkFeedItemsRead(r.Context(), args)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = json.NewEncoder(w).Encode(MarkReadResult{Marked: marked})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetCounters handles GET requests for the numbers of unread, starred and saved items,
+// and the unread items of each channel and group
+func (api *API) GetCounters(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	totals, err := queries.GetItemCounters(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	counters := Counters{Unread: totals.Unread, Starred: totals.Starred, ReadLater: totals.ReadLater}
+	if counters.Channels, err = queries.ListChannelUnreadCounts(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if counters.Groups, err = queries.ListGroupUnreadCounts(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if counters.Channels == nil {
+		counters.Channels = []models.ListChannelUnreadCountsRow{}
+	}
+	if counters.Groups == nil {
+		counters.Groups = []models.ListGroupUnreadCountsRow{}
+	}
+	err = json.NewEncoder(w).Encode(counters)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"FeedsCollector/internal/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// defaultStoryMinItems hides the stories covered by a single item
+const defaultStoryMinItems = 2
+
+// Story is a cluster of near-duplicate items covering the same event
+type Story struct {
+	ID        int64          `json:"id"`
+	Title     string         `json:"title"`
+	ItemCount int64          `json:"item_count"`
+	Created   time.Time      `json:"created"`
+	Updated   time.Time      `json:"updated"`
+	Items     []StoryItem    `json:"items"`
+	Channels  []StoryChannel `json:"channels"`
+}
+
+// StoryItem is an item of a story with the channels publishing it
+type StoryItem struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Link      string    `json:"link"`
+	Published null.Time `json:"published"`
+	Channels  []int64   `json:"channels"`
+}
+
+// StoryChannel is a channel covering the story
+type StoryChannel struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// ListStories handles GET requests for the stories, the recently updated ones first
+func (api *API) ListStories(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	minItems := int64(defaultStoryMinItems)
+	if value := r.URL.Query().Get("min_items"); value != "" {
+		minItems, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || minItems < 1 {
+			http.Error(w, "min_items must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	clusters, err := queries.ListStoryCluster(ctx, models.ListStoryClusterParams{
+		MinItems: minItems,
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stories := make([]Story, 0, len(clusters))
+	if len(clusters) > 0 {
+		clusterIDs := make([]null.Int, 0, len(clusters))
+		for _, cluster := range clusters {
+			clusterIDs = append(clusterIDs, null.IntFrom(cluster.ID))
+		}
+		items, err := queries.ListStoryClusterItems(ctx, clusterIDs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		stories = storiesWithItems(clusters, items)
+	}
+	err = json.NewEncoder(w).Encode(stories)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// storiesWithItems groups the item rows, one per item and channel, by their stories
+func storiesWithItems(clusters []models.StoryCluster, rows []models.ListStoryClusterItemsRow) []Story {
+	stories := make([]Story, 0, len(clusters))
+	index := make(map[int64]int, len(clusters))
+	for _, cluster := range clusters {
+		index[cluster.ID] = len(stories)
+		stories = append(stories, Story{
+			ID:        cluster.ID,
+			Title:     cluster.Title,
+			ItemCount: cluster.ItemCount,
+			Created:   cluster.Created,
+			Updated:   cluster.Updated,
+			Items:     []StoryItem{},
+			Channels:  []StoryChannel{},
+		})
+	}
+	seenChannels := make(map[[2]int64]bool)
+	for _, row := range rows {
+		i, ok := index[row.ClusterID.Int64]
+		if !ok {
+			continue
+		}
+		story := &stories[i]
+		// Rows of an item come one after another
+		if n := len(story.Items); n == 0 || story.Items[n-1].ID != row.ID {
+			story.Items = append(story.Items, StoryItem{ID: row.ID, Title: row.Title, Link: row.Link, Published: row.Published})
+		}
+		item := &story.Items[len(story.Items)-1]
+		item.Channels = append(item.Channels, row.ChannelID)
+		if key := [2]int64{story.ID, row.ChannelID}; !seenChannels[key] {
+			seenChannels[key] = true
+			story.Channels = append(story.Channels, StoryChannel{ID: row.ChannelID, Title: row.ChannelTitle})
+		}
+	}
+	return stories
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"FeedsCollector/internal/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Trash holds the deleted channels and items until they are restored or purged after trash.retention
+type Trash struct {
+	Channels []models.ListTrashedFeedChannelRow `json:"channels"`
+	Items    []TrashedItem                      `json:"items"` // A page of the items, the last deleted first
+}
+
+// TrashedItem is an item in the trash with the channels it's restored to
+type TrashedItem struct {
+	models.ListTrashedFeedItemRow
+	Channels []ItemChannel `json:"channels"`
+}
+
+// ListTrash handles GET requests to list the channels and a page of the items in the trash
+func (api *API) ListTrash(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	channels, err := queries.ListTrashedFeedChannel(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items, err := queries.ListTrashedFeedItem(ctx, models.ListTrashedFeedItemParams{Limit: limit, Offset: offset})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	trash := Trash{Channels: channels, Items: make([]TrashedItem, 0, len(items))}
+	if trash.Channels == nil {
+		trash.Channels = []models.ListTrashedFeedChannelRow{}
+	}
+	if len(items) > 0 {
+		itemIDs := make([]int64, len(items))
+		for i, item := range items {
+			itemIDs[i] = item.ID
+		}
+		itemChannels, err := queries.ListItemChannels(ctx, itemIDs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		channelsByItem := groupItemChannels(itemChannels)
+		for _, item := range items {
+			trashed := TrashedItem{ListTrashedFeedItemRow: item, Channels: channelsByItem[item.ID]}
+			if trashed.Channels == nil {
+				trashed.Channels = []ItemChannel{}
+			}
+			trash.Items = append(trash.Items, trashed)
+		}
+	}
+	err = json.NewEncoder(w).Encode(trash)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RestoreItem handles POST requests to take an item out of the trash
+func (api *API) RestoreItem(w http.ResponseWriter, r *http.Request) {
+	api.setItemState(w, r, func(ctx context.Context, queries *models.Queries, id int64) (int64, error) {
+		restored, err := queries.RestoreFeedItem(ctx, id)
+		if err != nil || restored == 0 {
+			return restored, err
+		}
+		return restored, queries.RecountItemStoryCluster(ctx, id)
+	})
+}
+
+// RestoreChannel handles POST requests to take a channel out of the trash, it's fetched again if it's enabled
+func (api *API) RestoreChannel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	queries := models.New(api.DB)
+	restored, err := queries.RestoreFeedChannel(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if restored == 0 {
+		http.Error(w, "channel not found in the trash", http.StatusNotFound)
+		return
+	}
+	if err := queries.RecountStoryClusters(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,11 +1,18 @@
 package api
 
 import (
+	"FeedsCollector/internal/utils"
+	"fmt"
 	"net/http"
 
+	"github.com/andybalholm/cascadia"
 	"github.com/go-playground/validator/v10"
+	"github.com/guregu/null"
 )
 
+// maxUpdateInterval is the longest update interval of a channel in minutes (a week)
+const maxUpdateInterval = 7 * 24 * 60
+
 var validate *validator.Validate
 
 func init() {
@@ -20,3 +27,33 @@ func ValidateStruct(w http.ResponseWriter, v interface{}) error {
 	}
 	return nil
 }
+
+func ValidateUpdateInterval(w http.ResponseWriter, interval null.Int) error {
+	if interval.Valid && (interval.Int64 < 1 || interval.Int64 > maxUpdateInterval) {
+		err := fmt.Errorf("update_interval must be between 1 and %d minutes", maxUpdateInterval)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+	return nil
+}
+
+func ValidateProxy(w http.ResponseWriter, proxy null.String) error {
+	if proxy.Valid && proxy.String != "" {
+		if _, err := utils.ParseProxyURL(proxy.String); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateContentSelector(w http.ResponseWriter, selector null.String) error {
+	if selector.Valid && selector.String != "" {
+		if _, err := cascadia.Compile(selector.String); err != nil {
+			err = fmt.Errorf("invalid content_selector: %w", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return err
+		}
+	}
+	return nil
+}
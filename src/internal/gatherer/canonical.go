@@ -0,0 +1,135 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal/models"
+	"context"
+	"database/sql"
+	"net/url"
+	"strings"
+
+	"github.com/guregu/null"
+)
+
+// Items read by a step of the canonical link backfill
+const backfillBatchSize = 500
+
+// trackingParams are query parameters added by newsletters, ad networks and analytics,
+// they never change the page. Parameters starting with "utm_" are removed as well.
+var trackingParams = map[string]bool{
+	"fbclid":      true,
+	"gclid":       true,
+	"dclid":       true,
+	"msclkid":     true,
+	"yclid":       true,
+	"igshid":      true,
+	"mc_cid":      true,
+	"mc_eid":      true,
+	"_ga":         true,
+	"_gl":         true,
+	"_hsenc":      true,
+	"_hsmi":       true,
+	"mkt_tok":     true,
+	"oly_anon_id": true,
+	"oly_enc_id":  true,
+	"vero_id":     true,
+	"wt_mc":       true,
+	"ref_src":     true,
+	"cmpid":       true,
+	"ncid":        true,
+	"sr_share":    true,
+	"spm":         true,
+}
+
+// canonicalURL normalizes the link for deduplication: the scheme and host are lowercased, default
+// ports, fragments, tracking parameters and trailing slashes are removed and the parameters are sorted.
+// Links which are not absolute http(s) URLs are only trimmed.
+func canonicalURL(link string) string {
+	link = strings.TrimSpace(link)
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return link
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if port := parsed.Port(); (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		parsed.Host = parsed.Hostname()
+	}
+	parsed.User = nil
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+
+	if len(parsed.Path) > 1 {
+		parsed.Path = strings.TrimRight(parsed.Path, "/")
+		parsed.RawPath = ""
+	}
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+
+	query := parsed.Query()
+	for param := range query {
+		name := strings.ToLower(param)
+		if strings.HasPrefix(name, "utm_") || trackingParams[name] {
+			query.Del(param)
+		}
+	}
+	// Encode sorts the parameters by name
+	parsed.RawQuery = query.Encode()
+	parsed.ForceQuery = false
+	return parsed.String()
+}
+
+// isSiteRoot reports links to the home page, some feeds link every item there
+// so such links don't identify items
+func isSiteRoot(canonicalLink string) bool {
+	parsed, err := url.Parse(canonicalLink)
+	return err == nil && parsed.Path == "/" && parsed.RawQuery == ""
+}
+
+// BackfillCanonicalLinks canonicalizes the canonical links of the items stored before migration 14,
+// which copied their links as they were. The links set from a page's rel=canonical differ from
+// the item links and are kept. It returns the number of items updated.
+func BackfillCanonicalLinks(ctx context.Context, db *sql.DB) (int, error) {
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		// Does nothing once the transaction is committed
+		_ = tx.Rollback()
+	}()
+	queries := models.New(db).WithTx(tx)
+	updated := 0
+	var afterID int64
+	for {
+		items, err := queries.ListFeedItemLinksAsCanonical(ctx, models.ListFeedItemLinksAsCanonicalParams{
+			AfterID: afterID,
+			Limit:   backfillBatchSize,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			afterID = item.ID
+			canonicalLink := canonicalURL(item.Link)
+			if canonicalLink == item.Link {
+				continue
+			}
+			err := queries.UpdateFeedItemCanonicalLink(ctx, models.UpdateFeedItemCanonicalLinkParams{
+				CanonicalLink: null.StringFrom(canonicalLink),
+				ID:            item.ID,
+			})
+			if err != nil {
+				return 0, err
+			}
+			updated++
+		}
+	}
+	return updated, tx.Commit()
+}
@@ -0,0 +1,228 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	maxIdleConnsPerHost = 4
+	idleConnTimeout     = 90 * time.Second
+)
+
+// Fetcher is the HTTP client shared by all fetches. Channels with the same proxy and TLS settings
+// share a transport, so connections to a host are reused between fetches.
+type Fetcher struct {
+	config     *utils.Config
+	rootCAs    *x509.CertPool
+	mu         sync.Mutex
+	transports map[transportKey]*http.Transport
+	pages      *hostGate // Politeness limits of the page requests
+}
+
+type transportKey struct {
+	proxy    string
+	insecure bool
+}
+
+func NewFetcher(config *utils.Config) (*Fetcher, error) {
+	fetcher := &Fetcher{
+		config:     config,
+		transports: make(map[transportKey]*http.Transport),
+		pages:      newHostGate(config),
+	}
+	if config.HTTPClient.CABundle != "" {
+		rootCAs, err := loadCABundle(config.HTTPClient.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		fetcher.rootCAs = rootCAs
+	}
+	return fetcher, nil
+}
+
+// loadCABundle adds the certificates from the PEM file to the system ones
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("could not read http_client.ca_bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in http_client.ca_bundle %s", path)
+	}
+	return pool, nil
+}
+
+// Get requests the feed of the channel using its proxy and TLS settings.
+// It also returns the redirects followed to get the response.
+func (f *Fetcher) Get(ctx context.Context, feedChannelInfo *models.GetFeedChannelForFetchRow, header http.Header) (*http.Response, []redirectHop, error) {
+	return f.get(ctx, feedChannelInfo.Link, f.channelKey(feedChannelInfo), header)
+}
+
+// GetPage requests a page of the channel's site, such as an item page, with the channel settings.
+// It waits until the host of the page accepts a request within the politeness limits.
+func (f *Fetcher) GetPage(ctx context.Context, feedChannelInfo *models.GetFeedChannelForFetchRow, link string) (*http.Response, []redirectHop, error) {
+	release, err := f.pages.acquire(ctx, hostKey(link, feedChannelInfo.Host))
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, redirects, err := f.get(ctx, link, f.channelKey(feedChannelInfo), make(http.Header))
+	if err != nil {
+		release()
+		return nil, redirects, err
+	}
+	// The slot of the host is taken until the page is read
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, redirects, nil
+}
+
+// releasingBody frees the slot of the host when the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	b.once.Do(b.release)
+	return b.ReadCloser.Close()
+}
+
+// GetURL requests a page which doesn't belong to any channel yet using the global settings
+func (f *Fetcher) GetURL(ctx context.Context, link string) (*http.Response, []redirectHop, error) {
+	return f.get(ctx, link, transportKey{proxy: f.config.HTTPClient.Proxy}, make(http.Header))
+}
+
+// channelKey selects the transport of the channel, its proxy overrides the global one
+func (f *Fetcher) channelKey(feedChannelInfo *models.GetFeedChannelForFetchRow) transportKey {
+	key := transportKey{proxy: f.config.HTTPClient.Proxy, insecure: feedChannelInfo.TlsInsecure}
+	if feedChannelInfo.Proxy.Valid && feedChannelInfo.Proxy.String != "" {
+		key.proxy = feedChannelInfo.Proxy.String
+	}
+	return key
+}
+
+func (f *Fetcher) get(ctx context.Context, link string, key transportKey, header http.Header) (*http.Response, []redirectHop, error) {
+	transport, err := f.transport(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	var redirects []redirectHop
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   f.config.HTTPClient.ConnectTimeout + f.config.HTTPClient.ReadTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			redirects = append(redirects, redirectHop{statusCode: req.Response.StatusCode, url: req.URL.String()})
+			if len(via) > f.config.Redirects.Max {
+				return &fetchError{class: errorClassHTTP, err: fmt.Errorf("stopped after %d redirects", f.config.Redirects.Max)}
+			}
+			return nil
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = header
+	req.Header.Set("User-Agent", f.config.HTTPClient.UserAgent)
+	// The transport decodes only gzip by itself, so both encodings are handled by ReadBody
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	resp, err := client.Do(req)
+	return resp, redirects, err
+}
+
+func (f *Fetcher) transport(key transportKey) (*http.Transport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if transport, ok := f.transports[key]; ok {
+		return transport, nil
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if key.proxy != "" {
+		proxyURL, err := utils.ParseProxyURL(key.proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+	dialer := &net.Dialer{
+		Timeout:   f.config.HTTPClient.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   f.config.HTTPClient.ConnectTimeout,
+		ResponseHeaderTimeout: f.config.HTTPClient.ReadTimeout,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSClientConfig: &tls.Config{
+			RootCAs:            f.rootCAs,
+			InsecureSkipVerify: key.insecure, //nolint:gosec // Enabled explicitly for the channel
+		},
+	}
+	f.transports[key] = transport
+	return transport, nil
+}
+
+// CloseIdleConnections closes the connections kept for reuse
+func (f *Fetcher) CloseIdleConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, transport := range f.transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+// ReadBody decodes the response body and reads it up to http_client.max_body_size bytes.
+// It also returns the number of bytes received over the wire.
+func (f *Fetcher) ReadBody(resp *http.Response) ([]byte, int64, error) {
+	wire := &countingReader{reader: resp.Body}
+	var body io.Reader = wire
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(wire)
+		if err != nil {
+			return nil, wire.count, &fetchError{class: errorClassHTTP, err: fmt.Errorf("invalid gzip body: %w", err)}
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	case "br":
+		body = brotli.NewReader(wire)
+	default:
+		return nil, 0, &fetchError{class: errorClassHTTP, err: fmt.Errorf("unsupported content encoding %q", resp.Header.Get("Content-Encoding"))}
+	}
+
+	maxSize := f.config.HTTPClient.MaxBodySize
+	data, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if err != nil {
+		return nil, wire.count, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, wire.count, &fetchError{class: errorClassSize, err: fmt.Errorf("response body exceeds %d bytes", maxSize)}
+	}
+	return data, wire.count, nil
+}
@@ -0,0 +1,132 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"context"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/guregu/null"
+)
+
+// minFingerprintWords is the shortest text fingerprinted, shorter ones match too much by accident
+const minFingerprintWords = 8
+
+// titleWeight repeats the title words, outlets rewrite the body more than the headline
+const titleWeight = 2
+
+// fingerprintWords normalizes the text for fingerprinting: lowercased words without punctuation
+func fingerprintWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// simHash computes the 64-bit SimHash of the title and text over words and word pairs.
+// Texts which differ in a few words get fingerprints which differ in a few bits.
+func simHash(title, text string) (uint64, bool) {
+	titleWords := fingerprintWords(title)
+	words := fingerprintWords(text)
+	if len(titleWords)+len(words) < minFingerprintWords {
+		return 0, false
+	}
+
+	var weights [64]int
+	addFeature := func(feature string, weight int) {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(feature))
+		sum := hash.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit] += weight
+			} else {
+				weights[bit] -= weight
+			}
+		}
+	}
+	addWords := func(words []string, weight int) {
+		for i, word := range words {
+			addFeature(word, weight)
+			if i > 0 {
+				addFeature(words[i-1]+" "+word, weight)
+			}
+		}
+	}
+	addWords(titleWords, titleWeight)
+	addWords(words, 1)
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint, true
+}
+
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// clusterItem fingerprints a new item of the channel and puts it into the story of its nearest near-duplicate
+// collected from another channel within the clustering window. A new story is started if the near-duplicate has none.
+func clusterItem(ctx context.Context, queries *models.Queries, feedChannelID int64, feedItemID int64, title, text string, config *utils.Config) error {
+	if config.Clustering.MaxDistance < 0 {
+		return nil
+	}
+	fingerprint, ok := simHash(title, text)
+	if !ok {
+		return nil
+	}
+	err := queries.UpdateFeedItemFingerprint(ctx, models.UpdateFeedItemFingerprintParams{
+		Simhash: null.IntFrom(int64(fingerprint)),
+		ID:      feedItemID,
+	})
+	if err != nil {
+		return err
+	}
+
+	candidates, err := queries.ListFeedItemFingerprint(ctx, models.ListFeedItemFingerprintParams{
+		Since:           time.Now().UTC().Add(-config.Clustering.Window),
+		ID:              feedItemID,
+		OriginChannelID: null.IntFrom(feedChannelID),
+	})
+	if err != nil {
+		return err
+	}
+	var nearest *models.ListFeedItemFingerprintRow
+	nearestDistance := config.Clustering.MaxDistance + 1
+	for i, candidate := range candidates {
+		distance := hammingDistance(fingerprint, uint64(candidate.Simhash.Int64))
+		// Ties go to the candidate which already belongs to a story
+		if distance < nearestDistance || (distance == nearestDistance && nearest != nil && !nearest.ClusterID.Valid && candidate.ClusterID.Valid) {
+			nearest, nearestDistance = &candidates[i], distance
+		}
+	}
+	if nearest == nil {
+		return nil
+	}
+
+	clusterID := nearest.ClusterID.Int64
+	if !nearest.ClusterID.Valid {
+		clusterID, err = queries.CreateStoryCluster(ctx, nearest.Title)
+		if err != nil {
+			return err
+		}
+		err = queries.AddItemToStoryCluster(ctx, models.AddItemToStoryClusterParams{ClusterID: null.IntFrom(clusterID), ID: nearest.ID})
+		if err != nil {
+			return err
+		}
+	}
+	err = queries.AddItemToStoryCluster(ctx, models.AddItemToStoryClusterParams{ClusterID: null.IntFrom(clusterID), ID: feedItemID})
+	if err != nil {
+		return err
+	}
+	internal.InfoLogger.Printf("Feed item #%v joined story #%v (distance %d)", feedItemID, clusterID, nearestDistance)
+	return queries.RefreshStoryCluster(ctx, clusterID)
+}
@@ -0,0 +1,181 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+// Where a discovered feed was found
+const (
+	discoverySourcePage = "page" // The requested URL is a feed itself
+	discoverySourceLink = "link" // <link rel="alternate"> of the page
+	discoverySourcePath = "path" // One of the common feed paths of the site
+)
+
+// maxDiscoveryCandidates limits the number of feeds probed for a single page
+const maxDiscoveryCandidates = 10
+
+// feedLinkTypes are the MIME types of <link rel="alternate"> pointing to feeds
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/json":      true,
+}
+
+// commonFeedPaths are probed when the page doesn't advertise its feeds
+var commonFeedPaths = []string{"/feed", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml", "/rss"}
+
+// ErrNoFeeds is returned when no feed is found on the page
+var ErrNoFeeds = errors.New("no feeds found")
+
+// FeedCandidate is a feed found by the autodiscovery, its fields are taken from the parsed feed
+type FeedCandidate struct {
+	Link        string `json:"link"`
+	Type        string `json:"type"` // rss, atom or json
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Host        string `json:"host"`
+	Source      string `json:"source"`
+}
+
+// Discover finds the feeds of a page. The page may be a feed itself, otherwise the feeds
+// advertised by the page are checked, and the common feed paths of the site if there are none.
+func Discover(ctx context.Context, fetcher *Fetcher, pageURL string) ([]FeedCandidate, error) {
+	data, effectiveURL, err := fetchPage(ctx, fetcher, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	if feed, err := newFeedParser().Parse(bytes.NewReader(data)); err == nil {
+		return []FeedCandidate{newFeedCandidate(effectiveURL, feed, discoverySourcePage)}, nil
+	}
+
+	links, err := feedLinks(data, effectiveURL)
+	if err != nil {
+		return nil, err
+	}
+	source := discoverySourceLink
+	if len(links) == 0 {
+		source = discoverySourcePath
+		for _, path := range commonFeedPaths {
+			links = append(links, effectiveURL.ResolveReference(&url.URL{Path: path}).String())
+		}
+	}
+
+	var candidates []FeedCandidate
+	for _, link := range links[:min(len(links), maxDiscoveryCandidates)] {
+		candidate, err := DescribeFeed(ctx, fetcher, link)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			internal.InfoLogger.Printf("Discovery: skipping %s: %v", link, err)
+			continue
+		}
+		candidate.Source = source
+		candidates = append(candidates, *candidate)
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoFeeds
+	}
+	return candidates, nil
+}
+
+// DescribeFeed fetches and parses the feed to take its title, description and host
+func DescribeFeed(ctx context.Context, fetcher *Fetcher, feedURL string) (*FeedCandidate, error) {
+	data, effectiveURL, err := fetchPage(ctx, fetcher, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	feed, err := newFeedParser().Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, &fetchError{class: errorClassParse, err: err}
+	}
+	candidate := newFeedCandidate(effectiveURL, feed, discoverySourcePage)
+	return &candidate, nil
+}
+
+func fetchPage(ctx context.Context, fetcher *Fetcher, pageURL string) ([]byte, *url.URL, error) {
+	resp, _, err := fetcher.GetURL(ctx, pageURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return readPage(fetcher, resp)
+}
+
+// readPage reads a successful response and returns its body and the URL after redirects
+func readPage(fetcher *Fetcher, resp *http.Response) ([]byte, *url.URL, error) {
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			internal.ErrorLogger.Printf("Error closing response body: %v", err)
+		}
+	}(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, &fetchError{class: errorClassHTTP, err: fmt.Errorf("unexpected response status: %s", resp.Status)}
+	}
+	data, _, err := fetcher.ReadBody(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, resp.Request.URL, nil
+}
+
+// feedLinks returns the absolute URLs of the feeds advertised with <link rel="alternate">
+func feedLinks(page []byte, pageURL *url.URL) ([]string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		return nil, &fetchError{class: errorClassParse, err: err}
+	}
+	base := pageURL
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if baseURL, err := pageURL.Parse(href); err == nil {
+			base = baseURL
+		}
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+	doc.Find("link[rel~=alternate][href]").Each(func(_ int, selection *goquery.Selection) {
+		mediaType, _, err := mime.ParseMediaType(selection.AttrOr("type", ""))
+		if err != nil || !feedLinkTypes[mediaType] {
+			return
+		}
+		link, err := base.Parse(strings.TrimSpace(selection.AttrOr("href", "")))
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+			return
+		}
+		link.Fragment = ""
+		if !seen[link.String()] {
+			seen[link.String()] = true
+			links = append(links, link.String())
+		}
+	})
+	return links, nil
+}
+
+func newFeedCandidate(feedURL *url.URL, feed *gofeed.Feed, source string) FeedCandidate {
+	host := feedURL.Hostname()
+	if siteURL, err := url.Parse(feed.Link); err == nil && siteURL.Hostname() != "" {
+		host = siteURL.Hostname()
+	}
+	return FeedCandidate{
+		Link:        feedURL.String(),
+		Type:        feed.FeedType,
+		Title:       strings.TrimSpace(feed.Title),
+		Description: strings.TrimSpace(feed.Description),
+		Host:        strings.ToLower(host),
+		Source:      source,
+	}
+}
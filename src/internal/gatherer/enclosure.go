@@ -0,0 +1,187 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal/models"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/guregu/null"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// Media kinds stored in item_enclosure.medium
+const (
+	mediumAudio    = "audio"
+	mediumVideo    = "video"
+	mediumImage    = "image"
+	mediumDocument = "document"
+)
+
+// itemEnclosures collects the media attached to the item from RSS enclosures, Media RSS and iTunes
+// extensions, and the item image. Attachments with the same URL are merged.
+func itemEnclosures(itemXML *gofeed.Item) []models.UpsertItemEnclosureParams {
+	var enclosures []models.UpsertItemEnclosureParams
+	index := make(map[string]int)
+	add := func(enclosure models.UpsertItemEnclosureParams) {
+		enclosure.Url = strings.TrimSpace(enclosure.Url)
+		if enclosure.Url == "" {
+			return
+		}
+		if !enclosure.Medium.Valid {
+			enclosure.Medium = mediumFromType(enclosure.MimeType.String)
+		}
+		i, ok := index[enclosure.Url]
+		if !ok {
+			index[enclosure.Url] = len(enclosures)
+			enclosures = append(enclosures, enclosure)
+			return
+		}
+		// The first source wins, the next ones only fill in the gaps
+		merged := &enclosures[i]
+		merged.MimeType = firstValidString(merged.MimeType, enclosure.MimeType)
+		merged.Medium = firstValidString(merged.Medium, enclosure.Medium)
+		merged.Length = firstValidInt(merged.Length, enclosure.Length)
+		merged.Duration = firstValidInt(merged.Duration, enclosure.Duration)
+		merged.Thumbnail = firstValidString(merged.Thumbnail, enclosure.Thumbnail)
+	}
+
+	var itunesDuration null.Int
+	if itemXML.ITunesExt != nil {
+		itunesDuration = parseDuration(itemXML.ITunesExt.Duration)
+	}
+	for _, enclosure := range itemXML.Enclosures {
+		add(models.UpsertItemEnclosureParams{
+			Url:      enclosure.URL,
+			MimeType: nonEmptyString(enclosure.Type),
+			Length:   positiveInt(enclosure.Length),
+			Duration: itunesDuration,
+		})
+	}
+
+	if media, ok := itemXML.Extensions["media"]; ok {
+		// YouTube and others put the content and its thumbnail into <media:group>
+		for _, group := range media["group"] {
+			for _, content := range mediaContents(group.Children, mediaThumbnail(group.Children)) {
+				add(content)
+			}
+		}
+		for _, content := range mediaContents(media, mediaThumbnail(media)) {
+			add(content)
+		}
+	}
+
+	var image string
+	if itemXML.Image != nil {
+		image = strings.TrimSpace(itemXML.Image.URL)
+	}
+	if image != "" {
+		if _, ok := index[image]; !ok {
+			add(models.UpsertItemEnclosureParams{Url: image, Medium: null.StringFrom(mediumImage)})
+		}
+		// The item image is the default preview of its media
+		for i := range enclosures {
+			if !enclosures[i].Thumbnail.Valid && enclosures[i].Url != image && enclosures[i].Medium.String != mediumImage {
+				enclosures[i].Thumbnail = null.StringFrom(image)
+			}
+		}
+	}
+	return enclosures
+}
+
+func mediaContents(elements map[string][]ext.Extension, thumbnail null.String) []models.UpsertItemEnclosureParams {
+	var contents []models.UpsertItemEnclosureParams
+	for _, content := range elements["content"] {
+		contents = append(contents, models.UpsertItemEnclosureParams{
+			Url:       content.Attrs["url"],
+			MimeType:  nonEmptyString(content.Attrs["type"]),
+			Medium:    nonEmptyString(content.Attrs["medium"]),
+			Length:    positiveInt(content.Attrs["fileSize"]),
+			Duration:  positiveInt(content.Attrs["duration"]),
+			Thumbnail: firstValidString(mediaThumbnail(content.Children), thumbnail),
+		})
+	}
+	return contents
+}
+
+func mediaThumbnail(elements map[string][]ext.Extension) null.String {
+	for _, thumbnail := range elements["thumbnail"] {
+		if url := strings.TrimSpace(thumbnail.Attrs["url"]); url != "" {
+			return null.StringFrom(url)
+		}
+	}
+	return null.String{}
+}
+
+func storeEnclosures(ctx context.Context, queries *models.Queries, feedItemID int64, enclosures []models.UpsertItemEnclosureParams) error {
+	for _, enclosure := range enclosures {
+		enclosure.ItemID = feedItemID
+		if err := queries.UpsertItemEnclosure(ctx, enclosure); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mediumFromType(mimeType string) null.String {
+	switch {
+	case mimeType == "":
+		return null.String{}
+	case strings.HasPrefix(mimeType, "audio/"):
+		return null.StringFrom(mediumAudio)
+	case strings.HasPrefix(mimeType, "video/"):
+		return null.StringFrom(mediumVideo)
+	case strings.HasPrefix(mimeType, "image/"):
+		return null.StringFrom(mediumImage)
+	default:
+		return null.StringFrom(mediumDocument)
+	}
+}
+
+// parseDuration parses iTunes durations: seconds, "MM:SS" or "HH:MM:SS"
+func parseDuration(value string) null.Int {
+	var seconds int64
+	for _, part := range strings.Split(strings.TrimSpace(value), ":") {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 {
+			return null.Int{}
+		}
+		seconds = seconds*60 + n
+	}
+	if seconds == 0 {
+		return null.Int{}
+	}
+	return null.IntFrom(seconds)
+}
+
+func positiveInt(value string) null.Int {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || n <= 0 {
+		return null.Int{}
+	}
+	return null.IntFrom(n)
+}
+
+func nonEmptyString(value string) null.String {
+	value = strings.TrimSpace(value)
+	return null.NewString(value, value != "")
+}
+
+func firstValidString(values ...null.String) null.String {
+	for _, value := range values {
+		if value.Valid {
+			return value
+		}
+	}
+	return null.String{}
+}
+
+func firstValidInt(values ...null.Int) null.Int {
+	for _, value := range values {
+		if value.Valid {
+			return value
+		}
+	}
+	return null.Int{}
+}
@@ -0,0 +1,233 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/guregu/null"
+	"golang.org/x/net/html"
+)
+
+// Extraction states stored in feed_item.extraction_status
+const (
+	extractionPending   = "pending"
+	extractionExtracted = "extracted"
+	extractionFailed    = "failed"
+)
+
+// minArticleLength is the shortest text accepted as the main content of a page
+const minArticleLength = 250
+
+// errNoArticle is returned when no element of the page looks like an article
+var errNoArticle = errors.New("no article found on the page")
+
+// clutterSelector matches the page elements which are never a part of an article
+const clutterSelector = "script, style, noscript, iframe, form, button, input, select, textarea, svg, canvas, " +
+	"nav, header, footer, aside, [role=navigation], [role=banner], [role=contentinfo], [role=complementary], " +
+	"[aria-hidden=true], [hidden]"
+
+var (
+	// Class names and IDs of the blocks around the article
+	negativeHint = regexp.MustCompile(`(?i)comment|footer|sidebar|widget|share|social|related|promo|sponsor|advert|\bads?\b|banner|menu|breadcrumb|subscribe|newsletter|popup|cookie|masthead|pagination|meta`)
+	// Class names and IDs of the article itself
+	positiveHint = regexp.MustCompile(`(?i)article|content|entry|post|story|text|body|main|blog`)
+)
+
+// extractArticles downloads the pages of the items waiting for extraction and stores their articles.
+// A failed page is recorded in its item and doesn't affect the others or the channel.
+func extractArticles(ctx context.Context, fetcher *Fetcher, queries *models.Queries, feedChannelInfo *models.GetFeedChannelForFetchRow, config *utils.Config) {
+	items, err := queries.ListFeedItemForExtraction(ctx, models.ListFeedItemForExtractionParams{
+		ChannelID: feedChannelInfo.ID,
+		Limit:     int64(config.FullText.MaxItems),
+	})
+	if err != nil {
+		internal.ErrorLogger.Printf("Error listing items of feed channel #%v for extraction: %v", feedChannelInfo.ID, err)
+		return
+	}
+	if len(items) > 0 {
+		// The feed was just requested, the pages on the same host wait for the host delay after it
+		fetcher.pages.requested(hostKey(feedChannelInfo.Link, feedChannelInfo.Host))
+	}
+	for _, item := range items {
+		article, canonicalLink, err := extractArticle(ctx, fetcher, feedChannelInfo, item.Link)
+		if ctx.Err() != nil {
+			// The item stays pending until the next fetch
+			return
+		}
+		if canonicalLink != "" {
+			// Copies of the article in other feeds are recognized by the canonical link of the page
+			err := queries.UpdateFeedItemCanonicalLink(ctx, models.UpdateFeedItemCanonicalLinkParams{
+				CanonicalLink: null.StringFrom(canonicalLink),
+				ID:            item.ID,
+			})
+			if err != nil {
+				internal.ErrorLogger.Printf("Error storing the canonical link of feed item #%v: %v", item.ID, err)
+				return
+			}
+		}
+		params := models.UpdateFeedItemExtractionParams{
+			ExtractedContent: nonEmptyString(article),
+			ExtractionStatus: null.StringFrom(extractionExtracted),
+			ID:               item.ID,
+		}
+		if err != nil {
+			internal.InfoLogger.Printf("Could not extract the article of feed item #%v from %s: %v", item.ID, item.Link, err)
+			params.ExtractionStatus = null.StringFrom(extractionFailed)
+			params.ExtractionError = null.StringFrom(err.Error())
+		}
+		if err := queries.UpdateFeedItemExtraction(ctx, params); err != nil {
+			internal.ErrorLogger.Printf("Error storing the article of feed item #%v: %v", item.ID, err)
+			return
+		}
+	}
+}
+
+// extractArticle downloads the item page and returns its main content as sanitized HTML
+// and the canonical link of the page if it has one
+func extractArticle(ctx context.Context, fetcher *Fetcher, feedChannelInfo *models.GetFeedChannelForFetchRow, link string) (article string, canonicalLink string, err error) {
+	if !isAbsoluteURL(link) {
+		return "", "", fmt.Errorf("item has no absolute link: %q", link)
+	}
+	resp, _, err := fetcher.GetPage(ctx, feedChannelInfo, link)
+	if err != nil {
+		return "", "", err
+	}
+	data, pageURL, err := readPage(fetcher, resp)
+	if err != nil {
+		return "", "", err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+	if href, ok := doc.Find("link[rel~=canonical][href]").First().Attr("href"); ok {
+		if canonical, err := pageURL.Parse(strings.TrimSpace(href)); err == nil && isAbsoluteURL(canonical.String()) {
+			canonicalLink = canonicalURL(canonical.String())
+		}
+	}
+
+	if feedChannelInfo.ContentSelector.Valid && feedChannelInfo.ContentSelector.String != "" {
+		article, err = selectArticle(doc, feedChannelInfo.ContentSelector.String)
+	} else {
+		article, err = findArticle(doc)
+	}
+	if err != nil {
+		return "", canonicalLink, err
+	}
+	article = sanitizeHTML(article, pageURL.String())
+	if article == "" {
+		return "", canonicalLink, errNoArticle
+	}
+	return article, canonicalLink, nil
+}
+
+// selectArticle joins the elements matched by the channel's CSS selector
+func selectArticle(doc *goquery.Document, selector string) (string, error) {
+	matched := doc.Find(selector)
+	if matched.Length() == 0 {
+		return "", fmt.Errorf("selector %q matched nothing", selector)
+	}
+	var article strings.Builder
+	var err error
+	matched.EachWithBreak(func(_ int, selection *goquery.Selection) bool {
+		var block string
+		block, err = goquery.OuterHtml(selection)
+		article.WriteString(block)
+		return err == nil
+	})
+	return article.String(), err
+}
+
+// findArticle picks the element holding most of the paragraph text of the page, readability-style:
+// every paragraph adds its score to the parent and half of it to the grandparent, the scores are
+// adjusted by the class names and reduced by the share of link text.
+func findArticle(doc *goquery.Document) (string, error) {
+	doc.Find(clutterSelector).Remove()
+	doc.Find("*").Each(func(_ int, selection *goquery.Selection) {
+		if selection.Is("body, article, main") {
+			return
+		}
+		if negativeHint.MatchString(classAndID(selection)) && !positiveHint.MatchString(classAndID(selection)) {
+			selection.Remove()
+		}
+	})
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*goquery.Selection
+	addScore := func(selection *goquery.Selection, score float64) {
+		if selection.Length() == 0 || selection.Is("body, html") {
+			return
+		}
+		node := selection.Get(0)
+		if _, ok := scores[node]; !ok {
+			scores[node] = classWeight(selection)
+			if selection.Is("article, main") {
+				scores[node] += 10
+			}
+			candidates = append(candidates, selection)
+		}
+		scores[node] += score
+	}
+	doc.Find("p, pre, td, blockquote").Each(func(_ int, paragraph *goquery.Selection) {
+		text := strings.TrimSpace(paragraph.Text())
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		addScore(paragraph.Parent(), score)
+		addScore(paragraph.Parent().Parent(), score/2)
+	})
+
+	var best *goquery.Selection
+	var bestScore float64
+	for _, candidate := range candidates {
+		score := scores[candidate.Get(0)] * (1 - linkDensity(candidate))
+		if best == nil || score > bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	if best == nil {
+		best = doc.Find("article").First()
+	}
+	if best.Length() == 0 || len(strings.TrimSpace(best.Text())) < minArticleLength {
+		return "", errNoArticle
+	}
+	return best.Html()
+}
+
+func classAndID(selection *goquery.Selection) string {
+	return selection.AttrOr("class", "") + " " + selection.AttrOr("id", "")
+}
+
+func classWeight(selection *goquery.Selection) float64 {
+	var weight float64
+	hints := classAndID(selection)
+	if positiveHint.MatchString(hints) {
+		weight += 25
+	}
+	if negativeHint.MatchString(hints) {
+		weight -= 25
+	}
+	return weight
+}
+
+// linkDensity is the share of the element's text inside links, navigation blocks consist of links only
+func linkDensity(selection *goquery.Selection) float64 {
+	textLength := len(strings.TrimSpace(selection.Text()))
+	if textLength == 0 {
+		return 1
+	}
+	var linkLength int
+	selection.Find("a").Each(func(_ int, link *goquery.Selection) {
+		linkLength += len(strings.TrimSpace(link.Text()))
+	})
+	return float64(linkLength) / float64(textLength)
+}
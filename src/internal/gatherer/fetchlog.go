@@ -0,0 +1,191 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/pkg/types"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Error classes stored in feed_channel_log.error_class
+const (
+	errorClassTimeout  = "timeout"
+	errorClassDNS      = "dns"
+	errorClassTLS      = "tls"
+	errorClassNetwork  = "network"
+	errorClassHTTP     = "http"
+	errorClassSize     = "size"
+	errorClassParse    = "parse"
+	errorClassDatabase = "database"
+)
+
+type itemOutcome int
+
+const (
+	itemUnchanged itemOutcome = iota
+	itemCreated
+	itemUpdated
+	itemMerged // Found in another channel by its canonical link
+)
+
+// itemResult is the outcome of storing a feed item
+type itemResult struct {
+	outcome       itemOutcome
+	itemID        int64
+	canonicalLink string
+	stored        *models.FeedItem // The item as stored, for the items later in the feed
+}
+
+// itemFailure is a feed item which could not be stored, the rest of the feed is stored without it
+type itemFailure struct {
+	item string // GUID of the item, or its title if it has none
+	err  error
+}
+
+// fetchResult collects the details of a single fetch attempt
+type fetchResult struct {
+	url            string // Requested URL
+	effectiveURL   string // URL of the final response
+	redirects      []redirectHop
+	statusCode     int
+	bytesReceived  int64
+	itemsNew       int64
+	itemsUpdated   int64
+	itemsUnchanged int64
+	itemsMerged    int64
+	merges         []string // Merged items, e.g. "#12 http://a/post"
+	failures       []itemFailure
+	hintInterval   time.Duration // Update interval requested by the feed itself
+}
+
+func (r *fetchResult) addItem(item itemResult) {
+	switch item.outcome {
+	case itemCreated:
+		r.itemsNew++
+	case itemUpdated:
+		r.itemsUpdated++
+	case itemMerged:
+		r.itemsMerged++
+		r.merges = append(r.merges, fmt.Sprintf("#%d %s", item.itemID, item.canonicalLink))
+	default:
+		r.itemsUnchanged++
+	}
+}
+
+func (r *fetchResult) addFailure(itemXML *gofeed.Item, err error) {
+	item := itemXML.GUID
+	if item == "" {
+		item = fmt.Sprintf("%q", itemXML.Title)
+	}
+	r.failures = append(r.failures, itemFailure{item: item, err: err})
+}
+
+func formatFailures(failures []itemFailure) string {
+	formatted := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		formatted = append(formatted, failure.item+": "+failure.err.Error())
+	}
+	return strings.Join(formatted, "; ")
+}
+
+// fetchError is an error with an already known class
+type fetchError struct {
+	class      string
+	err        error
+	retryAfter time.Duration // Delay requested by the publisher with Retry-After
+	gone       bool          // The publisher answered 410 Gone, the feed won't come back
+}
+
+func (e *fetchError) Error() string {
+	return e.class + ": " + e.err.Error()
+}
+
+func (e *fetchError) Unwrap() error {
+	return e.err
+}
+
+func classifyError(err error) string {
+	var fetchErr *fetchError
+	if errors.As(err, &fetchErr) {
+		return fetchErr.class
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return errorClassDNS
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return errorClassTimeout
+	}
+	if isTLSError(err) {
+		return errorClassTLS
+	}
+	return errorClassNetwork
+}
+
+func isTLSError(err error) bool {
+	var recordHeaderErr tls.RecordHeaderError
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	return errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &verificationErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &certificateInvalidErr)
+}
+
+// countingReader counts the bytes read from the response body
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+func createFeedChannelLog(ctx context.Context, queries *models.Queries, feedChannelID int64, runID sql.NullInt64, started time.Time, result *fetchResult, fetchErr error) error {
+	args := models.CreateFeedChannelLogParams{
+		ChannelID:      feedChannelID,
+		Started:        sql.NullTime{Time: started, Valid: true},
+		StatusCode:     sql.NullInt64{Int64: int64(result.statusCode), Valid: result.statusCode != 0},
+		BytesReceived:  result.bytesReceived,
+		ItemsNew:       result.itemsNew,
+		ItemsUpdated:   result.itemsUpdated,
+		ItemsUnchanged: result.itemsUnchanged,
+		Url:            types.Convert2NullString(result.url),
+		EffectiveUrl:   types.Convert2NullString(result.effectiveURL),
+		Redirects:      types.Convert2NullString(formatRedirects(result.redirects)),
+		ItemsMerged:    result.itemsMerged,
+		Merges:         types.Convert2NullString(strings.Join(result.merges, ", ")),
+		ItemsFailed:    int64(len(result.failures)),
+		Failures:       types.Convert2NullString(formatFailures(result.failures)),
+		RunID:          runID,
+		DurationMs:     sql.NullInt64{Int64: time.Since(started).Milliseconds(), Valid: true},
+	}
+	if fetchErr != nil {
+		args.ErrorClass = types.Convert2NullString(classifyError(fetchErr))
+		args.ErrorMessage = types.Convert2NullString(fetchErr.Error())
+	}
+	err := queries.CreateFeedChannelLog(ctx, args)
+	if err != nil {
+		internal.ErrorLogger.Printf("Error updating feed channel log: %v", err)
+		return err
+	}
+	return nil
+}
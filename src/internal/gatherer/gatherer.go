@@ -3,163 +3,246 @@ package gatherer
 import (
 	"FeedsCollector/internal"
 	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"bytes"
 	"context"
 	"database/sql"
-	"errors"
+	"fmt"
 	"github.com/guregu/null"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/mmcdole/gofeed"
 )
 
-func FetchListFeedChannels(ctx context.Context, db *sql.DB) {
-	queries := models.New(db)
+// UpdateFeed fetches the feed channel, stores its items and records the outcome of the attempt in the channel log.
+// Channels with full_text then get the articles of their new items extracted from the item pages.
+func UpdateFeed(ctx context.Context, fetcher *Fetcher, feedChannelInfo *models.GetFeedChannelForFetchRow, db *sql.DB, config *utils.Config) error {
+	_, err := updateFeed(ctx, fetcher, feedChannelInfo, db, config, sql.NullInt64{})
+	return err
+}
 
-	// NOTE: This table is not going to grow very large, so I'm not using pagination
-	feedRows, err := queries.ListFeedChannel(ctx, sql.NullString{String: "1", Valid: true})
+// updateFeed is UpdateFeed recording the attempt as a part of the scheduler's fetch run, it returns the outcome of the fetch
+func updateFeed(ctx context.Context, fetcher *Fetcher, feedChannelInfo *models.GetFeedChannelForFetchRow, db *sql.DB, config *utils.Config,
+	runID sql.NullInt64) (*fetchResult, error) {
+	started := time.Now().UTC()
+	result, err := fetchFeed(ctx, fetcher, feedChannelInfo, db, config)
 	if err != nil {
-		internal.ErrorLogger.Fatalf("error listing feeds: %v", err)
+		internal.ErrorLogger.Printf("Error updating feed channel #%v: %v", feedChannelInfo.ID, err)
 	}
 
-	feedDataChannel := make(chan models.ListFeedChannelRow)
-
-	// create 10 goroutines to fetch feeds (function fetchFeed)
-	var wgFetcher sync.WaitGroup
-	wgFetcher.Add(10)
-	for i := 0; i < 10; i++ {
-		go func() {
-			defer wgFetcher.Done()
-			for feedInfo := range feedDataChannel {
-				err := UpdateFeed(ctx, &feedInfo, db)
-				if err != nil {
-					internal.ErrorLogger.Printf("Error processing feed: %v", err)
-					return
-				}
-			}
-		}()
+	// The attempt is logged and scheduled even if the fetch was interrupted by the context
+	queries := models.New(db)
+	logErr := createFeedChannelLog(context.WithoutCancel(ctx), queries, feedChannelInfo.ID, runID, started, result, err)
+	scheduleErr := scheduleNextFetch(context.WithoutCancel(ctx), queries, feedChannelInfo, config, result, err)
+	if err != nil {
+		return result, err
 	}
-
-	for _, feedRow := range feedRows {
-		feedDataChannel <- feedRow
+	if logErr != nil {
+		return result, logErr
 	}
-
-	close(feedDataChannel)
-	wgFetcher.Wait()
+	if scheduleErr != nil {
+		return result, scheduleErr
+	}
+	// The feed was fetched, failing to follow its move is retried on the next fetch
+	if err := trackPermanentRedirect(context.WithoutCancel(ctx), queries, feedChannelInfo, config, result); err != nil {
+		internal.ErrorLogger.Printf("Error tracking redirects of feed channel #%v: %v", feedChannelInfo.ID, err)
+	}
+	if feedChannelInfo.FullText {
+		extractArticles(ctx, fetcher, queries, feedChannelInfo, config)
+	}
+	return result, nil
 }
 
-func UpdateFeed(ctx context.Context, feedChannelInfo *models.ListFeedChannelRow, db *sql.DB) error {
+func fetchFeed(ctx context.Context, fetcher *Fetcher, feedChannelInfo *models.GetFeedChannelForFetchRow, db *sql.DB, config *utils.Config) (*fetchResult, error) {
+	result := &fetchResult{}
+
 	// Request the feed
-	client := &http.Client{
-		Timeout: 4 * time.Second,
+	header := make(http.Header)
+	// Conditional GET: let the publisher answer 304 if nothing has changed since the last fetch
+	if feedChannelInfo.Etag.Valid && feedChannelInfo.Etag.String != "" {
+		header.Set("If-None-Match", feedChannelInfo.Etag.String)
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedChannelInfo.Link, nil)
-	if err != nil {
-		internal.ErrorLogger.Printf("Error creating request: %v", err)
-		return err
+	if feedChannelInfo.LastModified.Valid && feedChannelInfo.LastModified.String != "" {
+		header.Set("If-Modified-Since", feedChannelInfo.LastModified.String)
 	}
-	resp, err := client.Do(req)
+	result.url = feedChannelInfo.Link
+	resp, redirects, err := fetcher.Get(ctx, feedChannelInfo, header)
+	result.redirects = redirects
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			internal.ErrorLogger.Printf("Request timed out: %v", err)
-			// TODO: добавить это в БД лог конкретного канала (feed)
-			return err
-		}
-		internal.ErrorLogger.Printf("Error requesting feed: %v", err)
-		return err
+		return result, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			internal.ErrorLogger.Fatalf("Error closing response body: %v", err)
+			internal.ErrorLogger.Printf("Error closing response body: %v", err)
 		}
 	}(resp.Body)
 
-	// Parse the feed
-	parser := gofeed.NewParser()
-	feed, err := parser.Parse(resp.Body)
+	result.statusCode = resp.StatusCode
+	result.effectiveURL = resp.Request.URL.String()
+	if resp.StatusCode == http.StatusNotModified {
+		// Nothing to parse, but the fetch is still a successful update
+		internal.InfoLogger.Printf("Feed channel #%v is not modified", feedChannelInfo.ID)
+		return result, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		statusErr := &fetchError{class: errorClassHTTP, err: fmt.Errorf("unexpected response status: %s", resp.Status)}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			statusErr.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		statusErr.gone = resp.StatusCode == http.StatusGone
+		return result, statusErr
+	}
+
+	data, bytesReceived, err := fetcher.ReadBody(resp)
+	result.bytesReceived = bytesReceived
 	if err != nil {
-		internal.ErrorLogger.Printf("Error parsing feed: %v", err)
-		return err
+		return result, err
 	}
 
-	// Iterate over feed items and send them to the channel
-	for _, itemXML := range feed.Items {
-		err := processFeedItem(feedChannelInfo.ID, itemXML, ctx, db)
-		if err != nil {
-			internal.ErrorLogger.Printf("Error processing feed item \"%v\": %v", itemXML.Title, err)
-			return err
-		}
-		// internal.InfoLogger.Printf("Processed feed item: %v", itemXML.Title)
+	// Parse the feed
+	parser := newFeedParser()
+	feed, err := parser.Parse(bytes.NewReader(data))
+	if err != nil {
+		return result, &fetchError{class: errorClassParse, err: err}
 	}
+	result.hintInterval = feedHintInterval(feed)
 
-	queries := models.New(db)
-	// Update a feed channel log
-	err = queries.CreateFeedChannelLog(ctx, feedChannelInfo.ID)
+	// Items, metadata and cache headers are stored in a single transaction
+	cacheHeaders := feedCacheHeaders{etag: resp.Header.Get("ETag"), lastModified: resp.Header.Get("Last-Modified")}
+	err = storeFeed(ctx, feedChannelInfo, feed, cacheHeaders, db, config, result)
 	if err != nil {
-		internal.ErrorLogger.Printf("Error updating feed channel log: %v", err)
-		return err
+		return result, &fetchError{class: errorClassDatabase, err: err}
 	}
 
-	return nil
+	return result, nil
 }
 
-func processFeedItem(feedChannelID int64, itemXML *gofeed.Item, ctx context.Context, db *sql.DB) error {
+func processFeedItem(feedChannelInfo *models.GetFeedChannelForFetchRow, itemXML *gofeed.Item, ctx context.Context, queries *models.Queries, lookup *itemLookup, config *utils.Config) (itemResult, error) {
+	feedChannelID := feedChannelInfo.ID
 	authors := getAuthorsString(itemXML)
 
+	sanitized := sanitizeItemHTML(itemXML.Description, itemXML.Content, itemXML.Link, feedChannelInfo.Link)
+
 	feedItemNew := models.CreateFeedItemParams{
-		Guid:        null.StringFrom(itemXML.GUID),
-		Title:       itemXML.Title,
-		Description: null.StringFrom(itemXML.Description),
-		Link:        itemXML.Link,
-		Author:      authors,
-		Published:   null.TimeFromPtr(itemXML.PublishedParsed),
+		Guid:            null.StringFrom(itemXML.GUID),
+		Title:           itemXML.Title,
+		Description:     null.StringFrom(sanitized.description),
+		Link:            itemXML.Link,
+		Author:          authors,
+		Published:       null.TimeFromPtr(itemXML.PublishedParsed),
+		Content:         sanitized.content,
+		SourceUpdated:   null.TimeFromPtr(itemXML.UpdatedParsed),
+		DescriptionRaw:  nonEmptyString(itemXML.Description),
+		ContentRaw:      nonEmptyString(itemXML.Content),
+		Text:            sanitized.text,
+		CanonicalLink:   nonEmptyString(canonicalURL(itemXML.Link)),
+		OriginChannelID: null.IntFrom(feedChannelID),
+	}
+	// The item pages compare the publication dates as stored, so they're all stored in UTC
+	if feedItemNew.Published.Valid {
+		feedItemNew.Published.Time = feedItemNew.Published.Time.UTC()
 	}
 
-	queries := models.New(db)
-
-	feedItem, createdFlag, err := getOrCreateFeedItem(ctx, queries, &feedItemNew)
+	feedItem, createdFlag, mergedFlag, err := getOrCreateFeedItem(ctx, queries, lookup, &feedItemNew)
 	if err != nil {
 		internal.ErrorLogger.Printf("Error getting or creating feed item: %v", err)
-		return err
+		return itemResult{}, err
+	}
+	if feedItem == nil {
+		return itemResult{}, nil // The item was purged from the trash
 	}
-	if !createdFlag { // The feed item is in the database, we need to check if it's changed in the source
+	outcome := itemCreated
+	switch {
+	case mergedFlag:
+		// The same item from another channel, only its own channel updates it
+		outcome = itemMerged
+		internal.InfoLogger.Printf("Feed item \"%v\" of feed channel #%v merged with feed item #%v (%s)",
+			feedItemNew.Title, feedChannelID, feedItem.ID, feedItem.CanonicalLink.String)
+	case !createdFlag && feedItem.OriginChannelID.Valid && feedItem.OriginChannelID.Int64 != feedChannelID:
+		outcome = itemUnchanged // Shared items are updated from the feed of their origin channel only
+	case !createdFlag:
+		outcome = itemUnchanged // The feed item is in the database, we need to check if it's changed in the source
 		channelsIDsString, err := getChannelsIDs(ctx, queries, feedItem.ID)
 		if err != nil {
 			internal.ErrorLogger.Printf("Error getting channels IDs: %v", err)
-			return err
+			return itemResult{}, err
 		}
 		isEqualFlag, err := compareFeedItems(feedItem, &feedItemNew, channelsIDsString)
 		if err != nil {
-			internal.ErrorLogger.Fatalf("Error comparing feed items: %v", err)
+			internal.ErrorLogger.Printf("Error comparing feed items: %v", err)
+			return itemResult{}, err
 		}
 		if !isEqualFlag {
+			// The version being replaced goes to the item history, unless the publisher only touched
+			// the update time
+			edited := itemEdited(feedItem, &feedItemNew)
+			if edited {
+				err = queries.CreateFeedItemRevision(ctx, feedItem.ID)
+				if err != nil {
+					internal.ErrorLogger.Printf("Error storing revision of feed item #%v: %v", feedItem.ID, err)
+					return itemResult{}, err
+				}
+			}
 			args := models.UpdateFeedItemShortParams{
-				Title:       feedItemNew.Title,
-				Description: feedItemNew.Description,
-				Link:        feedItemNew.Link,
-				ID:          feedItem.ID,
+				Title:          feedItemNew.Title,
+				Description:    feedItemNew.Description,
+				Link:           feedItemNew.Link,
+				Content:        feedItemNew.Content,
+				SourceUpdated:  feedItemNew.SourceUpdated,
+				DescriptionRaw: feedItemNew.DescriptionRaw,
+				ContentRaw:     feedItemNew.ContentRaw,
+				Text:           feedItemNew.Text,
+				CanonicalLink:  feedItemNew.CanonicalLink,
+				Edited:         edited,
+				ID:             feedItem.ID,
 			}
 			err = queries.UpdateFeedItemShort(ctx, args)
 			if err != nil {
 				internal.ErrorLogger.Printf("Error updating feed item: %v", err)
-				return err
+				return itemResult{}, err
 			}
+			outcome = itemUpdated
+			feedItem = updatedFeedItem(feedItem, &feedItemNew)
 		}
 	}
 
+	if createdFlag && feedChannelInfo.FullText {
+		// The article is downloaded after all items of the feed are stored
+		err = queries.MarkFeedItemForExtraction(ctx, feedItem.ID)
+		if err != nil {
+			internal.ErrorLogger.Printf("Error marking feed item #%v for extraction: %v", feedItem.ID, err)
+			return itemResult{}, err
+		}
+	}
+
+	err = storeEnclosures(ctx, queries, feedItem.ID, itemEnclosures(itemXML))
+	if err != nil {
+		internal.ErrorLogger.Printf("Error storing enclosures of feed item #%v: %v", feedItem.ID, err)
+		return itemResult{}, err
+	}
+
 	// Feed item exists, but it may be associated with another channel.
 	// Trying to create a new relation (channel to item).
 	err = addItemToChannel(ctx, queries, feedChannelID, feedItem.ID)
 	if err != nil {
-		return err
+		return itemResult{}, err
 	}
 
-	return nil
+	// The stories count the items of the channels, so the item joins one once it's in its channel
+	if createdFlag {
+		err = clusterItem(ctx, queries, feedChannelInfo.ID, feedItem.ID, feedItemNew.Title, sanitized.text.String, config)
+		if err != nil {
+			internal.ErrorLogger.Printf("Error clustering feed item #%v: %v", feedItem.ID, err)
+			return itemResult{}, err
+		}
+	}
+
+	return itemResult{outcome: outcome, itemID: feedItem.ID, canonicalLink: feedItem.CanonicalLink.String, stored: feedItem}, nil
 }
 
 func getAuthorsString(itemXML *gofeed.Item) *string {
@@ -177,84 +260,78 @@ func getAuthorsString(itemXML *gofeed.Item) *string {
 	return &authors
 }
 
-func getOrCreateFeedItem(ctx context.Context, queries *models.Queries, feedItemNew *models.CreateFeedItemParams) (item *models.FeedItem, created bool, err error) {
-	feedItem, err := getItemFromDB(ctx, queries, feedItemNew)
-	if err != nil {
-		internal.ErrorLogger.Printf("Error checking if feed item exists: %v", err)
-		return nil, false, err
-	}
+func getOrCreateFeedItem(ctx context.Context, queries *models.Queries, lookup *itemLookup, feedItemNew *models.CreateFeedItemParams) (item *models.FeedItem, created bool, merged bool, err error) {
+	feedItem, merged := lookup.find(feedItemNew)
 	if feedItem != nil {
-		return feedItem, false, nil // feed item exists
+		return feedItem, false, merged, nil // feed item exists
+	}
+	if lookup.purged(feedItemNew) {
+		// The user deleted the item, it isn't stored again while the channel lists it
+		return nil, false, false, nil
 	}
 
 	// Feed item doesn't exist. So, create it
 	itemCreated, err := queries.CreateFeedItem(ctx, *feedItemNew) // TODO
 	if err != nil {
 		internal.ErrorLogger.Printf("Error creating feed item: %v", err)
-		return nil, false, err
+		return nil, false, false, err
 	}
 	item = &models.FeedItem{
-		ID:          itemCreated.ID,
-		Title:       feedItemNew.Title,
-		Description: feedItemNew.Description,
-		Link:        feedItemNew.Link,
-		Author:      feedItemNew.Author,
-		Guid:        feedItemNew.Guid,
-		Published:   feedItemNew.Published,
-		Read:        itemCreated.Read,
-		Deleted:     false,
-		Created:     itemCreated.Created,
-		Updated:     itemCreated.Updated,
-	}
-	return item, true, nil
+		ID:              itemCreated.ID,
+		Title:           feedItemNew.Title,
+		Description:     feedItemNew.Description,
+		Link:            feedItemNew.Link,
+		Author:          feedItemNew.Author,
+		Guid:            feedItemNew.Guid,
+		Published:       feedItemNew.Published,
+		Content:         feedItemNew.Content,
+		DescriptionRaw:  feedItemNew.DescriptionRaw,
+		ContentRaw:      feedItemNew.ContentRaw,
+		SourceUpdated:   feedItemNew.SourceUpdated,
+		CanonicalLink:   feedItemNew.CanonicalLink,
+		OriginChannelID: feedItemNew.OriginChannelID,
+		Read:            itemCreated.Read,
+		Deleted:         false,
+		Created:         itemCreated.Created,
+		Updated:         itemCreated.Updated,
+	}
+	return item, true, false, nil
 }
 
-func getItemFromDB(ctx context.Context, queries *models.Queries, feedItemNew *models.CreateFeedItemParams) (*models.FeedItem, error) {
-	var item models.FeedItem
-
-	// Checking if a feed with this guid already exists
-	// TODO: почему я не могу проверить itemByGuid == nil?
-	itemByGuid, err := queries.GetFeedItemByGuid(ctx, feedItemNew.Guid)
-	if err != nil {
-		// If there is no feed item with the passed guid,
-		// then we need to check if a feed with passed link already exists
-		if !errors.Is(err, sql.ErrNoRows) {
-			internal.ErrorLogger.Printf("Error checking if feed exists: %v", err)
-			return nil, err
-		}
-	} else {
-		item = models.FeedItem{
-			ID:          itemByGuid.ID,
-			Guid:        itemByGuid.Guid,
-			Title:       itemByGuid.Title,
-			Description: itemByGuid.Description,
-			Link:        itemByGuid.Link,
-			Author:      itemByGuid.Author,
-			Published:   itemByGuid.Published,
-		}
-		return &item, nil
+// updatedFeedItem is the stored item after UpdateFeedItemShort
+func updatedFeedItem(feedItem *models.FeedItem, feedItemNew *models.CreateFeedItemParams) *models.FeedItem {
+	updated := *feedItem
+	updated.Title = feedItemNew.Title
+	updated.Description = feedItemNew.Description
+	updated.Content = feedItemNew.Content
+	updated.DescriptionRaw = feedItemNew.DescriptionRaw
+	updated.ContentRaw = feedItemNew.ContentRaw
+	updated.SourceUpdated = feedItemNew.SourceUpdated
+	if feedItemNew.Link != feedItem.Link {
+		// The canonical link found on the item page is kept while the link is the same
+		updated.CanonicalLink = feedItemNew.CanonicalLink
 	}
+	updated.Link = feedItemNew.Link
+	updated.Edited = updated.Edited || itemEdited(feedItem, feedItemNew)
+	return &updated
+}
 
-	// Checking if a feed item with the same link already exists
-	itemByLink, err := queries.GetFeedItemByLink(ctx, feedItemNew.Link)
-	if err != nil {
-		if !errors.Is(err, sql.ErrNoRows) {
-			return nil, err
-		}
-	} else {
-		item = models.FeedItem{
-			ID:          itemByLink.ID,
-			Guid:        itemByLink.Guid,
-			Title:       itemByLink.Title,
-			Description: itemByLink.Description,
-			Link:        itemByLink.Link,
-			Author:      itemByLink.Author,
-			Published:   itemByLink.Published,
-		}
-		return &item, nil
-	}
+// itemEdited tells if the publisher changed the fields kept in the item history, the text follows
+// the content and the description. The content a feed starts to publish is no edit, the items stored
+// before the content was collected have none.
+func itemEdited(feedItem *models.FeedItem, feedItemNew *models.CreateFeedItemParams) bool {
+	return feedItemNew.Title != feedItem.Title || feedItemNew.Link != feedItem.Link ||
+		htmlEdited(feedItem.Description, feedItem.DescriptionRaw, feedItemNew.Description, feedItemNew.DescriptionRaw) ||
+		feedItem.Content.Valid && htmlEdited(feedItem.Content, feedItem.ContentRaw, feedItemNew.Content, feedItemNew.ContentRaw)
+}
 
-	return nil, nil
+// htmlEdited compares the stored HTML with the new one once sanitized. The items stored before the HTML
+// was sanitized hold it as published and have no raw version, their HTML is compared as published.
+func htmlEdited(stored, storedRaw, sanitized, raw null.String) bool {
+	if stored.String != "" && !storedRaw.Valid {
+		return strings.TrimSpace(stored.String) != raw.String
+	}
+	return stored.String != sanitized.String
 }
 
 func compareFeedItems(feedItem *models.FeedItem, feedItemNew *models.CreateFeedItemParams, channelsIDsString *string) (isEqual bool, err error) {
@@ -279,6 +356,16 @@ func compareFeedItems(feedItem *models.FeedItem, feedItemNew *models.CreateFeedI
 			feedItem.ID, *channelsIDsString, feedItem.Link, feedItemNew.Link)
 		return false, nil
 	}
+	if feedItemNew.Content.String != feedItem.Content.String {
+		internal.InfoLogger.Printf("Feed item #%v (channels: %v) content has changed.",
+			feedItem.ID, *channelsIDsString)
+		return false, nil
+	}
+	if !feedItemNew.SourceUpdated.Time.Equal(feedItem.SourceUpdated.Time) {
+		internal.InfoLogger.Printf("Feed item #%v (channels: %v) was updated by the publisher at %v.",
+			feedItem.ID, *channelsIDsString, feedItemNew.SourceUpdated.Time)
+		return false, nil
+	}
 
 	return true, nil
 }
@@ -0,0 +1,1574 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/guregu/null"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+  <title>Test Feed</title>
+  <link>http://example.com/</link>
+  <description>Test feed description</description>
+  <item>
+    <guid>http://example.com/posts/1</guid>
+    <title>First post</title>
+    <link>http://example.com/posts/1</link>
+    <description>First post description</description>
+    <pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate>
+  </item>
+</channel>
+</rss>`
+
+var testDB *sql.DB
+var testConfig *utils.Config
+var testFetcher *Fetcher
+
+func TestMain(m *testing.M) {
+	if _, err := internal.InitLogging("", internal.InfoLogLevel); err != nil {
+		panic(err)
+	}
+	if _, err := internal.InitLogging("", internal.ErrorLogLevel); err != nil {
+		panic(err)
+	}
+
+	testConfig = &utils.Config{}
+	if err := utils.ValidateConfig(testConfig); err != nil {
+		panic(err)
+	}
+	var err error
+	testFetcher, err = NewFetcher(testConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	testDB, err = sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	// Every connection to ":memory:" gets its own database
+	testDB.SetMaxOpenConns(1)
+	defer testDB.Close()
+
+	err = runMigrations(testDB)
+	if err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func runMigrations(db *sql.DB) error {
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		return fmt.Errorf("could not create sqlite driver: %w", err)
+	}
+
+	m, err := migrate.NewWithDatabaseInstance(
+		"file://../../db/migrations",
+		"sqlite3", driver)
+	if err != nil {
+		return fmt.Errorf("could not create migrate instance: %w", err)
+	}
+
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("could not run up migrations: %w", err)
+	}
+
+	if err := utils.MigrateSearch(db, "file://../../db/search_migrations"); err != nil {
+		return fmt.Errorf("could not run search migrations: %w", err)
+	}
+
+	return nil
+}
+
+// newTestDB opens a migrated database of the test's own, for the tests which span all channels
+// or need several connections to the database
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := utils.OpenDatabase(t.TempDir()+"/feeds.db", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := runMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createTestChannel(t *testing.T, link string) models.CreateFeedChannelRow {
+	t.Helper()
+	channel, err := models.New(testDB).CreateFeedChannel(context.Background(), models.CreateFeedChannelParams{
+		Title:       "Test Channel",
+		Description: "A test channel",
+		Link:        link,
+		Host:        "127.0.0.1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create channel: %v", err)
+	}
+	return channel
+}
+
+func TestUpdateFeedConditionalGet(t *testing.T) {
+	const etag = `"v1"`
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		_, _ = w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	channel := createTestChannel(t, server.URL)
+
+	for i := 0; i < 2; i++ {
+		feedInfo, err := models.New(testDB).GetFeedChannelForFetch(ctx, channel.ID)
+		if err != nil {
+			t.Fatalf("Failed to load channel: %v", err)
+		}
+		if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err != nil {
+			t.Fatalf("UpdateFeed #%d failed: %v", i+1, err)
+		}
+	}
+
+	if requests != 2 || notModified != 1 {
+		t.Errorf("Expected 2 requests with 1 conditional hit, got %d requests and %d hits", requests, notModified)
+	}
+
+	var statuses []int64
+	rows, err := testDB.QueryContext(ctx, "SELECT status_code FROM feed_channel_log WHERE channel_id = ? ORDER BY id", channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status int64
+		if err := rows.Scan(&status); err != nil {
+			t.Fatal(err)
+		}
+		statuses = append(statuses, status)
+	}
+	if len(statuses) != 2 || statuses[0] != http.StatusOK || statuses[1] != http.StatusNotModified {
+		t.Errorf("Expected log statuses [200 304], got %v", statuses)
+	}
+}
+
+func TestUpdateFeedLogsFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	channel := createTestChannel(t, server.URL)
+	feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+	if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err == nil {
+		t.Fatal("Expected UpdateFeed to fail")
+	}
+
+	logs, err := models.New(testDB).ListFeedChannelLog(ctx, models.ListFeedChannelLogParams{ChannelID: channel.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 log record, got %d", len(logs))
+	}
+	if logs[0].ErrorClass.String != errorClassHTTP || logs[0].StatusCode.Int64 != http.StatusInternalServerError {
+		t.Errorf("Expected an http error with status 500, got %v (%v)", logs[0].ErrorClass, logs[0].StatusCode)
+	}
+}
+
+func TestUpdateFeedDisablesFailingChannel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7200")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	config := *testConfig
+	config.Retry.MaxFailures = 2
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel := createTestChannel(t, server.URL)
+
+	for failures := int64(0); failures < 2; failures++ {
+		feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host, FailureCount: failures}
+		if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, &config); err == nil {
+			t.Fatal("Expected UpdateFeed to fail")
+		}
+		health, err := queries.GetFeedChannelHealth(ctx, channel.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if health.FailureCount != failures+1 {
+			t.Errorf("Expected failure count %d, got %d", failures+1, health.FailureCount)
+		}
+		if !health.NextFetchAt.Valid || time.Until(health.NextFetchAt.Time) < time.Hour {
+			t.Errorf("Expected Retry-After to postpone the next fetch by 2 hours, got %v", health.NextFetchAt)
+		}
+	}
+
+	health, err := queries.GetFeedChannelHealth(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Enabled || !health.DisabledReason.Valid {
+		t.Errorf("Expected the channel to be disabled with a reason, got enabled=%v reason=%v", health.Enabled, health.DisabledReason)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	base, maxDelay := time.Minute, time.Hour
+	for failures, want := range map[int64]time.Duration{1: time.Minute, 3: 4 * time.Minute, 10: time.Hour, 100: time.Hour} {
+		delay := backoffDelay(failures, base, maxDelay)
+		if delay < want/2 || delay > want {
+			t.Errorf("backoffDelay(%d) = %v, want between %v and %v", failures, delay, want/2, want)
+		}
+	}
+}
+
+func TestFeedHintInterval(t *testing.T) {
+	const hintedFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:sy="http://purl.org/rss/1.0/modules/syndication/">
+<channel>
+  <title>Hinted Feed</title>
+  <link>http://example.com/</link>
+  <description>Feed with update hints</description>
+  <ttl>30</ttl>
+  <sy:updatePeriod>daily</sy:updatePeriod>
+  <sy:updateFrequency>4</sy:updateFrequency>
+</channel>
+</rss>`
+	feed, err := newFeedParser().ParseString(hintedFeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hint := feedHintInterval(feed); hint != 6*time.Hour {
+		t.Errorf("Expected a 6h hint, got %v", hint)
+	}
+}
+
+func TestPublishingCadence(t *testing.T) {
+	now := time.Now()
+	var published []null.Time
+	for i := 0; i < 5; i++ {
+		published = append(published, null.TimeFrom(now.Add(-time.Duration(i)*2*time.Hour)))
+	}
+	if cadence := publishingCadence(published); cadence != 2*time.Hour {
+		t.Errorf("Expected a 2h cadence, got %v", cadence)
+	}
+	if cadence := publishingCadence(published[:2]); cadence != 0 {
+		t.Errorf("Expected no cadence for two items, got %v", cadence)
+	}
+}
+
+func TestSchedulerFetchNow(t *testing.T) {
+	fetched := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testFeed))
+		fetched <- struct{}{}
+	}))
+	defer server.Close()
+
+	channel := createTestChannel(t, server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler := NewScheduler(testDB, testConfig, testFetcher)
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(stopped)
+	}()
+
+	waitFetch := func(reason string) {
+		select {
+		case <-fetched:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("The channel was not fetched %s", reason)
+		}
+	}
+	// The channel has never been fetched, so it's due right away
+	waitFetch("on start")
+	if !scheduler.FetchNow(channel.ID) {
+		t.Fatal("The scheduler refused the fetch request")
+	}
+	waitFetch("on request")
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(testConfig.Scheduler.ShutdownTimeout + 5*time.Second):
+		t.Fatal("The scheduler did not stop")
+	}
+	if scheduler.FetchNow(channel.ID) {
+		t.Error("The stopped scheduler accepted a fetch request")
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	config := *testConfig
+	config.Politeness.HostConcurrency = 2
+	config.Politeness.HostDelay = time.Second
+	config.Politeness.Hosts = map[string]utils.HostLimits{"slow.example.com": {Concurrency: 1, Delay: time.Minute}}
+	limiter := newHostLimiter(&config)
+	now := time.Now()
+
+	limiter.start("example.com", now)
+	if wait, ok := limiter.wait("example.com", now); !ok || wait != time.Second {
+		t.Errorf("Expected to wait 1s for the second slot, got %v (ok=%v)", wait, ok)
+	}
+	limiter.start("example.com", now.Add(time.Second))
+	if _, ok := limiter.wait("example.com", now.Add(time.Minute)); ok {
+		t.Error("Expected no free slots for example.com")
+	}
+	limiter.finish("example.com", now.Add(time.Minute))
+	if wait, ok := limiter.wait("example.com", now.Add(time.Minute)); !ok || wait != 0 {
+		t.Errorf("Expected a free slot for example.com, got %v (ok=%v)", wait, ok)
+	}
+
+	limiter.start("slow.example.com", now)
+	if _, ok := limiter.wait("slow.example.com", now); ok {
+		t.Error("Expected the override to allow a single request to slow.example.com")
+	}
+	limiter.finish("slow.example.com", now)
+	if wait, ok := limiter.wait("slow.example.com", now); !ok || wait != time.Minute {
+		t.Errorf("Expected to wait 1m for slow.example.com, got %v (ok=%v)", wait, ok)
+	}
+}
+
+func TestFetcherDecodesBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != testConfig.HTTPClient.UserAgent {
+			http.Error(w, "unexpected user agent", http.StatusForbidden)
+			return
+		}
+		var encoded bytes.Buffer
+		var writer io.WriteCloser
+		switch r.URL.Path {
+		case "/gzip":
+			writer = gzip.NewWriter(&encoded)
+		case "/br":
+			writer = brotli.NewWriter(&encoded)
+		}
+		_, _ = writer.Write([]byte(testFeed))
+		_ = writer.Close()
+		w.Header().Set("Content-Encoding", strings.TrimPrefix(r.URL.Path, "/"))
+		_, _ = w.Write(encoded.Bytes())
+	}))
+	defer server.Close()
+
+	for _, encoding := range []string{"gzip", "br"} {
+		feedInfo := models.GetFeedChannelForFetchRow{Link: server.URL + "/" + encoding}
+		resp, _, err := testFetcher.Get(context.Background(), &feedInfo, make(http.Header))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, wireBytes, err := testFetcher.ReadBody(resp)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if string(data) != testFeed || wireBytes >= int64(len(testFeed)) {
+			t.Errorf("%s: expected the decoded feed and fewer bytes on the wire, got %d bytes from %d", encoding, len(data), wireBytes)
+		}
+	}
+}
+
+func TestFetcherLimitsBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	config := *testConfig
+	config.HTTPClient.MaxBodySize = 100
+	fetcher, err := NewFetcher(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feedInfo := models.GetFeedChannelForFetchRow{Link: server.URL}
+	resp, _, err := fetcher.Get(context.Background(), &feedInfo, make(http.Header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, _, err := fetcher.ReadBody(resp); classifyError(err) != errorClassSize {
+		t.Errorf("Expected a size error, got %v", err)
+	}
+}
+
+func TestFetcherChannelSettings(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A proxy receives the absolute URL of the requested feed
+		proxied = r.URL.String()
+		_, _ = w.Write([]byte(testFeed))
+	}))
+	defer proxy.Close()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	feedInfo := models.GetFeedChannelForFetchRow{Link: "http://feeds.example.com/rss", Proxy: null.StringFrom(proxy.URL)}
+	resp, _, err := testFetcher.Get(ctx, &feedInfo, make(http.Header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if proxied != feedInfo.Link {
+		t.Errorf("Expected the request to go through the channel proxy, got %q", proxied)
+	}
+
+	feedInfo = models.GetFeedChannelForFetchRow{Link: server.URL}
+	if _, _, err := testFetcher.Get(ctx, &feedInfo, make(http.Header)); classifyError(err) != errorClassTLS {
+		t.Errorf("Expected a TLS error for the self-signed certificate, got %v", err)
+	}
+	feedInfo.TlsInsecure = true
+	resp, _, err = testFetcher.Get(ctx, &feedInfo, make(http.Header))
+	if err != nil {
+		t.Fatalf("Expected the insecure channel to skip verification, got %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestUpdateFeedMovesChannel(t *testing.T) {
+	var newLink string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, newLink, http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		_, _ = w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+	// The new link is on another host
+	newLink = strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/new"
+
+	config := *testConfig
+	config.Redirects.PermanentAfter = 2
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel := createTestChannel(t, server.URL+"/old")
+
+	for i := 0; i < 2; i++ {
+		health, err := queries.GetFeedChannelHealth(ctx, channel.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if health.Link != channel.Link {
+			t.Fatalf("The channel moved after %d redirect(s)", i)
+		}
+		feedInfo, err := queries.GetFeedChannelForFetch(ctx, channel.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && feedInfo.Etag.String != `"v1"` {
+			t.Fatalf("Expected the validators of the fetched feed, got etag %v", feedInfo.Etag)
+		}
+		if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, &config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	health, err := queries.GetFeedChannelHealth(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Link != newLink || health.OriginalLink.String != channel.Link || health.MovedTo.Valid {
+		t.Errorf("Expected the channel to move to /new, got link %s (original %v, moved to %v)", health.Link, health.OriginalLink, health.MovedTo)
+	}
+	feedInfo, err := queries.GetFeedChannelForFetch(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feedInfo.Host != "localhost" || feedInfo.Etag.Valid || feedInfo.LastModified.Valid {
+		t.Errorf("Expected the host of the new link and no validators, got host %s, etag %v and last modified %v",
+			feedInfo.Host, feedInfo.Etag, feedInfo.LastModified)
+	}
+	links, err := queries.ListFeedChannelLink(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0].OldLink != channel.Link {
+		t.Errorf("Expected a single link change, got %v", links)
+	}
+	logs, err := queries.ListFeedChannelLog(ctx, models.ListFeedChannelLogParams{ChannelID: channel.ID, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs[0].Url.String != channel.Link || logs[0].EffectiveUrl.String != newLink {
+		t.Errorf("Expected the log to record the original and effective URLs, got %v and %v", logs[0].Url, logs[0].EffectiveUrl)
+	}
+}
+
+func TestUpdateFeedMarksGoneChannel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	channel := createTestChannel(t, server.URL)
+	feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+	if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err == nil {
+		t.Fatal("Expected UpdateFeed to fail")
+	}
+
+	health, err := models.New(testDB).GetFeedChannelHealth(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !health.Gone || health.Enabled || health.FailureCount != 0 {
+		t.Errorf("Expected the channel to be disabled as gone on the first 410, got gone=%v enabled=%v failures=%d",
+			health.Gone, health.Enabled, health.FailureCount)
+	}
+}
+
+func TestDiscoverCommonPaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			_, _ = w.Write([]byte(`<html><head><title>No feed links</title></head></html>`))
+		case "/atom.xml":
+			_, _ = w.Write([]byte(testFeed))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	candidates, err := Discover(context.Background(), testFetcher, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].Link != server.URL+"/atom.xml" || candidates[0].Source != discoverySourcePath {
+		t.Fatalf("Expected the feed at a common path, got %+v", candidates)
+	}
+	if candidates[0].Title != "Test Feed" || candidates[0].Host != "example.com" {
+		t.Errorf("Expected the candidate to be described by the feed, got %+v", candidates[0])
+	}
+
+	candidates, err = Discover(context.Background(), testFetcher, server.URL+"/atom.xml")
+	if err != nil || len(candidates) != 1 || candidates[0].Source != discoverySourcePage {
+		t.Errorf("Expected the page to be recognized as a feed, got %+v (%v)", candidates, err)
+	}
+}
+
+func TestSyncChannelMetadata(t *testing.T) {
+	const describedFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+  <title>Described Feed</title>
+  <link>http://example.com/blog</link>
+  <description>Feed with metadata</description>
+  <language>en-us</language>
+  <generator>Hugo</generator>
+  <lastBuildDate>Mon, 02 Jan 2006 15:04:05 GMT</lastBuildDate>
+  <image><url>http://example.com/logo.png</url><title>Logo</title><link>http://example.com/</link></image>
+</channel>
+</rss>`
+	feed, err := newFeedParser().ParseString(describedFeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel := createTestChannel(t, "http://example.com/metadata.xml")
+
+	if err := syncChannelMetadata(ctx, queries, channel.ID, feed); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := queries.GetFeedChannelMetadata(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Title != "Described Feed" || metadata.Language.String != "en-us" || metadata.ImageUrl.String != "http://example.com/logo.png" ||
+		metadata.Generator.String != "Hugo" || !metadata.LastBuildDate.Valid {
+		t.Errorf("Expected the metadata to be synced from the feed, got %+v", metadata)
+	}
+
+	// A locked title is kept, the other metadata is still synced
+	_, err = testDB.ExecContext(ctx, "UPDATE feed_channel SET title = 'My Title', metadata_locked = 1, language = NULL WHERE id = ?", channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncChannelMetadata(ctx, queries, channel.ID, feed); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err = queries.GetFeedChannelMetadata(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Title != "My Title" || metadata.Language.String != "en-us" {
+		t.Errorf("Expected the locked title to be kept and the language to be synced, got %+v", metadata)
+	}
+}
+
+func TestItemEnclosures(t *testing.T) {
+	const mediaFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:media="http://search.yahoo.com/mrss/">
+<channel>
+  <title>Media Feed</title>
+  <link>http://example.com/</link>
+  <description>Podcast and video</description>
+  <item>
+    <guid>episode-1</guid>
+    <title>Episode 1</title>
+    <enclosure url="http://example.com/episode1.mp3" length="1024" type="audio/mpeg"/>
+    <itunes:duration>01:02:03</itunes:duration>
+    <itunes:image href="http://example.com/episode1.jpg"/>
+  </item>
+  <item>
+    <guid>video-1</guid>
+    <title>Video 1</title>
+    <media:group>
+      <media:content url="http://example.com/v/1" type="application/x-shockwave-flash" width="640" height="390"/>
+      <media:thumbnail url="http://example.com/v/1.jpg" width="480" height="360"/>
+    </media:group>
+  </item>
+</channel>
+</rss>`
+	feed, err := newFeedParser().ParseString(mediaFeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	podcast := itemEnclosures(feed.Items[0])
+	if len(podcast) != 2 {
+		t.Fatalf("Expected the episode and its image, got %+v", podcast)
+	}
+	episode := podcast[0]
+	if episode.Url != "http://example.com/episode1.mp3" || episode.Medium.String != mediumAudio || episode.Length.Int64 != 1024 ||
+		episode.Duration.Int64 != 3723 || episode.Thumbnail.String != "http://example.com/episode1.jpg" {
+		t.Errorf("Unexpected episode enclosure %+v", episode)
+	}
+
+	video := itemEnclosures(feed.Items[1])
+	if len(video) != 1 || video[0].Url != "http://example.com/v/1" || video[0].Thumbnail.String != "http://example.com/v/1.jpg" {
+		t.Errorf("Expected the media group content with its thumbnail, got %+v", video)
+	}
+}
+
+func TestUpdateFeedStoresContent(t *testing.T) {
+	const contentFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Content Feed</title>
+  <id>urn:content-feed</id>
+  <updated>2024-01-02T10:00:00Z</updated>
+  <entry>
+    <id>urn:content-feed:1</id>
+    <title>Long read</title>
+    <link href="http://example.com/long-read"/>
+    <published>2024-01-01T10:00:00Z</published>
+    <updated>2024-01-02T10:00:00Z</updated>
+    <summary>A teaser</summary>
+    <content type="html">&lt;p&gt;The whole article&lt;/p&gt;</content>
+  </entry>
+</feed>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(contentFeed))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	channel := createTestChannel(t, server.URL)
+	feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+	if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := models.New(testDB).GetFeedItemByGuid(ctx, models.GetFeedItemByGuidParams{ChannelID: channel.ID, Guid: null.StringFrom("urn:content-feed:1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Description.String != "A teaser" || item.Content.String != "<p>The whole article</p>" {
+		t.Errorf("Expected the summary and the content in their own columns, got %q and %q", item.Description.String, item.Content.String)
+	}
+	items, err := models.New(testDB).ListFeedItem(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Text.String != "The whole article" {
+		t.Errorf("Expected the plain text of the content, got %+v", items)
+	}
+	if !item.SourceUpdated.Valid || !item.SourceUpdated.Time.Equal(time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the publisher's update time, got %v", item.SourceUpdated)
+	}
+}
+
+func TestSanitizeHTML(t *testing.T) {
+	raw := `<p onclick="steal()">Hello <a href="/post">world</a></p>` +
+		`<script>alert(1)</script><iframe src="http://evil.example.com"></iframe>` +
+		`<img src="images/cat.png" srcset="cat.png 1x, cat@2x.png 2x" alt="cat">` +
+		`<img src="http://example.com/pixel.gif" width="1" height="1">` +
+		`<img src="http://feeds.feedburner.com/~r/blog/~4/abc">`
+	sanitized := sanitizeHTML(raw, "http://example.com/blog/item")
+
+	for _, unsafe := range []string{"onclick", "<script", "alert", "<iframe", "pixel.gif", "feedburner"} {
+		if strings.Contains(sanitized, unsafe) {
+			t.Errorf("Expected %q to be removed, got %s", unsafe, sanitized)
+		}
+	}
+	for _, resolved := range []string{`href="http://example.com/post"`, `src="http://example.com/blog/images/cat.png"`,
+		`srcset="http://example.com/blog/cat.png 1x, http://example.com/blog/cat@2x.png 2x"`} {
+		if !strings.Contains(sanitized, resolved) {
+			t.Errorf("Expected %s in %s", resolved, sanitized)
+		}
+	}
+
+	text := plainText("<h1>Title</h1><p>First  paragraph<br>second line</p><p>Third &amp; last</p>")
+	if text != "Title\n\nFirst paragraph\n\nsecond line\n\nThird & last" {
+		t.Errorf("Unexpected plain text %q", text)
+	}
+}
+
+func TestUpdateFeedExtractsArticles(t *testing.T) {
+	const paragraph = "The article paragraph is long enough to be taken for the main content of the page, " +
+		"it has commas, several clauses and no links at all."
+	page := `<html><body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<div class="sidebar"><p>Subscribe to the newsletter to get the news first, it is free, really.</p></div>
+<div class="post-content"><h1>Headline</h1><p>` + paragraph + `</p><p>` + paragraph + `</p>
+<p><img src="/images/chart.png"></p></div>
+<footer><p>Copyright by the publisher, all rights reserved, no reprints allowed.</p></footer>
+</body></html>`
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed":
+			_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Excerpts</title>
+<item><guid>urn:excerpts:1</guid><title>Article</title><link>%[1]s/article</link><description>Excerpt</description></item>
+<item><guid>urn:excerpts:2</guid><title>Missing</title><link>%[1]s/missing</link><description>Excerpt</description></item>
+</channel></rss>`, server.URL)
+		case "/article":
+			_, _ = w.Write([]byte(page))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// The item pages wait for the host delay, the default one would slow the test down
+	config := *testConfig
+	config.Politeness.HostDelay = time.Millisecond
+	fetcher, err := NewFetcher(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	channel := createTestChannel(t, server.URL+"/feed")
+	feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host, FullText: true}
+	if err := UpdateFeed(ctx, fetcher, &feedInfo, testDB, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := models.New(testDB)
+	items, err := queries.ListFeedItem(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]string)
+	for _, item := range items {
+		statuses[item.Title] = item.ExtractionStatus.String
+	}
+	if statuses["Article"] != extractionExtracted || statuses["Missing"] != extractionFailed {
+		t.Errorf("Expected the article extracted and the missing page failed, got %v", statuses)
+	}
+
+	contents, err := queries.ListFeedItemContent(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contents) != 1 {
+		t.Fatalf("Expected the extracted article of one item, got %d", len(contents))
+	}
+	article := contents[0].ExtractedContent.String
+	if !strings.Contains(article, paragraph) || !strings.Contains(article, server.URL+"/images/chart.png") {
+		t.Errorf("Expected the article with resolved links, got %s", article)
+	}
+	for _, clutter := range []string{"Home", "newsletter", "Copyright"} {
+		if strings.Contains(article, clutter) {
+			t.Errorf("Expected %q to be left out of the article, got %s", clutter, article)
+		}
+	}
+
+	// A selector of the channel takes the content as is
+	feedInfo.ContentSelector = null.StringFrom("footer p")
+	article, _, err = extractArticle(ctx, fetcher, &feedInfo, server.URL+"/article")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article != "<p>Copyright by the publisher, all rights reserved, no reprints allowed.</p>" {
+		t.Errorf("Expected the selected element, got %s", article)
+	}
+}
+
+func TestExtractArticlesWaitsForHost(t *testing.T) {
+	const hostDelay = 100 * time.Millisecond
+	var mu sync.Mutex
+	var requests []time.Time
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		mu.Unlock()
+		if r.URL.Path != "/feed" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Paced</title>
+<item><guid>urn:paced:1</guid><title>First</title><link>%[1]s/1</link></item>
+<item><guid>urn:paced:2</guid><title>Second</title><link>%[1]s/2</link></item>
+<item><guid>urn:paced:3</guid><title>Third</title><link>%[1]s/3</link></item>
+</channel></rss>`, server.URL)
+	}))
+	defer server.Close()
+
+	config := *testConfig
+	config.Politeness.HostDelay = hostDelay
+	fetcher, err := NewFetcher(&config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := createTestChannel(t, server.URL+"/feed")
+	feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host, FullText: true}
+	if err := UpdateFeed(context.Background(), fetcher, &feedInfo, testDB, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 4 {
+		t.Fatalf("Expected the feed and 3 item pages requested, got %d requests", len(requests))
+	}
+	// The delay runs from the start of a request, the server sees it later by the time to connect
+	for i := 1; i < len(requests); i++ {
+		if gap := requests[i].Sub(requests[i-1]); gap < hostDelay*9/10 {
+			t.Errorf("Request %d followed the previous one after %v, want at least %v", i, gap, hostDelay)
+		}
+	}
+}
+
+func TestUpdateFeedKeepsRevisions(t *testing.T) {
+	title, updated := "Original headline", "2024-06-01T08:00:00Z"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>News</title>
+<entry><id>urn:news:edited</id><title>%s</title><link href="http://example.com/news"/><summary>Story</summary><updated>%s</updated></entry>
+</feed>`, title, updated)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel := createTestChannel(t, server.URL)
+	feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+	update := func() (models.GetFeedItemVersionRow, []models.FeedItemRevision) {
+		t.Helper()
+		if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err != nil {
+			t.Fatal(err)
+		}
+		item, err := queries.GetFeedItemByGuid(ctx, models.GetFeedItemByGuidParams{ChannelID: channel.ID, Guid: null.StringFrom("urn:news:edited")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		version, err := queries.GetFeedItemVersion(ctx, item.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revisions, err := queries.ListFeedItemRevision(ctx, item.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return version, revisions
+	}
+
+	update()
+	update()
+	// A new update time alone is no edit
+	updated = "2024-06-01T09:00:00Z"
+	version, revisions := update()
+	if version.Edited || !version.SourceUpdated.Valid || len(revisions) != 0 {
+		t.Errorf("Expected the item updated without a revision, got %+v and %+v", version, revisions)
+	}
+
+	title = "Corrected headline"
+	version, revisions = update()
+	if len(revisions) != 1 || revisions[0].Title != "Original headline" {
+		t.Errorf("Expected the original version in the history, got %+v", revisions)
+	}
+	if !version.Edited || version.Title != "Corrected headline" {
+		t.Errorf("Expected the corrected item flagged as edited, got %+v", version)
+	}
+}
+
+func TestUpdateFeedDoesNotReviseLegacyItems(t *testing.T) {
+	const description = `<p>Story with <a href="http://example.com/more">a link</a></p>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/"><channel><title>News</title>
+<item><guid>urn:news:legacy</guid><title>Legacy</title><link>http://example.com/legacy</link><description><![CDATA[%s]]></description>
+<content:encoded><![CDATA[<p>The full story</p>]]></content:encoded></item>
+</channel></rss>`, description)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channel := createTestChannel(t, server.URL)
+	// Stored before the content was collected and the HTML sanitized: as published, without the raw HTML or the text
+	result, err := testDB.Exec(`INSERT INTO feed_item (guid, title, link, description, canonical_link, origin_channel_id)
+VALUES ('urn:news:legacy', 'Legacy', 'http://example.com/legacy', ?, 'http://example.com/legacy', ?)`, description, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemID, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queries.CreateFeedChannelItem(ctx, models.CreateFeedChannelItemParams{ChannelID: channel.ID, ItemID: itemID}); err != nil {
+		t.Fatal(err)
+	}
+
+	feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+	for i := 0; i < 2; i++ {
+		if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revisions, err := queries.ListFeedItemRevision(ctx, itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := queries.GetFeedItemVersion(ctx, itemID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 0 || version.Edited {
+		t.Errorf("Expected the legacy item updated without a revision, got %+v and %+v", version, revisions)
+	}
+	// The item is stored the way the gatherer stores it now
+	if !strings.Contains(version.Description.String, `target="_blank"`) || version.Content.String != "<p>The full story</p>" ||
+		version.Text.String != "The full story" {
+		t.Errorf("Expected the legacy item sanitized with its content, got %+v", version)
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := map[string]string{
+		"HTTP://Example.COM:80/story/?utm_source=rss&utm_medium=feed#comments": "http://example.com/story",
+		"https://example.com:443/a/b//?b=2&a=1&fbclid=xyz":                     "https://example.com/a/b?a=1&b=2",
+		"https://example.com":                "https://example.com/",
+		"https://example.com/?ref_src=twsrc": "https://example.com/",
+		" /relative/link ":                   "/relative/link",
+	}
+	for link, want := range tests {
+		if got := canonicalURL(link); got != want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
+
+func TestUpdateFeedDeduplicatesItems(t *testing.T) {
+	feeds := map[string]string{
+		"/a": `<item><guid>a-1</guid><title>Story</title><link>http://news.example.com/story?utm_source=a</link></item>
+<item><guid>shared-guid</guid><title>Other story</title><link>http://news.example.com/other</link></item>
+<item><guid>a-live-1</guid><title>Live 1</title><link>http://news.example.com/live</link></item>
+<item><guid>a-live-2</guid><title>Live 2</title><link>http://news.example.com/live</link></item>`,
+		// The same story under another GUID and a GUID of channel A for an unrelated item
+		"/b": `<item><guid>b-1</guid><title>Story copy</title><link>HTTP://News.Example.com/story/?utm_medium=b#top</link></item>
+<item><guid>shared-guid</guid><title>Unrelated</title><link>http://blog.example.org/unrelated</link></item>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>News</title>%s</channel></rss>`, feeds[r.URL.Path])
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	queries := models.New(testDB)
+	titles := make(map[string][]string)
+	var channelB models.CreateFeedChannelRow
+	for _, path := range []string{"/a", "/b"} {
+		channel := createTestChannel(t, server.URL+path)
+		feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+		if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err != nil {
+			t.Fatal(err)
+		}
+		items, err := queries.ListFeedItem(ctx, channel.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range items {
+			titles[path] = append(titles[path], item.Title)
+		}
+		channelB = channel
+	}
+
+	sort.Strings(titles["/a"])
+	sort.Strings(titles["/b"])
+	if !reflect.DeepEqual(titles["/a"], []string{"Live 1", "Live 2", "Other story", "Story"}) {
+		t.Errorf("Expected items with the same link and different GUIDs kept apart, got %v", titles["/a"])
+	}
+	if !reflect.DeepEqual(titles["/b"], []string{"Story", "Unrelated"}) {
+		t.Errorf("Expected the copy merged and the GUID not taken from channel A, got %v", titles["/b"])
+	}
+
+	logs, err := queries.ListFeedChannelLog(ctx, models.ListFeedChannelLogParams{ChannelID: channelB.ID, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].ItemsMerged != 1 || !strings.HasSuffix(logs[0].Merges.String, " http://news.example.com/story") {
+		t.Errorf("Expected the merge in the fetch log, got %+v", logs)
+	}
+}
+
+func TestUpdateFeedClustersStories(t *testing.T) {
+	const story = "The central bank raised interest rates by a quarter point on Wednesday, citing persistent inflation " +
+		"in services and a tight labour market. Officials signalled that further increases remain possible."
+	feeds := map[string]string{
+		"/wire": `<item><guid>wire-1</guid><title>Central bank raises rates by a quarter point</title>
+<link>http://wire.example.com/rates</link><description>` + story + `</description></item>`,
+		"/paper": `<item><guid>paper-1</guid><title>Central bank raises rates by a quarter point</title>
+<link>http://paper.example.com/economy/rates</link><description>` + strings.Replace(story, "labour", "labor", 1) + `</description></item>
+<item><guid>paper-2</guid><title>Local team wins the championship after extra time</title>
+<link>http://paper.example.com/sports/final</link><description>The home side clinched the title in a dramatic final
+that went to extra time, with the winning goal scored in the last minute by a substitute.</description></item>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>News</title>%s</channel></rss>`, feeds[r.URL.Path])
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	queries := models.New(testDB)
+	var channelIDs []int64
+	for _, path := range []string{"/wire", "/paper"} {
+		channel := createTestChannel(t, server.URL+path)
+		feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+		if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err != nil {
+			t.Fatal(err)
+		}
+		channelIDs = append(channelIDs, channel.ID)
+	}
+	clusters := make(map[string]null.Int)
+	for _, channelID := range channelIDs {
+		items, err := queries.ListFeedItem(ctx, channelID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range items {
+			clusters[item.Guid.String] = item.ClusterID
+		}
+	}
+
+	if !clusters["wire-1"].Valid || clusters["wire-1"] != clusters["paper-1"] {
+		t.Errorf("Expected the copies of the story in one cluster, got %v", clusters)
+	}
+	if clusters["paper-2"].Valid {
+		t.Errorf("Expected the unrelated item out of the clusters, got %v", clusters)
+	}
+	stories, err := queries.ListStoryCluster(ctx, models.ListStoryClusterParams{MinItems: 2, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stories) != 1 || stories[0].ItemCount != 2 || stories[0].Title != "Central bank raises rates by a quarter point" {
+		t.Errorf("Expected a story of two items, got %+v", stories)
+	}
+}
+
+func TestBackfillCanonicalLinks(t *testing.T) {
+	db, err := utils.OpenDatabase(t.TempDir()+"/feeds.db", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../db/migrations", "sqlite3", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Items stored before the deduplication get their links copied by its migration
+	if err := m.Migrate(13); err != nil {
+		t.Fatal(err)
+	}
+	const link = "HTTP://Example.com:80/news/story/?utm_source=feed&id=7#comments"
+	if _, err := db.Exec("INSERT INTO feed_item (title, link) VALUES ('Upgraded', ?), ('Canonical', 'http://example.com/other')", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	// The page's rel=canonical is kept
+	if _, err := db.Exec("UPDATE feed_item SET canonical_link = 'http://example.com/canonical' WHERE title = 'Canonical'"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	updated, err := BackfillCanonicalLinks(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 1 {
+		t.Errorf("Expected one item updated, got %d", updated)
+	}
+	canonicalLinks := make(map[string]string)
+	rows, err := db.Query("SELECT title, canonical_link FROM feed_item")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var title, canonicalLink string
+		if err := rows.Scan(&title, &canonicalLink); err != nil {
+			t.Fatal(err)
+		}
+		canonicalLinks[title] = canonicalLink
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Upgraded": "http://example.com/news/story?id=7", "Canonical": "http://example.com/canonical"}
+	if !reflect.DeepEqual(canonicalLinks, want) {
+		t.Errorf("Expected the upgraded link canonicalized, got %v want %v", canonicalLinks, want)
+	}
+	if updated, err := BackfillCanonicalLinks(ctx, db); err != nil || updated != 0 {
+		t.Errorf("Expected nothing left to backfill, got %d (%v)", updated, err)
+	}
+}
+
+func TestBackfillSanitizedItems(t *testing.T) {
+	db, err := utils.OpenDatabase(t.TempDir()+"/feeds.db", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://../../db/migrations", "sqlite3", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Items stored before the sanitizing keep their HTML as published
+	if err := m.Migrate(10); err != nil {
+		t.Fatal(err)
+	}
+	const description = `<p onclick="steal()">Read <a href="/more">more</a></p><script>alert(1)</script>`
+	_, err = db.Exec(`INSERT INTO feed_channel (id, title, description, link, host) VALUES (1, 'Legacy', '', 'http://legacy.example.com/rss', 'legacy.example.com');
+INSERT INTO feed_item (id, title, link, description, content) VALUES (1, 'Legacy', '', ?, NULL), (2, 'Empty', 'http://legacy.example.com/empty', '', NULL);
+INSERT INTO feed_channel_item (channel_id, item_id) VALUES (1, 1), (1, 2)`, description)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	updated, err := BackfillSanitizedItems(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != 1 {
+		t.Errorf("Expected one item updated, got %d", updated)
+	}
+	var sanitized, raw, text string
+	if err := db.QueryRow("SELECT description, description_raw, text FROM feed_item WHERE id = 1").Scan(&sanitized, &raw, &text); err != nil {
+		t.Fatal(err)
+	}
+	// The item has no link, so its relative URLs point to the site of the channel
+	want := `<p>Read <a href="http://legacy.example.com/more" rel="nofollow noreferrer noopener" target="_blank">more</a></p>`
+	if sanitized != want || raw != description || text != "Read more" {
+		t.Errorf("Expected the description sanitized and kept as published, got %q, %q and %q", sanitized, raw, text)
+	}
+	if updated, err := BackfillSanitizedItems(ctx, db); err != nil || updated != 0 {
+		t.Errorf("Expected nothing left to backfill, got %d (%v)", updated, err)
+	}
+}
+
+func TestUpdateFeedDoesNotClusterOneChannel(t *testing.T) {
+	// A daily digest repeats most of its text, its issues are not copies of a story
+	const digest = "Good morning, here is your daily digest of the markets: shares closed mixed, bond yields edged " +
+		"higher and the currency held steady against the dollar ahead of the employment figures."
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Digest</title>
+<item><guid>digest-1</guid><title>Markets daily digest</title><link>http://digest.example.com/1</link>
+<description>%s Monday.</description></item>
+<item><guid>digest-2</guid><title>Markets daily digest</title><link>http://digest.example.com/2</link>
+<description>%s Tuesday.</description></item>
+</channel></rss>`, digest, digest)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	channel := createTestChannel(t, server.URL)
+	feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+	if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err != nil {
+		t.Fatal(err)
+	}
+	items, err := models.New(testDB).ListFeedItem(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected both issues stored, got %d items", len(items))
+	}
+	for _, item := range items {
+		if item.ClusterID.Valid {
+			t.Errorf("Expected the items of one channel out of the stories, got %v in story %v", item.Guid, item.ClusterID)
+		}
+	}
+}
+
+func TestUpdateFeedSkipsFailedItems(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"partial"`)
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Partial</title>
+<item><guid>partial-1</guid><title>First item</title><link>http://%[1]s/1</link></item>
+<item><guid>partial-2</guid><title>Broken item</title><link>http://%[1]s/2</link></item>
+<item><guid>partial-3</guid><title>Third item</title><link>http://%[1]s/3</link></item>
+</channel></rss>`, r.Host)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	_, err := testDB.ExecContext(ctx, `CREATE TEMP TRIGGER reject_broken_item BEFORE INSERT ON feed_item
+WHEN NEW.title = 'Broken item' BEGIN SELECT RAISE(ABORT, 'broken item rejected'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, _ = testDB.ExecContext(ctx, "DROP TRIGGER reject_broken_item")
+	}()
+
+	channel := createTestChannel(t, server.URL)
+	feedInfo := models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+	if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err != nil {
+		t.Fatalf("Expected a failed item not to fail the fetch, got %v", err)
+	}
+
+	queries := models.New(testDB)
+	items, err := queries.ListFeedItem(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var guids []string
+	for _, item := range items {
+		guids = append(guids, item.Guid.String)
+	}
+	sort.Strings(guids)
+	if !reflect.DeepEqual(guids, []string{"partial-1", "partial-3"}) {
+		t.Errorf("Expected the items around the failed one stored, got %v", guids)
+	}
+	logs, err := queries.ListFeedChannelLog(ctx, models.ListFeedChannelLogParams{ChannelID: channel.ID, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs[0].ErrorClass.Valid || logs[0].ItemsNew != 2 || logs[0].ItemsFailed != 1 ||
+		!strings.HasPrefix(logs[0].Failures.String, "partial-2: ") || !strings.Contains(logs[0].Failures.String, "broken item rejected") {
+		t.Errorf("Expected the failed item reported in the log, got %+v", logs[0])
+	}
+	stored, err := queries.GetFeedChannelForFetch(ctx, channel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Etag.Valid {
+		t.Errorf("Expected no ETag saved while an item is missing, got %v", stored.Etag)
+	}
+}
+
+func TestUpdateFeedConcurrentWriters(t *testing.T) {
+	db := newTestDB(t)
+	var journalMode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil || journalMode != "wal" {
+		t.Fatalf("Expected the database in WAL mode, got %q (%v)", journalMode, err)
+	}
+
+	const channels, itemsPerFeed = 8, 20
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var items strings.Builder
+		for i := 0; i < itemsPerFeed; i++ {
+			_, _ = fmt.Fprintf(&items, "<item><guid>%[1]s-%[2]d</guid><title>Item %[2]d</title><link>http://concurrent.example.com%[1]s/%[2]d</link></item>", r.URL.Path, i)
+		}
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Concurrent</title>%s</channel></rss>`, items.String())
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	queries := models.New(db)
+	feedInfos := make([]models.GetFeedChannelForFetchRow, channels)
+	for i := range feedInfos {
+		channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+			Title:       "Concurrent Channel",
+			Description: "A channel fetched along with others",
+			Link:        fmt.Sprintf("%s/feed%d", server.URL, i),
+			Host:        "127.0.0.1",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		feedInfos[i] = models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+	}
+
+	errs := make(chan error, channels)
+	for i := range feedInfos {
+		go func(feedInfo *models.GetFeedChannelForFetchRow) {
+			errs <- UpdateFeed(ctx, testFetcher, feedInfo, db, testConfig)
+		}(&feedInfos[i])
+	}
+	for range feedInfos {
+		if err := <-errs; err != nil {
+			t.Errorf("Expected concurrent updates to succeed, got %v", err)
+		}
+	}
+	for _, feedInfo := range feedInfos {
+		items, err := queries.ListFeedItem(ctx, feedInfo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != itemsPerFeed {
+			t.Errorf("Expected %d items in channel #%d, got %d", itemsPerFeed, feedInfo.ID, len(items))
+		}
+	}
+}
+
+func TestSchedulerRecordsFetchRuns(t *testing.T) {
+	db := newTestDB(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	queries := models.New(db)
+	channelIDs := make(map[string]int64)
+	for _, path := range []string{"/feed", "/broken"} {
+		channel, err := queries.CreateFeedChannel(ctx, models.CreateFeedChannelParams{
+			Title:       "Run Channel",
+			Description: "A channel fetched by the scheduler",
+			Link:        server.URL + path,
+			Host:        "127.0.0.1",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		channelIDs[path] = channel.ID
+	}
+
+	config := *testConfig
+	config.Politeness.HostDelay = time.Millisecond
+	runCtx, cancel := context.WithCancel(ctx)
+	scheduler := NewScheduler(db, &config, testFetcher)
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.Run(runCtx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	var runs []models.FetchRun
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		runs, err = queries.ListFetchRun(ctx, models.ListFetchRunParams{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) > 0 && runs[0].Finished.Valid {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The fetch run did not finish, got %+v", runs)
+		}
+	}
+	if len(runs) != 1 || runs[0].Succeeded != 1 || runs[0].Failed != 1 || runs[0].Skipped != 0 {
+		t.Errorf("Expected a run with one success and one failure, got %+v", runs)
+	}
+	channels, err := queries.ListFetchRunChannels(ctx, sql.NullInt64{Int64: runs[0].ID, Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 2 || channels[0].ChannelID != channelIDs["/broken"] || channels[0].ErrorClass.String != errorClassHTTP ||
+		channels[1].ChannelID != channelIDs["/feed"] || channels[1].ItemsNew != 1 || !channels[1].DurationMs.Valid {
+		t.Errorf("Expected the failed fetch first and the successful one next, got %+v", channels)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	feeds := map[string]string{
+		"/kept": `<item><guid>kept-1</guid><title>Kept</title><link>http://trash.example.com/kept</link></item>
+<item><guid>kept-2</guid><title>Deleted</title><link>http://trash.example.com/deleted</link></item>`,
+		"/deleted": `<item><guid>deleted-1</guid><title>Deleted channel</title><link>http://trash.example.com/channel</link></item>`,
+		"/mirror":  `<item><guid>mirror-1</guid><title>Mirrored</title><link>http://trash.example.com/deleted?utm_source=mirror</link></item>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Trash</title>%s</channel></rss>`, feeds[r.URL.Path])
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	queries := models.New(testDB)
+	channels := make(map[string]models.GetFeedChannelForFetchRow)
+	update := func(path string) {
+		t.Helper()
+		feedInfo := channels[path]
+		if err := UpdateFeed(ctx, testFetcher, &feedInfo, testDB, testConfig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	titles := func(path string) []string {
+		t.Helper()
+		items, err := queries.ListFeedItem(ctx, channels[path].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, item := range items {
+			titles = append(titles, item.Title)
+		}
+		sort.Strings(titles)
+		return titles
+	}
+	for _, path := range []string{"/kept", "/deleted"} {
+		channel := createTestChannel(t, server.URL+path)
+		channels[path] = models.GetFeedChannelForFetchRow{ID: channel.ID, Link: channel.Link, Host: channel.Host}
+		update(path)
+	}
+
+	item, err := queries.GetFeedItemByGuid(ctx, models.GetFeedItemByGuidParams{ChannelID: channels["/kept"].ID, Guid: null.StringFrom("kept-2")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queries.TrashFeedItem(ctx, item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := queries.TrashFeedChannel(ctx, channels["/deleted"].ID); err != nil {
+		t.Fatal(err)
+	}
+	// The item in the trash is still known to the gatherer, the publisher's copy doesn't restore it
+	update("/kept")
+	var deleted bool
+	if err := testDB.QueryRow("SELECT deleted FROM feed_item WHERE id = ?", item.ID).Scan(&deleted); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles("/kept"); !deleted || !reflect.DeepEqual(got, []string{"Deleted", "Kept"}) {
+		t.Errorf("Expected the item to stay in the trash, got deleted %v and items %v", deleted, got)
+	}
+
+	// Nothing has been in the trash for the retention period yet
+	items, purgedChannels, err := purgeTrashBefore(ctx, testDB, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items != 0 || purgedChannels != 0 {
+		t.Errorf("Expected nothing purged, got %d items and %d channels", items, purgedChannels)
+	}
+	items, purgedChannels, err = purgeTrashBefore(ctx, testDB, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items != 2 || purgedChannels != 1 {
+		t.Errorf("Expected 2 items and 1 channel purged, got %d items and %d channels", items, purgedChannels)
+	}
+
+	// The purged item is not stored again while the channel still publishes it
+	update("/kept")
+	if got := titles("/kept"); !reflect.DeepEqual(got, []string{"Kept"}) {
+		t.Errorf("Expected the purged item to stay deleted, got %v", got)
+	}
+	logs, err := queries.ListFeedChannelLog(ctx, models.ListFeedChannelLogParams{ChannelID: channels["/kept"].ID, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].ItemsNew != 0 {
+		t.Errorf("Expected no new items in the fetch log, got %+v", logs)
+	}
+
+	if _, err := queries.GetFeedChannelForFetch(ctx, channels["/deleted"].ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected the purged channel to be gone, got %v", err)
+	}
+	for _, table := range []string{"feed_channel_item", "feed_channel_log"} {
+		var count int
+		err := testDB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE channel_id = ?", channels["/deleted"].ID).Scan(&count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("Expected the rows of the purged channel deleted from %s, got %d", table, count)
+		}
+	}
+
+	// Another channel publishing the canonical link of the purged item publishes the same item
+	mirror := createTestChannel(t, server.URL+"/mirror")
+	channels["/mirror"] = models.GetFeedChannelForFetchRow{ID: mirror.ID, Link: mirror.Link, Host: mirror.Host}
+	update("/mirror")
+	if got := titles("/mirror"); len(got) != 0 {
+		t.Errorf("Expected the purged item not to come back from another channel, got %v", got)
+	}
+
+	// The tombstones expire, then the feeds still publishing the item bring it back
+	if expired, err := expireTombstonesBefore(ctx, testDB, time.Now().Add(-time.Hour)); err != nil || expired != 0 {
+		t.Errorf("Expected no tombstones expired yet, got %d (%v)", expired, err)
+	}
+	expired, err := expireTombstonesBefore(ctx, testDB, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired == 0 {
+		t.Error("Expected the tombstone of the purged item expired")
+	}
+	update("/kept")
+	update("/mirror")
+	if got := titles("/kept"); !reflect.DeepEqual(got, []string{"Deleted", "Kept"}) {
+		t.Errorf("Expected the item collected again after its tombstone expired, got %v", got)
+	}
+	if got := titles("/mirror"); !reflect.DeepEqual(got, []string{"Deleted"}) {
+		t.Errorf("Expected the mirrored item merged with the collected one, got %v", got)
+	}
+}
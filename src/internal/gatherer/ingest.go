@@ -0,0 +1,270 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"FeedsCollector/pkg/types"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/guregu/null"
+	"github.com/mmcdole/gofeed"
+)
+
+// writeLock lets one worker write a feed at a time. SQLite allows a single writer anyway,
+// queueing here keeps the workers from failing on the busy timeout while a large feed is stored.
+var writeLock sync.Mutex
+
+// feedCacheHeaders are the validators of the feed response saved for the next conditional GET
+type feedCacheHeaders struct {
+	etag         string
+	lastModified string
+}
+
+// storeFeed stores the items of the feed and the channel metadata in a single transaction.
+// Every item is stored under a savepoint: an item which fails is rolled back alone and reported
+// in the result, the rest of the feed is committed. If every item fails, nothing is committed.
+func storeFeed(ctx context.Context, feedChannelInfo *models.GetFeedChannelForFetchRow, feed *gofeed.Feed, cacheHeaders feedCacheHeaders,
+	db *sql.DB, config *utils.Config, result *fetchResult) error {
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Does nothing once the transaction is committed
+		_ = tx.Rollback()
+	}()
+	queries := models.New(db).WithTx(tx)
+
+	lookup, err := loadItemLookup(ctx, queries, feedChannelInfo.ID, feed.Items)
+	if err != nil {
+		return fmt.Errorf("looking up stored items: %w", err)
+	}
+	for _, itemXML := range feed.Items {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT feed_item"); err != nil {
+			return err
+		}
+		item, err := processFeedItem(feedChannelInfo, itemXML, ctx, queries, lookup, config)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			internal.ErrorLogger.Printf("Skipping feed item \"%v\" of feed channel #%v: %v", itemXML.Title, feedChannelInfo.ID, err)
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO feed_item"); err != nil {
+				return err
+			}
+			result.addFailure(itemXML, err)
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE feed_item"); err != nil {
+			return err
+		}
+		if item.stored != nil {
+			lookup.add(item.stored)
+			result.addItem(item)
+		}
+	}
+	if len(result.failures) > 0 && len(result.failures) == len(feed.Items) {
+		return fmt.Errorf("all %d items failed, the first one: %w", len(result.failures), result.failures[0].err)
+	}
+
+	if err := syncChannelMetadata(ctx, queries, feedChannelInfo.ID, feed); err != nil {
+		return fmt.Errorf("channel metadata: %w", err)
+	}
+	// Cache headers are saved only if all items are stored, otherwise
+	// the failed items would be hidden by the next 304 response instead of retried
+	if len(result.failures) == 0 {
+		err = queries.UpdateFeedChannelCacheHeaders(ctx, models.UpdateFeedChannelCacheHeadersParams{
+			Etag:         types.Convert2NullString(cacheHeaders.etag),
+			LastModified: types.Convert2NullString(cacheHeaders.lastModified),
+			ID:           feedChannelInfo.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// itemLookup holds the stored items matching the GUIDs and links of a feed. They are loaded
+// with two queries for the whole feed, and the items stored during the fetch are added as they go.
+type itemLookup struct {
+	channelID int64
+	byGuid    map[string]*models.FeedItem // Items of the channel
+	byLink    map[string][]*linkedItem    // Items of all channels by the canonical link, oldest first
+	links     map[int64]string            // Canonical links of the items in byLink
+	// GUIDs of the items of the channel and canonical links of the items of any channel purged from the trash,
+	// they are not stored again until their tombstones expire
+	purgedGuids map[string]bool
+	purgedLinks map[string]bool
+}
+
+type linkedItem struct {
+	item      *models.FeedItem
+	inChannel bool
+}
+
+func loadItemLookup(ctx context.Context, queries *models.Queries, feedChannelID int64, items []*gofeed.Item) (*itemLookup, error) {
+	lookup := &itemLookup{
+		channelID: feedChannelID,
+		byGuid:    make(map[string]*models.FeedItem),
+		byLink:    make(map[string][]*linkedItem),
+		links:     make(map[int64]string),
+
+		purgedGuids: make(map[string]bool),
+		purgedLinks: make(map[string]bool),
+	}
+	var guids, canonicalLinks []null.String
+	for _, itemXML := range items {
+		if itemXML.GUID != "" {
+			guids = append(guids, null.StringFrom(itemXML.GUID))
+		}
+		if link := canonicalURL(itemXML.Link); link != "" && !isSiteRoot(link) {
+			canonicalLinks = append(canonicalLinks, null.StringFrom(link))
+		}
+	}
+
+	if len(guids) > 0 {
+		itemsByGuid, err := queries.ListFeedItemByGuids(ctx, models.ListFeedItemByGuidsParams{ChannelID: feedChannelID, Guids: guids})
+		if err != nil {
+			return nil, err
+		}
+		for _, itemByGuid := range itemsByGuid {
+			if _, ok := lookup.byGuid[itemByGuid.Guid.String]; ok {
+				continue // The oldest item with the GUID wins
+			}
+			lookup.byGuid[itemByGuid.Guid.String] = &models.FeedItem{
+				ID:              itemByGuid.ID,
+				Guid:            itemByGuid.Guid,
+				Title:           itemByGuid.Title,
+				Description:     itemByGuid.Description,
+				Link:            itemByGuid.Link,
+				Author:          itemByGuid.Author,
+				Published:       itemByGuid.Published,
+				Content:         itemByGuid.Content,
+				DescriptionRaw:  itemByGuid.DescriptionRaw,
+				ContentRaw:      itemByGuid.ContentRaw,
+				SourceUpdated:   itemByGuid.SourceUpdated,
+				CanonicalLink:   itemByGuid.CanonicalLink,
+				OriginChannelID: itemByGuid.OriginChannelID,
+			}
+		}
+	}
+
+	if len(canonicalLinks) > 0 {
+		itemsByLink, err := queries.ListFeedItemByCanonicalLinks(ctx, models.ListFeedItemByCanonicalLinksParams{
+			ChannelID:      feedChannelID,
+			CanonicalLinks: canonicalLinks,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, itemByLink := range itemsByLink {
+			link := itemByLink.CanonicalLink.String
+			lookup.byLink[link] = append(lookup.byLink[link], &linkedItem{
+				item: &models.FeedItem{
+					ID:              itemByLink.ID,
+					Guid:            itemByLink.Guid,
+					Title:           itemByLink.Title,
+					Description:     itemByLink.Description,
+					Link:            itemByLink.Link,
+					Author:          itemByLink.Author,
+					Published:       itemByLink.Published,
+					Content:         itemByLink.Content,
+					DescriptionRaw:  itemByLink.DescriptionRaw,
+					ContentRaw:      itemByLink.ContentRaw,
+					SourceUpdated:   itemByLink.SourceUpdated,
+					CanonicalLink:   itemByLink.CanonicalLink,
+					OriginChannelID: itemByLink.OriginChannelID,
+				},
+				inChannel: itemByLink.InChannel != 0,
+			})
+			lookup.links[itemByLink.ID] = link
+		}
+	}
+
+	if len(guids) > 0 || len(canonicalLinks) > 0 {
+		tombstones, err := queries.ListFeedItemTombstones(ctx, models.ListFeedItemTombstonesParams{
+			ChannelID:      feedChannelID,
+			Guids:          guids,
+			CanonicalLinks: canonicalLinks,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, tombstone := range tombstones {
+			if tombstone.Guid.String != "" && tombstone.ChannelID == feedChannelID {
+				lookup.purgedGuids[tombstone.Guid.String] = true
+			}
+			if tombstone.CanonicalLink.Valid {
+				lookup.purgedLinks[tombstone.CanonicalLink.String] = true
+			}
+		}
+	}
+	return lookup, nil
+}
+
+// find returns the stored version of the item. The GUID is looked up among the items of the channel only,
+// so another publisher's GUIDs can't take over them. Otherwise an item with the same canonical link is the same one,
+// unless the channel published both with different GUIDs. merged is set when the item comes from another channel.
+func (l *itemLookup) find(feedItemNew *models.CreateFeedItemParams) (item *models.FeedItem, merged bool) {
+	if feedItemNew.Guid.String != "" {
+		if itemByGuid, ok := l.byGuid[feedItemNew.Guid.String]; ok {
+			return itemByGuid, false
+		}
+	}
+
+	// Links to the home page don't identify items
+	if !feedItemNew.CanonicalLink.Valid || isSiteRoot(feedItemNew.CanonicalLink.String) {
+		return nil, false
+	}
+	for _, itemByLink := range l.byLink[feedItemNew.CanonicalLink.String] {
+		ownItem := itemByLink.inChannel && itemByLink.item.OriginChannelID.Int64 == l.channelID
+		if ownItem && itemByLink.item.Guid.String != "" && feedItemNew.Guid.String != "" && itemByLink.item.Guid.String != feedItemNew.Guid.String {
+			// The channel publishes several items with the same link
+			continue
+		}
+		return itemByLink.item, !itemByLink.inChannel
+	}
+	return nil, false
+}
+
+// purged tells if the item was deleted by the user and purged from the trash. It's matched like find does,
+// by the GUID among the purged items of the channel or by the canonical link among the purged items of all channels.
+func (l *itemLookup) purged(feedItemNew *models.CreateFeedItemParams) bool {
+	if feedItemNew.Guid.String != "" && l.purgedGuids[feedItemNew.Guid.String] {
+		return true
+	}
+	return feedItemNew.CanonicalLink.Valid && !isSiteRoot(feedItemNew.CanonicalLink.String) &&
+		l.purgedLinks[feedItemNew.CanonicalLink.String]
+}
+
+// add records the item stored in the channel, so a repeated item later in the feed is found
+func (l *itemLookup) add(item *models.FeedItem) {
+	if item.Guid.String != "" {
+		if _, ok := l.byGuid[item.Guid.String]; !ok {
+			l.byGuid[item.Guid.String] = item
+		}
+	}
+	if oldLink, ok := l.links[item.ID]; ok {
+		l.byLink[oldLink] = slices.DeleteFunc(l.byLink[oldLink], func(linked *linkedItem) bool {
+			return linked.item.ID == item.ID
+		})
+		delete(l.links, item.ID)
+	}
+	if !item.CanonicalLink.Valid {
+		return
+	}
+	link := item.CanonicalLink.String
+	i, _ := slices.BinarySearchFunc(l.byLink[link], item.ID, func(linked *linkedItem, id int64) int {
+		return int(linked.item.ID - id)
+	})
+	l.byLink[link] = slices.Insert(l.byLink[link], i, &linkedItem{item: item, inChannel: true})
+	l.links[item.ID] = link
+}
@@ -0,0 +1,136 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"context"
+	"database/sql"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/rss"
+)
+
+// Number of the latest items used to learn the publishing cadence of a feed
+const cadenceSampleSize = 20
+
+// Periods of the syndication module (sy:updatePeriod)
+var syndicationPeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+// newFeedParser returns a parser which keeps the hints about the update interval
+func newFeedParser() *gofeed.Parser {
+	parser := gofeed.NewParser()
+	parser.RSSTranslator = &rssTranslator{}
+	return parser
+}
+
+// rssTranslator keeps the <ttl> element, which is dropped by the default translator
+type rssTranslator struct {
+	gofeed.DefaultRSSTranslator
+}
+
+func (t *rssTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	result, err := t.DefaultRSSTranslator.Translate(feed)
+	if err != nil {
+		return nil, err
+	}
+	if rssFeed, ok := feed.(*rss.Feed); ok && rssFeed.TTL != "" {
+		if result.Custom == nil {
+			result.Custom = make(map[string]string)
+		}
+		result.Custom["ttl"] = rssFeed.TTL
+	}
+	return result, nil
+}
+
+// feedHintInterval returns the interval the publisher asks for with <ttl> or sy:updatePeriod, 0 if there is none
+func feedHintInterval(feed *gofeed.Feed) time.Duration {
+	var hint time.Duration
+	if ttl, err := strconv.Atoi(strings.TrimSpace(feed.Custom["ttl"])); err == nil && ttl > 0 {
+		hint = time.Duration(ttl) * time.Minute
+	}
+
+	syExtension := feed.Extensions["sy"]
+	if len(syExtension["updatePeriod"]) == 0 {
+		return hint
+	}
+	period, ok := syndicationPeriods[strings.ToLower(strings.TrimSpace(syExtension["updatePeriod"][0].Value))]
+	if !ok {
+		return hint
+	}
+	frequency := 1
+	if len(syExtension["updateFrequency"]) > 0 {
+		if f, err := strconv.Atoi(strings.TrimSpace(syExtension["updateFrequency"][0].Value)); err == nil && f > 0 {
+			frequency = f
+		}
+	}
+	return max(hint, period/time.Duration(frequency))
+}
+
+// nextFetchInterval returns the delay before the next fetch of a successfully updated channel
+// and the interval learned by the adaptive mode, if it's enabled
+func nextFetchInterval(ctx context.Context, queries *models.Queries, feedChannelInfo *models.GetFeedChannelForFetchRow, config *utils.Config, result *fetchResult) (time.Duration, sql.NullInt64) {
+	interval := config.FeedsUpdateInterval
+	if feedChannelInfo.UpdateInterval.Valid {
+		interval = time.Duration(feedChannelInfo.UpdateInterval.Int64) * time.Minute
+	}
+	if !feedChannelInfo.AdaptiveInterval {
+		return interval, sql.NullInt64{}
+	}
+
+	if result.statusCode == http.StatusNotModified && feedChannelInfo.LearnedInterval.Valid {
+		// Nothing new has been published, the feed hints are unknown without the body
+		learned := feedChannelInfo.LearnedInterval
+		return time.Duration(learned.Int64) * time.Minute, learned
+	}
+
+	published, err := queries.ListFeedChannelPublished(ctx, models.ListFeedChannelPublishedParams{
+		ChannelID: feedChannelInfo.ID,
+		Limit:     cadenceSampleSize,
+	})
+	if err != nil {
+		internal.ErrorLogger.Printf("Error listing publication dates of feed channel #%v: %v", feedChannelInfo.ID, err)
+		return interval, sql.NullInt64{}
+	}
+	if cadence := publishingCadence(published); cadence > 0 {
+		// Fetch twice per typical gap between items to pick new items up reasonably fast
+		interval = cadence / 2
+	}
+	interval = max(interval, result.hintInterval)
+	interval = min(max(interval, config.AdaptiveInterval.Min), config.AdaptiveInterval.Max).Round(time.Minute)
+
+	return interval, sql.NullInt64{Int64: int64(interval / time.Minute), Valid: true}
+}
+
+// publishingCadence returns the median gap between publication dates, 0 if there are too few of them
+func publishingCadence(published []null.Time) time.Duration {
+	dates := make([]time.Time, 0, len(published))
+	for _, p := range published {
+		if p.Valid {
+			dates = append(dates, p.Time)
+		}
+	}
+	if len(dates) < 3 {
+		return 0
+	}
+	slices.SortFunc(dates, func(a, b time.Time) int { return b.Compare(a) })
+
+	gaps := make([]time.Duration, 0, len(dates)-1)
+	for i := 1; i < len(dates); i++ {
+		gaps = append(gaps, dates[i-1].Sub(dates[i]))
+	}
+	slices.Sort(gaps)
+	return gaps[len(gaps)/2]
+}
@@ -0,0 +1,180 @@
+package gatherer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+// Finished jobs are kept for polling this long
+const jobRetention = time.Hour
+
+// Job states
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+)
+
+// Fetch states of the channels of a job
+const (
+	JobChannelQueued    = "queued"
+	JobChannelSucceeded = "succeeded"
+	JobChannelFailed    = "failed"
+	JobChannelSkipped   = "skipped" // The channel was deleted before it was fetched
+)
+
+// Job tracks the fetches requested on demand. A channel of the job is done by the first fetch
+// which starts after the request, a fetch already in progress doesn't count.
+type Job struct {
+	ID        int64        `json:"id"`
+	Status    string       `json:"status"`
+	Created   time.Time    `json:"created"`
+	Finished  null.Time    `json:"finished"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+	ItemsNew  int64        `json:"items_new"`
+	Channels  []JobChannel `json:"channels"`
+}
+
+// JobChannel is the outcome of the fetch of a channel requested by a job
+type JobChannel struct {
+	ChannelID    int64       `json:"channel_id"`
+	Status       string      `json:"status"`
+	ItemsNew     int64       `json:"items_new"`
+	ItemsUpdated int64       `json:"items_updated"`
+	Error        null.String `json:"error"`
+}
+
+type trackedJob struct {
+	job     Job
+	pending map[int64]int // Indexes of the channels waiting for a fetch
+	done    chan struct{}
+}
+
+// jobRegistry keeps the jobs for the API, which reads them while the scheduler completes them
+type jobRegistry struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*trackedJob
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[int64]*trackedJob)}
+}
+
+func (r *jobRegistry) create(channelIDs []int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for id, tracked := range r.jobs {
+		if tracked.job.Finished.Valid && now.Sub(tracked.job.Finished.Time) > jobRetention {
+			delete(r.jobs, id)
+		}
+	}
+
+	r.nextID++
+	tracked := &trackedJob{
+		job:     Job{ID: r.nextID, Status: JobRunning, Created: now, Channels: make([]JobChannel, 0, len(channelIDs))},
+		pending: make(map[int64]int, len(channelIDs)),
+		done:    make(chan struct{}),
+	}
+	for _, channelID := range channelIDs {
+		if _, ok := tracked.pending[channelID]; ok {
+			continue
+		}
+		tracked.pending[channelID] = len(tracked.job.Channels)
+		tracked.job.Channels = append(tracked.job.Channels, JobChannel{ChannelID: channelID, Status: JobChannelQueued})
+	}
+	r.jobs[tracked.job.ID] = tracked
+	tracked.finishIfDone(now)
+	return tracked.job.ID
+}
+
+// complete records the fetch in the jobs waiting for the channel
+func (r *jobRegistry) complete(outcome fetchOutcome) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, tracked := range r.jobs {
+		if !outcome.started.Before(tracked.job.Created) {
+			tracked.record(outcome)
+		}
+	}
+}
+
+// abort fails the channels of the job whose fetch could not be requested
+func (r *jobRegistry) abort(jobID int64, channelIDs []int64, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if tracked, ok := r.jobs[jobID]; ok {
+		for _, channelID := range channelIDs {
+			tracked.record(fetchOutcome{channelID: channelID, err: err})
+		}
+	}
+}
+
+// abortAll fails the channels still waiting for a fetch in all jobs
+func (r *jobRegistry) abortAll(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, tracked := range r.jobs {
+		for channelID := range tracked.pending {
+			tracked.record(fetchOutcome{channelID: channelID, err: err})
+		}
+	}
+}
+
+// wait returns the job once it's done or the context is over, whichever comes first
+func (r *jobRegistry) wait(ctx context.Context, jobID int64) (Job, bool) {
+	r.mu.Lock()
+	tracked, ok := r.jobs[jobID]
+	r.mu.Unlock()
+	if !ok {
+		return Job{}, false
+	}
+	select {
+	case <-tracked.done:
+	case <-ctx.Done():
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job := tracked.job
+	job.Channels = append([]JobChannel(nil), tracked.job.Channels...)
+	return job, true
+}
+
+func (t *trackedJob) record(outcome fetchOutcome) {
+	i, ok := t.pending[outcome.channelID]
+	if !ok {
+		return
+	}
+	delete(t.pending, outcome.channelID)
+	channel := &t.job.Channels[i]
+	switch {
+	case outcome.skipped:
+		channel.Status = JobChannelSkipped
+		t.job.Skipped++
+	case outcome.err != nil:
+		channel.Status = JobChannelFailed
+		channel.Error = null.StringFrom(outcome.err.Error())
+		t.job.Failed++
+	default:
+		channel.Status = JobChannelSucceeded
+		t.job.Succeeded++
+	}
+	channel.ItemsNew = outcome.itemsNew
+	channel.ItemsUpdated = outcome.itemsUpdated
+	t.job.ItemsNew += outcome.itemsNew
+	t.finishIfDone(time.Now())
+}
+
+func (t *trackedJob) finishIfDone(now time.Time) {
+	if len(t.pending) > 0 || t.job.Status == JobDone {
+		return
+	}
+	t.job.Status = JobDone
+	t.job.Finished = null.TimeFrom(now)
+	close(t.done)
+}
@@ -0,0 +1,87 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/guregu/null"
+	"github.com/mmcdole/gofeed"
+)
+
+// channelMetadataFields are the FeedChannel fields synced from the feed
+var channelMetadataFields = []string{"Title", "Description", "SiteLink", "Language", "ImageUrl", "Generator", "LastBuildDate"}
+
+// syncChannelMetadata updates the channel with the metadata of the parsed feed. The title and description
+// of a channel with metadata_locked are kept as the user set them, and empty ones are never taken from the feed.
+func syncChannelMetadata(ctx context.Context, queries *models.Queries, feedChannelID int64, feed *gofeed.Feed) error {
+	row, err := queries.GetFeedChannelMetadata(ctx, feedChannelID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The channel was moved to the trash during the fetch
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	current := models.FeedChannel{
+		ID:            row.ID,
+		Title:         row.Title,
+		Description:   row.Description,
+		SiteLink:      row.SiteLink,
+		Language:      row.Language,
+		ImageUrl:      row.ImageUrl,
+		Generator:     row.Generator,
+		LastBuildDate: row.LastBuildDate,
+	}
+
+	synced := current
+	if !row.MetadataLocked {
+		if title := strings.TrimSpace(utils.Truncate(strings.TrimSpace(feed.Title), utils.MaxChannelTitle)); title != "" {
+			synced.Title = title
+		}
+		if description := strings.TrimSpace(feed.Description); description != "" {
+			synced.Description = description
+		}
+	}
+	synced.SiteLink = nonEmptyString(feed.Link)
+	synced.Language = nonEmptyString(feed.Language)
+	synced.ImageUrl = null.String{}
+	if feed.Image != nil && feed.Image.URL != "" {
+		synced.ImageUrl = null.StringFrom(feed.Image.URL)
+	}
+	synced.Generator = nonEmptyString(feed.Generator)
+	synced.LastBuildDate = null.TimeFromPtr(feed.UpdatedParsed)
+	if synced.LastBuildDate.Valid {
+		synced.LastBuildDate.Time = synced.LastBuildDate.Time.UTC()
+	}
+
+	var changed []string
+	for _, field := range channelMetadataFields {
+		isEqual, _, err := utils.CompareFeedChannels(&current, &synced, []string{field})
+		if err != nil {
+			return err
+		}
+		if !isEqual {
+			changed = append(changed, field)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	internal.InfoLogger.Printf("Feed channel #%v metadata changed: %s", feedChannelID, strings.Join(changed, ", "))
+	return queries.UpdateFeedChannelMetadata(ctx, models.UpdateFeedChannelMetadataParams{
+		Title:         synced.Title,
+		Description:   synced.Description,
+		SiteLink:      synced.SiteLink,
+		Language:      synced.Language,
+		ImageUrl:      synced.ImageUrl,
+		Generator:     synced.Generator,
+		LastBuildDate: synced.LastBuildDate,
+		ID:            feedChannelID,
+	})
+}
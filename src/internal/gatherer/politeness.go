@@ -0,0 +1,137 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal/utils"
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A request waiting for a free slot of a host checks again this often
+const hostSlotPollInterval = 50 * time.Millisecond
+
+// hostLimiter keeps the number of parallel requests to a host and the delay between them within
+// the politeness limits. It's used only by the scheduler loop, so it's not safe for concurrent use.
+type hostLimiter struct {
+	config *utils.Config
+	hosts  map[string]*hostState
+}
+
+type hostState struct {
+	active    int       // Requests in flight
+	nextStart time.Time // The next request can't start earlier
+}
+
+func newHostLimiter(config *utils.Config) *hostLimiter {
+	return &hostLimiter{
+		config: config,
+		hosts:  make(map[string]*hostState),
+	}
+}
+
+func (l *hostLimiter) limits(host string) (concurrency int, delay time.Duration) {
+	concurrency, delay = l.config.Politeness.HostConcurrency, l.config.Politeness.HostDelay
+	if limits, ok := l.config.Politeness.Hosts[host]; ok {
+		if limits.Concurrency > 0 {
+			concurrency = limits.Concurrency
+		}
+		if limits.Delay > 0 {
+			delay = limits.Delay
+		}
+	}
+	return concurrency, delay
+}
+
+// wait returns how long a new request to the host has to wait.
+// ok is false if the host has no free slots, so the wait time is unknown.
+func (l *hostLimiter) wait(host string, now time.Time) (wait time.Duration, ok bool) {
+	state, found := l.hosts[host]
+	if !found {
+		return 0, true
+	}
+	if concurrency, _ := l.limits(host); state.active >= concurrency {
+		return 0, false
+	}
+	return max(state.nextStart.Sub(now), 0), true
+}
+
+func (l *hostLimiter) start(host string, now time.Time) {
+	state, found := l.hosts[host]
+	if !found {
+		state = &hostState{}
+		l.hosts[host] = state
+	}
+	_, delay := l.limits(host)
+	state.active++
+	state.nextStart = now.Add(delay)
+}
+
+func (l *hostLimiter) finish(host string, now time.Time) {
+	state, found := l.hosts[host]
+	if !found {
+		return
+	}
+	state.active--
+	if state.active <= 0 && !state.nextStart.After(now) {
+		delete(l.hosts, host)
+	}
+}
+
+// hostGate applies the politeness limits to the requests made by the workers outside the scheduler loop,
+// such as the item pages downloaded for the article extraction
+type hostGate struct {
+	mu      sync.Mutex
+	limiter *hostLimiter
+}
+
+func newHostGate(config *utils.Config) *hostGate {
+	return &hostGate{limiter: newHostLimiter(config)}
+}
+
+// acquire waits until the host accepts a request and takes a slot, release frees it once the request is over
+func (g *hostGate) acquire(ctx context.Context, host string) (release func(), err error) {
+	for {
+		g.mu.Lock()
+		now := time.Now()
+		wait, ok := g.limiter.wait(host, now)
+		if ok && wait == 0 {
+			g.limiter.start(host, now)
+			g.mu.Unlock()
+			return func() {
+				g.mu.Lock()
+				defer g.mu.Unlock()
+				g.limiter.finish(host, time.Now())
+			}, nil
+		}
+		g.mu.Unlock()
+		if !ok {
+			wait = hostSlotPollInterval
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// requested records a request made to the host through the scheduler, the next one waits for the host delay
+func (g *hostGate) requested(host string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	g.limiter.start(host, now)
+	g.limiter.finish(host, now)
+}
+
+// hostKey returns the host the politeness limits are applied to
+func hostKey(link string, host string) string {
+	if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return strings.ToLower(host)
+}
@@ -0,0 +1,87 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"FeedsCollector/pkg/types"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/guregu/null"
+)
+
+// redirectHop is a redirect followed during a fetch
+type redirectHop struct {
+	statusCode int
+	url        string // Where the response redirected to
+}
+
+func isPermanentRedirect(statusCode int) bool {
+	return statusCode == http.StatusMovedPermanently || statusCode == http.StatusPermanentRedirect
+}
+
+// formatRedirects returns the redirect chain as it's stored in the database: "301 http://a/feed, 302 http://b/feed"
+func formatRedirects(redirects []redirectHop) string {
+	hops := make([]string, 0, len(redirects))
+	for _, hop := range redirects {
+		hops = append(hops, fmt.Sprintf("%d %s", hop.statusCode, hop.url))
+	}
+	return strings.Join(hops, ", ")
+}
+
+// permanentTarget returns where the feed has permanently moved. Only the permanent redirects
+// at the start of the chain count, everything after a temporary redirect may change any time.
+func permanentTarget(redirects []redirectHop) string {
+	var target string
+	for _, hop := range redirects {
+		if !isPermanentRedirect(hop.statusCode) {
+			break
+		}
+		target = hop.url
+	}
+	return target
+}
+
+// trackPermanentRedirect changes the channel link once the feed has been permanently redirected
+// to the same URL for redirects.permanent_after fetches in a row. A single misconfigured response
+// doesn't move the channel away from its publisher.
+func trackPermanentRedirect(ctx context.Context, queries *models.Queries, feedChannelInfo *models.GetFeedChannelForFetchRow, config *utils.Config, result *fetchResult) error {
+	target := permanentTarget(result.redirects)
+	if target == "" || target == feedChannelInfo.Link {
+		if !feedChannelInfo.MovedTo.Valid {
+			return nil
+		}
+		return queries.SetFeedChannelMove(ctx, models.SetFeedChannelMoveParams{ID: feedChannelInfo.ID})
+	}
+
+	count := int64(1)
+	if feedChannelInfo.MovedTo.Valid && feedChannelInfo.MovedTo.String == target {
+		count = feedChannelInfo.MovedCount + 1
+	}
+	if count < int64(config.Redirects.PermanentAfter) {
+		return queries.SetFeedChannelMove(ctx, models.SetFeedChannelMoveParams{
+			MovedTo:    null.StringFrom(target),
+			MovedCount: count,
+			ID:         feedChannelInfo.ID,
+		})
+	}
+
+	internal.InfoLogger.Printf("Feed channel #%v has moved from %s to %s", feedChannelInfo.ID, feedChannelInfo.Link, target)
+	err := queries.MoveFeedChannel(ctx, models.MoveFeedChannelParams{
+		Link: target,
+		Host: hostKey(target, feedChannelInfo.Host),
+		ID:   feedChannelInfo.ID,
+	})
+	if err != nil {
+		return err
+	}
+	return queries.CreateFeedChannelLink(ctx, models.CreateFeedChannelLinkParams{
+		ChannelID: feedChannelInfo.ID,
+		OldLink:   feedChannelInfo.Link,
+		NewLink:   target,
+		Redirects: types.Convert2NullString(formatRedirects(result.redirects)),
+	})
+}
@@ -0,0 +1,113 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"FeedsCollector/pkg/types"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleNextFetch sets the channel's next_fetch_at according to the outcome of the fetch.
+// Healthy channels are fetched again after their update interval, failing channels are retried with exponential backoff and disabled after retry.max_failures attempts.
+// Channels answering 410 Gone are disabled right away.
+func scheduleNextFetch(ctx context.Context, queries *models.Queries, feedChannelInfo *models.GetFeedChannelForFetchRow, config *utils.Config, result *fetchResult, fetchErr error) error {
+	if fetchErr == nil {
+		interval, learnedInterval := nextFetchInterval(ctx, queries, feedChannelInfo, config, result)
+		err := queries.UpdateFeedChannelSucceeded(ctx, models.UpdateFeedChannelSucceededParams{
+			LearnedInterval: learnedInterval,
+			DelaySeconds:    int64(interval.Seconds()),
+			ID:              feedChannelInfo.ID,
+		})
+		if err != nil {
+			internal.ErrorLogger.Printf("Error scheduling feed channel #%v: %v", feedChannelInfo.ID, err)
+		}
+		return err
+	}
+	if errors.Is(fetchErr, context.Canceled) {
+		// The gatherer is shutting down, it's not the channel's fault
+		return nil
+	}
+
+	var classified *fetchError
+	if errors.As(fetchErr, &classified) && classified.gone {
+		internal.InfoLogger.Printf("Feed channel #%v is gone, disabling it", feedChannelInfo.ID)
+		err := queries.MarkFeedChannelGone(ctx, models.MarkFeedChannelGoneParams{
+			DisabledReason: types.Convert2NullString("the feed is gone (410 Gone)"),
+			ID:             feedChannelInfo.ID,
+		})
+		if err != nil {
+			internal.ErrorLogger.Printf("Error disabling feed channel #%v: %v", feedChannelInfo.ID, err)
+		}
+		return err
+	}
+
+	failures := feedChannelInfo.FailureCount + 1
+	delay := backoffDelay(failures, config.Retry.BackoffBase, config.Retry.BackoffMax)
+	if classified != nil && classified.retryAfter > delay {
+		delay = classified.retryAfter
+	}
+	err := queries.UpdateFeedChannelFailed(ctx, models.UpdateFeedChannelFailedParams{
+		FailureCount: failures,
+		DelaySeconds: int64(delay.Seconds()),
+		ID:           feedChannelInfo.ID,
+	})
+	if err != nil {
+		internal.ErrorLogger.Printf("Error scheduling feed channel #%v: %v", feedChannelInfo.ID, err)
+		return err
+	}
+
+	if failures < int64(config.Retry.MaxFailures) {
+		internal.InfoLogger.Printf("Feed channel #%v failed %d time(s) in a row, next attempt in %v",
+			feedChannelInfo.ID, failures, delay)
+		return nil
+	}
+	reason := fmt.Sprintf("%d consecutive failures, last error: %v", failures, fetchErr)
+	internal.InfoLogger.Printf("Disabling feed channel #%v: %s", feedChannelInfo.ID, reason)
+	err = queries.DisableFeedChannel(ctx, models.DisableFeedChannelParams{
+		DisabledReason: types.Convert2NullString(reason),
+		ID:             feedChannelInfo.ID,
+	})
+	if err != nil {
+		internal.ErrorLogger.Printf("Error disabling feed channel #%v: %v", feedChannelInfo.ID, err)
+	}
+	return err
+}
+
+// backoffDelay returns the delay before the next attempt after the given number of consecutive failures
+func backoffDelay(failures int64, base time.Duration, maxDelay time.Duration) time.Duration {
+	delay := maxDelay
+	if failures < 32 {
+		if d := base << (failures - 1); d > 0 && d < maxDelay {
+			delay = d
+		}
+	}
+	// Equal jitter: at least half of the delay is kept, so the retries never become too eager
+	half := delay / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1)) //nolint:gosec // jitter doesn't need a secure source
+}
+
+// parseRetryAfter parses the Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// fetchJob is a channel handed to a worker
+type fetchJob struct {
+	channelID int64
+	runID     sql.NullInt64
+}
+
+// fetchOutcome is reported by a worker when a fetch is over
+type fetchOutcome struct {
+	channelID int64
+	err       error
+	skipped   bool // The channel was deleted before the fetch started
+	started   time.Time
+	duration  time.Duration
+	// Items of the feed, for the jobs waiting for the fetch
+	itemsNew     int64
+	itemsUpdated int64
+}
+
+// fetchRun accumulates the outcomes of a run: the fetches from the moment the scheduler
+// starts fetching while idle until no channel is being fetched or waiting for a worker
+type fetchRun struct {
+	id               int64
+	succeeded        int64
+	failed           int64
+	skipped          int64
+	fetchTime        time.Duration
+	slowestChannelID int64
+	slowestFetch     time.Duration
+}
+
+func (r *fetchRun) add(outcome fetchOutcome) {
+	switch {
+	case outcome.skipped:
+		r.skipped++
+	case outcome.err != nil:
+		r.failed++
+	default:
+		r.succeeded++
+	}
+	r.fetchTime += outcome.duration
+	if !outcome.skipped && outcome.duration >= r.slowestFetch {
+		r.slowestChannelID, r.slowestFetch = outcome.channelID, outcome.duration
+	}
+}
+
+// startRun records a new run, the fetches are still dispatched if that fails
+func startRun(ctx context.Context, queries *models.Queries) *fetchRun {
+	id, err := queries.CreateFetchRun(ctx, time.Now().UTC())
+	if err != nil {
+		internal.ErrorLogger.Printf("Error creating fetch run: %v", err)
+		return nil
+	}
+	return &fetchRun{id: id}
+}
+
+// save stores the summary of the run, finished marks the run as complete
+func (r *fetchRun) save(ctx context.Context, queries *models.Queries, finished bool) {
+	args := models.UpdateFetchRunParams{
+		Succeeded:        r.succeeded,
+		Failed:           r.failed,
+		Skipped:          r.skipped,
+		FetchTimeMs:      r.fetchTime.Milliseconds(),
+		SlowestChannelID: sql.NullInt64{Int64: r.slowestChannelID, Valid: r.slowestChannelID != 0},
+		SlowestFetchMs:   r.slowestFetch.Milliseconds(),
+		ID:               r.id,
+	}
+	if finished {
+		args.Finished = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		internal.InfoLogger.Printf("Fetch run #%v finished: %d succeeded, %d failed, %d skipped",
+			r.id, r.succeeded, r.failed, r.skipped)
+	}
+	if err := queries.UpdateFetchRun(ctx, args); err != nil {
+		internal.ErrorLogger.Printf("Error updating fetch run #%v: %v", r.id, err)
+	}
+}
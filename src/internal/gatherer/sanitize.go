@@ -0,0 +1,255 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal/models"
+	"context"
+	"database/sql"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/guregu/null"
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlPolicy whitelists the HTML safe to render in a web client: no scripts, frames, forms,
+// styles or event handlers. External links get rel="nofollow noreferrer noopener" and open in a new tab.
+var htmlPolicy = func() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.RequireParseableURLs(true)
+	policy.AllowURLSchemes("http", "https", "mailto")
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	policy.RequireNoReferrerOnFullyQualifiedLinks(true)
+	policy.AllowAttrs("srcset", "sizes").OnElements("img")
+	return policy
+}()
+
+// urlAttributes are the attributes holding URLs which are resolved against the base URL
+var urlAttributes = []string{"href", "src", "poster", "cite"}
+
+// trackerHosts serve tracking pixels and counters embedded into feed items
+var trackerHosts = []string{
+	"feeds.feedburner.com",
+	"feedproxy.google.com",
+	"pixel.wp.com",
+	"stats.wordpress.com",
+	"www.google-analytics.com",
+	"pixel.quantserve.com",
+	"counter.yadro.ru",
+	"mc.yandex.ru",
+	"www.facebook.com/tr",
+}
+
+// itemHTML is the HTML of an item the way it's stored: sanitized, with the plain text of the content
+// or the description for previews and search
+type itemHTML struct {
+	description string
+	content     null.String
+	text        null.String
+}
+
+// sanitizeItemHTML sanitizes the description and the content of an item. Their relative URLs point
+// to the item page, or to the site if the item has no link.
+func sanitizeItemHTML(description, content, itemLink, channelLink string) itemHTML {
+	baseLink := itemLink
+	if !isAbsoluteURL(baseLink) {
+		baseLink = channelLink
+	}
+	sanitized := itemHTML{
+		description: sanitizeHTML(description, baseLink),
+		content:     nonEmptyString(sanitizeHTML(content, baseLink)),
+	}
+	text := plainText(sanitized.description)
+	if sanitized.content.Valid {
+		text = plainText(sanitized.content.String)
+	}
+	sanitized.text = nonEmptyString(text)
+	return sanitized
+}
+
+// BackfillSanitizedItems sanitizes the HTML of the items stored before migration 11, which kept it
+// as published. The published HTML is moved to description_raw and content_raw like the gatherer
+// stores it now. It returns the number of items updated.
+func BackfillSanitizedItems(ctx context.Context, db *sql.DB) (int, error) {
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		// Does nothing once the transaction is committed
+		_ = tx.Rollback()
+	}()
+	queries := models.New(db).WithTx(tx)
+	updated := 0
+	var afterID int64
+	for {
+		items, err := queries.ListUnsanitizedFeedItems(ctx, models.ListUnsanitizedFeedItemsParams{
+			AfterID: afterID,
+			Limit:   backfillBatchSize,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			afterID = item.ID
+			sanitized := sanitizeItemHTML(item.Description.String, item.Content.String, item.Link, item.ChannelLink)
+			err := queries.UpdateFeedItemSanitized(ctx, models.UpdateFeedItemSanitizedParams{
+				Description:    null.StringFrom(sanitized.description),
+				Content:        sanitized.content,
+				DescriptionRaw: nonEmptyString(item.Description.String),
+				ContentRaw:     nonEmptyString(item.Content.String),
+				Text:           sanitized.text,
+				ID:             item.ID,
+			})
+			if err != nil {
+				return 0, err
+			}
+			updated++
+		}
+	}
+	return updated, tx.Commit()
+}
+
+// sanitizeHTML makes the item HTML safe to render: relative URLs are resolved against baseURL,
+// tracking pixels are removed and everything not allowed by htmlPolicy is stripped.
+func sanitizeHTML(raw string, baseURL string) string {
+	if strings.TrimSpace(raw) == "" {
+		return ""
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(raw))
+	if err != nil {
+		return htmlPolicy.Sanitize(raw)
+	}
+	var base *url.URL
+	if isAbsoluteURL(baseURL) {
+		base, _ = url.Parse(strings.TrimSpace(baseURL))
+	}
+
+	doc.Find("img").Each(func(_ int, img *goquery.Selection) {
+		if isTrackingPixel(img) {
+			img.Remove()
+		}
+	})
+	if base != nil {
+		for _, attr := range urlAttributes {
+			doc.Find("[" + attr + "]").Each(func(_ int, selection *goquery.Selection) {
+				selection.SetAttr(attr, resolveURL(base, selection.AttrOr(attr, "")))
+			})
+		}
+		doc.Find("[srcset]").Each(func(_ int, selection *goquery.Selection) {
+			selection.SetAttr("srcset", resolveSrcset(base, selection.AttrOr("srcset", "")))
+		})
+	}
+
+	body, err := doc.Find("body").Html()
+	if err != nil {
+		return htmlPolicy.Sanitize(raw)
+	}
+	return strings.TrimSpace(htmlPolicy.Sanitize(body))
+}
+
+func isTrackingPixel(img *goquery.Selection) bool {
+	width, widthErr := strconv.Atoi(strings.TrimSuffix(img.AttrOr("width", ""), "px"))
+	height, heightErr := strconv.Atoi(strings.TrimSuffix(img.AttrOr("height", ""), "px"))
+	if widthErr == nil && heightErr == nil && width <= 1 && height <= 1 {
+		return true
+	}
+	src, err := url.Parse(img.AttrOr("src", ""))
+	if err != nil {
+		return false
+	}
+	location := strings.ToLower(src.Host + src.Path)
+	for _, host := range trackerHosts {
+		if strings.HasPrefix(location, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveURL(base *url.URL, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.HasPrefix(value, "#") {
+		return value
+	}
+	resolved, err := base.Parse(value)
+	if err != nil {
+		return value
+	}
+	return resolved.String()
+}
+
+// resolveSrcset resolves the URLs of "image.jpg 1x, image@2x.jpg 2x"
+func resolveSrcset(base *url.URL, srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = resolveURL(base, fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// blockElements are separated by line breaks in the plain text
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "blockquote": true, "pre": true, "figure": true, "figcaption": true,
+	"table": true, "ul": true, "ol": true, "dl": true, "dt": true, "dd": true, "hr": true, "section": true, "article": true,
+}
+
+// plainText returns the text of the HTML for previews and search, paragraphs are separated with blank lines
+func plainText(rawHTML string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(rawHTML), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return ""
+	}
+	var builder strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			builder.WriteString(node.Data)
+		case html.ElementNode:
+			if node.Data == "script" || node.Data == "style" {
+				return
+			}
+			if blockElements[node.Data] {
+				builder.WriteString("\n")
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if node.Type == html.ElementNode && blockElements[node.Data] {
+			builder.WriteString("\n")
+		}
+	}
+	for _, node := range nodes {
+		walk(node)
+	}
+
+	var paragraphs []string
+	for _, line := range strings.Split(builder.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func isAbsoluteURL(link string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	return err == nil && parsed.IsAbs() && parsed.Host != ""
+}
@@ -0,0 +1,422 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"FeedsCollector/internal/utils"
+	"container/heap"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// The schedule is re-read from the database to pick up channels added or changed through the API
+	scheduleReloadInterval = time.Minute
+	fetchRequestsBuffer    = 64
+)
+
+// Scheduler keeps channels in a queue ordered by their due time and dispatches them
+// to a pool of workers as they come due. A channel is never fetched twice at the same time
+// and requests to the same host are kept within the politeness limits.
+type Scheduler struct {
+	db       *sql.DB
+	config   *utils.Config
+	fetcher  *Fetcher
+	requests chan int64
+	stopped  chan struct{}
+
+	queue    dueQueue
+	queued   map[int64]*dueChannel
+	busy     map[int64]string // Hosts of channels waiting for a free worker or being fetched
+	refetch  map[int64]bool   // Busy channels requested to be fetched again right after the current fetch
+	ready    []*dueChannel
+	hosts    *hostLimiter
+	jobs     chan fetchJob
+	finished chan fetchOutcome
+	run      *fetchRun // Nil while no channel is being fetched
+
+	refreshJobs *jobRegistry
+}
+
+// errSchedulerStopped is reported for the fetches requested after the scheduler has stopped
+var errSchedulerStopped = errors.New("the scheduler has been stopped")
+
+func NewScheduler(db *sql.DB, config *utils.Config, fetcher *Fetcher) *Scheduler {
+	return &Scheduler{
+		db:       db,
+		config:   config,
+		fetcher:  fetcher,
+		requests: make(chan int64, fetchRequestsBuffer),
+		stopped:  make(chan struct{}),
+		queued:   make(map[int64]*dueChannel),
+		busy:     make(map[int64]string),
+		refetch:  make(map[int64]bool),
+		hosts:    newHostLimiter(config),
+		jobs:     make(chan fetchJob),
+		finished: make(chan fetchOutcome),
+
+		refreshJobs: newJobRegistry(),
+	}
+}
+
+// FetchNow asks the scheduler to fetch the channel as soon as possible.
+// It returns false if the scheduler has been stopped.
+func (s *Scheduler) FetchNow(channelID int64) bool {
+	select {
+	case <-s.stopped:
+		return false
+	default:
+	}
+	select {
+	case s.requests <- channelID:
+		return true
+	case <-s.stopped:
+		return false
+	}
+}
+
+// Refresh asks the scheduler to fetch the channels as soon as possible and returns the ID of the job
+// tracking the fetches. It fails if the scheduler has been stopped.
+func (s *Scheduler) Refresh(channelIDs []int64) (int64, error) {
+	jobID := s.refreshJobs.create(channelIDs)
+	for i, channelID := range channelIDs {
+		if !s.FetchNow(channelID) {
+			s.refreshJobs.abort(jobID, channelIDs[i:], errSchedulerStopped)
+			return jobID, errSchedulerStopped
+		}
+	}
+	return jobID, nil
+}
+
+// WaitJob waits for the job to be done and returns its state, or the current state once the context is over.
+// It returns false if the job is unknown or has expired.
+func (s *Scheduler) WaitJob(ctx context.Context, jobID int64) (Job, bool) {
+	return s.refreshJobs.wait(ctx, jobID)
+}
+
+// Run dispatches due channels until the context is cancelled. On cancellation, in-flight fetches
+// are given scheduler.shutdown_timeout to finish before they are aborted.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer close(s.stopped)
+
+	workersCtx, abortWorkers := context.WithCancel(context.WithoutCancel(ctx))
+	defer abortWorkers()
+	var wgWorkers sync.WaitGroup
+	wgWorkers.Add(s.config.Scheduler.Workers)
+	for i := 0; i < s.config.Scheduler.Workers; i++ {
+		go s.worker(workersCtx, &wgWorkers)
+	}
+
+	s.reload(ctx)
+	reloadTicker := time.NewTicker(scheduleReloadInterval)
+	defer reloadTicker.Stop()
+	s.purgeTrash(ctx)
+	purgeTicker := time.NewTicker(trashPurgeInterval)
+	defer purgeTicker.Stop()
+
+	for {
+		readyIndex, hostWait := s.nextReady(time.Now())
+		wait := s.nextDue()
+		if readyIndex < 0 && hostWait > 0 {
+			wait = min(wait, hostWait)
+		}
+		dueTimer := time.NewTimer(wait)
+		// Sending to a nil channel blocks, so the case is enabled only when a channel can be fetched
+		var jobs chan fetchJob
+		var job fetchJob
+		if readyIndex >= 0 {
+			if s.run == nil {
+				s.run = startRun(ctx, models.New(s.db))
+			}
+			jobs = s.jobs
+			job = fetchJob{channelID: s.ready[readyIndex].id}
+			if s.run != nil {
+				job.runID = sql.NullInt64{Int64: s.run.id, Valid: true}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			dueTimer.Stop()
+			s.shutdown(&wgWorkers, abortWorkers)
+			return
+		case <-dueTimer.C:
+			s.dispatchDue(time.Now())
+		case <-reloadTicker.C:
+			s.reload(ctx)
+		case <-purgeTicker.C:
+			s.purgeTrash(ctx)
+		case channelID := <-s.requests:
+			s.fetchNow(ctx, channelID)
+		case jobs <- job:
+			s.hosts.start(s.ready[readyIndex].host, time.Now())
+			s.ready = slices.Delete(s.ready, readyIndex, readyIndex+1)
+		case outcome := <-s.finished:
+			s.complete(ctx, outcome)
+		}
+		dueTimer.Stop()
+	}
+}
+
+func (s *Scheduler) worker(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for job := range s.jobs {
+		started := time.Now()
+		outcome := s.fetchChannel(ctx, job)
+		outcome.started = started
+		outcome.duration = time.Since(started)
+		s.finished <- outcome
+	}
+}
+
+// fetchChannel updates the channel, a failure of any kind affects this channel only
+func (s *Scheduler) fetchChannel(ctx context.Context, job fetchJob) (outcome fetchOutcome) {
+	outcome.channelID = job.channelID
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			internal.ErrorLogger.Printf("Fetch of feed channel #%v panicked: %v\n%s", job.channelID, recovered, debug.Stack())
+			outcome.err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+
+	feedChannelInfo, err := models.New(s.db).GetFeedChannelForFetch(ctx, job.channelID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			outcome.skipped = true
+			return outcome
+		}
+		internal.ErrorLogger.Printf("Error loading feed channel #%v: %v", job.channelID, err)
+		outcome.err = err
+		return outcome
+	}
+	// updateFeed records failures in the channel log, the error only counts in the run summary
+	result, err := updateFeed(ctx, s.fetcher, &feedChannelInfo, s.db, s.config, job.runID)
+	outcome.err = err
+	outcome.itemsNew, outcome.itemsUpdated = result.itemsNew, result.itemsUpdated
+	return outcome
+}
+
+func (s *Scheduler) shutdown(wgWorkers *sync.WaitGroup, abortWorkers context.CancelFunc) {
+	internal.InfoLogger.Printf("Stopping the scheduler, %d fetch(es) in flight", len(s.busy)-len(s.ready))
+	close(s.jobs)
+
+	workersDone := make(chan struct{})
+	go func() {
+		wgWorkers.Wait()
+		close(workersDone)
+	}()
+
+	deadline := time.NewTimer(s.config.Scheduler.ShutdownTimeout)
+	defer deadline.Stop()
+	for {
+		select {
+		case outcome := <-s.finished:
+			// Workers report finished fetches until they exit
+			s.refreshJobs.complete(outcome)
+			if s.run != nil {
+				s.run.add(outcome)
+			}
+		case <-deadline.C:
+			internal.InfoLogger.Printf("Aborting in-flight fetches")
+			abortWorkers()
+		case <-workersDone:
+			s.refreshJobs.abortAll(errSchedulerStopped)
+			if s.run != nil {
+				// Channels which were waiting for a worker are not a part of the run
+				s.run.save(context.Background(), models.New(s.db), true)
+				s.run = nil
+			}
+			s.fetcher.CloseIdleConnections()
+			return
+		}
+	}
+}
+
+// reload synchronizes the queue with the schedule stored in the database
+func (s *Scheduler) reload(ctx context.Context) {
+	channels, err := models.New(s.db).ListFeedChannel(ctx)
+	if err != nil {
+		internal.ErrorLogger.Printf("Error listing feed channels: %v", err)
+		return
+	}
+
+	enabled := make(map[int64]bool, len(channels))
+	for _, channel := range channels {
+		enabled[channel.ID] = true
+		if _, busy := s.busy[channel.ID]; !busy {
+			s.schedule(channel.ID, hostKey(channel.Link, channel.Host), dueTime(channel.NextFetchAt))
+		}
+	}
+	for channelID := range s.queued {
+		if !enabled[channelID] {
+			s.unschedule(channelID)
+		}
+	}
+	s.dispatchDue(time.Now())
+}
+
+func (s *Scheduler) fetchNow(ctx context.Context, channelID int64) {
+	if _, busy := s.busy[channelID]; busy {
+		s.refetch[channelID] = true
+		return
+	}
+	if channel, ok := s.queued[channelID]; ok {
+		s.schedule(channelID, channel.host, time.Now())
+	} else {
+		// The channel may be disabled, but it's fetched anyway when it's explicitly requested
+		channel, err := s.loadSchedule(ctx, channelID)
+		if err != nil {
+			// Jobs waiting for the channel won't get a fetch
+			s.refreshJobs.complete(fetchOutcome{channelID: channelID, err: err, skipped: errors.Is(err, sql.ErrNoRows), started: time.Now()})
+			return
+		}
+		s.schedule(channelID, hostKey(channel.Link, channel.Host), time.Now())
+	}
+	s.dispatchDue(time.Now())
+}
+
+// complete puts a fetched channel back into the queue according to its new schedule
+// and finishes the run once no channel is being fetched or waiting for a worker
+func (s *Scheduler) complete(ctx context.Context, outcome fetchOutcome) {
+	channelID := outcome.channelID
+	s.hosts.finish(s.busy[channelID], time.Now())
+	delete(s.busy, channelID)
+	s.refreshJobs.complete(outcome)
+	if s.run != nil {
+		s.run.add(outcome)
+		defer func() {
+			finished := len(s.busy) == 0
+			s.run.save(ctx, models.New(s.db), finished)
+			if finished {
+				s.run = nil
+			}
+		}()
+	}
+	if s.refetch[channelID] {
+		delete(s.refetch, channelID)
+		s.fetchNow(ctx, channelID)
+		return
+	}
+
+	channel, err := s.loadSchedule(ctx, channelID)
+	if err != nil {
+		return
+	}
+	if channel.Enabled {
+		s.schedule(channelID, hostKey(channel.Link, channel.Host), dueTime(channel.NextFetchAt))
+	}
+}
+
+func (s *Scheduler) loadSchedule(ctx context.Context, channelID int64) (models.GetFeedChannelScheduleRow, error) {
+	channel, err := models.New(s.db).GetFeedChannelSchedule(ctx, channelID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		internal.ErrorLogger.Printf("Error loading schedule of feed channel #%v: %v", channelID, err)
+	}
+	return channel, err
+}
+
+// dispatchDue moves all due channels from the queue to the ready list
+func (s *Scheduler) dispatchDue(now time.Time) {
+	for s.queue.Len() > 0 && !s.queue[0].due.After(now) {
+		channel := heap.Pop(&s.queue).(*dueChannel)
+		delete(s.queued, channel.id)
+		s.busy[channel.id] = channel.host
+		s.ready = append(s.ready, channel)
+	}
+}
+
+// nextReady returns the index of the first ready channel whose host accepts a request now.
+// If there is none, it returns -1 and how long to wait for a host to become available.
+func (s *Scheduler) nextReady(now time.Time) (int, time.Duration) {
+	var minWait time.Duration
+	for i, channel := range s.ready {
+		wait, ok := s.hosts.wait(channel.host, now)
+		if !ok {
+			// All slots are taken, the host becomes available when a fetch finishes
+			continue
+		}
+		if wait == 0 {
+			return i, 0
+		}
+		if minWait == 0 || wait < minWait {
+			minWait = wait
+		}
+	}
+	return -1, minWait
+}
+
+func (s *Scheduler) schedule(channelID int64, host string, due time.Time) {
+	if channel, ok := s.queued[channelID]; ok {
+		channel.host = host
+		channel.due = due
+		heap.Fix(&s.queue, channel.index)
+		return
+	}
+	channel := &dueChannel{id: channelID, host: host, due: due}
+	heap.Push(&s.queue, channel)
+	s.queued[channelID] = channel
+}
+
+func (s *Scheduler) unschedule(channelID int64) {
+	if channel, ok := s.queued[channelID]; ok {
+		heap.Remove(&s.queue, channel.index)
+		delete(s.queued, channelID)
+	}
+}
+
+// nextDue returns how long to wait for the next channel to come due
+func (s *Scheduler) nextDue() time.Duration {
+	if s.queue.Len() == 0 {
+		return scheduleReloadInterval
+	}
+	return max(time.Until(s.queue[0].due), 0)
+}
+
+// dueTime converts next_fetch_at to the due time, channels which have never been scheduled are due right away
+func dueTime(nextFetchAt sql.NullTime) time.Time {
+	if !nextFetchAt.Valid {
+		return time.Now()
+	}
+	return nextFetchAt.Time
+}
+
+type dueChannel struct {
+	id    int64
+	host  string
+	due   time.Time
+	index int
+}
+
+// dueQueue is a min-heap of channels ordered by their due time
+type dueQueue []*dueChannel
+
+func (q dueQueue) Len() int { return len(q) }
+
+func (q dueQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+
+func (q dueQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *dueQueue) Push(x any) {
+	channel := x.(*dueChannel)
+	channel.index = len(*q)
+	*q = append(*q, channel)
+}
+
+func (q *dueQueue) Pop() any {
+	old := *q
+	n := len(old)
+	channel := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return channel
+}
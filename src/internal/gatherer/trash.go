@@ -0,0 +1,155 @@
+package gatherer
+
+import (
+	"FeedsCollector/internal"
+	"FeedsCollector/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	// The scheduler purges the trash this often
+	trashPurgeInterval = time.Hour
+	// Items and channels deleted by a query, SQLite limits the number of parameters
+	purgeBatchSize = 500
+)
+
+// purgeTrash deletes the items and channels which have been in the trash longer than trash.retention
+// and the tombstones older than trash.tombstone_retention
+func (s *Scheduler) purgeTrash(ctx context.Context) {
+	items, channels, err := purgeTrashBefore(ctx, s.db, time.Now().Add(-s.config.Trash.Retention))
+	if err != nil {
+		internal.ErrorLogger.Printf("Error purging the trash: %v", err)
+		return
+	}
+	if items > 0 || channels > 0 {
+		internal.InfoLogger.Printf("Purged %d item(s) and %d channel(s) from the trash", items, channels)
+	}
+	tombstones, err := expireTombstonesBefore(ctx, s.db, time.Now().Add(-s.config.Trash.TombstoneRetention))
+	if err != nil {
+		internal.ErrorLogger.Printf("Error expiring the tombstones of purged items: %v", err)
+		return
+	}
+	if tombstones > 0 {
+		internal.InfoLogger.Printf("Expired %d tombstone(s) of purged items", tombstones)
+	}
+}
+
+// expireTombstonesBefore deletes the tombstones of the items purged before the date,
+// the items are collected again if a feed still publishes them
+func expireTombstonesBefore(ctx context.Context, db *sql.DB, before time.Time) (int64, error) {
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	// The format of SQLite's datetime(), which normalizes the stored dates in the queries
+	return models.New(db).DeleteExpiredFeedItemTombstones(ctx, before.UTC().Format(time.DateTime))
+}
+
+// purgeTrashBefore deletes the items and channels moved to the trash before the date in a single transaction.
+// A purged item leaves a tombstone in each of its channels, so the gatherer doesn't store it again while
+// the publisher still lists it, or another channel publishes its canonical link, until the tombstone expires.
+// The items published only in the purged channels are deleted with them.
+func purgeTrashBefore(ctx context.Context, db *sql.DB, before time.Time) (items int, channels int, err error) {
+	writeLock.Lock()
+	defer writeLock.Unlock()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		// Does nothing once the transaction is committed
+		_ = tx.Rollback()
+	}()
+	queries := models.New(db).WithTx(tx)
+	// The format of SQLite's datetime(), which normalizes the stored dates in the queries
+	beforeDate := before.UTC().Format(time.DateTime)
+
+	// The items of the purged channels don't need tombstones, the channels are gone
+	for {
+		itemIDs, err := queries.ListPurgeableChannelItemIDs(ctx, models.ListPurgeableChannelItemIDsParams{
+			Before: beforeDate,
+			Limit:  purgeBatchSize,
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(itemIDs) == 0 {
+			break
+		}
+		if err := deleteItems(ctx, queries, itemIDs); err != nil {
+			return 0, 0, err
+		}
+		items += len(itemIDs)
+	}
+	for {
+		itemIDs, err := queries.ListPurgeableFeedItemIDs(ctx, models.ListPurgeableFeedItemIDsParams{
+			Before: beforeDate,
+			Limit:  purgeBatchSize,
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(itemIDs) == 0 {
+			break
+		}
+		if err := queries.CreateFeedItemTombstones(ctx, itemIDs); err != nil {
+			return 0, 0, err
+		}
+		if err := deleteItems(ctx, queries, itemIDs); err != nil {
+			return 0, 0, err
+		}
+		items += len(itemIDs)
+	}
+
+	channelIDs, err := queries.ListPurgeableFeedChannelIDs(ctx, beforeDate)
+	if err != nil {
+		return 0, 0, err
+	}
+	for start := 0; start < len(channelIDs); start += purgeBatchSize {
+		if err := deleteChannels(ctx, queries, channelIDs[start:min(start+purgeBatchSize, len(channelIDs))]); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if items > 0 {
+		if err := queries.RecountStoryClusters(ctx); err != nil {
+			return 0, 0, err
+		}
+	}
+	return items, len(channelIDs), tx.Commit()
+}
+
+// deleteItems deletes the items with the rows referencing them, the foreign keys are not enforced
+func deleteItems(ctx context.Context, queries *models.Queries, itemIDs []int64) error {
+	for _, deleteRows := range []func(context.Context, []int64) error{
+		queries.DeleteFeedChannelItemsByItems,
+		queries.DeleteItemEnclosuresByItems,
+		queries.DeleteFeedItemRevisionsByItems,
+		queries.DeleteFeedItems,
+	} {
+		if err := deleteRows(ctx, itemIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteChannels deletes the channels with the rows referencing them, the foreign keys are not enforced
+func deleteChannels(ctx context.Context, queries *models.Queries, channelIDs []int64) error {
+	for _, deleteRows := range []func(context.Context, []int64) error{
+		queries.DeleteFeedChannelItemsByChannels,
+		queries.DeleteFeedChannelLogsByChannels,
+		queries.DeleteFeedChannelLinksByChannels,
+		queries.DeleteFeedGroupChannelsByChannels,
+		queries.DeleteFeedChannelTagsByChannels,
+		queries.DeleteFeedItemTombstonesByChannels,
+		queries.DeleteFeedChannels,
+	} {
+		if err := deleteRows(ctx, channelIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
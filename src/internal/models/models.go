@@ -12,15 +12,38 @@ import (
 )
 
 type FeedChannel struct {
-	ID          int64        `json:"id"`
-	Title       string       `json:"title" validate:"required,min=5,max=20"`
-	Description string       `json:"description"`
-	Link        string       `json:"link" validate:"required,url"`
-	Host        string       `json:"host"`
-	Published   null.Time    `json:"published" validate:"required"`
-	Enabled     bool         `json:"enabled"`
-	Created     time.Time    `json:"created"`
-	Updated     sql.NullTime `json:"updated"`
+	ID               int64          `json:"id"`
+	Title            string         `json:"title" validate:"required,min=5,max=20"`
+	Description      string         `json:"description"`
+	Link             string         `json:"link" validate:"required,url"`
+	Host             string         `json:"host"`
+	Published        null.Time      `json:"published" validate:"required"`
+	Enabled          bool           `json:"enabled"`
+	Created          time.Time      `json:"created"`
+	Updated          sql.NullTime   `json:"updated"`
+	Etag             sql.NullString `json:"etag"`
+	LastModified     sql.NullString `json:"last_modified"`
+	FailureCount     int64          `json:"failure_count"`
+	NextFetchAt      sql.NullTime   `json:"next_fetch_at"`
+	DisabledReason   sql.NullString `json:"disabled_reason"`
+	UpdateInterval   null.Int       `json:"update_interval"`
+	AdaptiveInterval bool           `json:"adaptive_interval"`
+	LearnedInterval  sql.NullInt64  `json:"learned_interval"`
+	Proxy            null.String    `json:"proxy"`
+	TlsInsecure      bool           `json:"tls_insecure"`
+	OriginalLink     null.String    `json:"original_link"`
+	MovedTo          null.String    `json:"moved_to"`
+	MovedCount       int64          `json:"moved_count"`
+	Gone             bool           `json:"gone"`
+	SiteLink         null.String    `json:"site_link"`
+	Language         null.String    `json:"language"`
+	ImageUrl         null.String    `json:"image_url"`
+	Generator        null.String    `json:"generator"`
+	LastBuildDate    null.Time      `json:"last_build_date"`
+	MetadataLocked   bool           `json:"metadata_locked"`
+	FullText         bool           `json:"full_text"`
+	ContentSelector  null.String    `json:"content_selector"`
+	DeletedAt        null.Time      `json:"deleted_at"`
 }
 
 type FeedChannelItem struct {
@@ -28,10 +51,36 @@ type FeedChannelItem struct {
 	ItemID    int64 `json:"item_id" validate:"required"`
 }
 
+type FeedChannelLink struct {
+	ID        int64          `json:"id"`
+	ChannelID int64          `json:"channel_id"`
+	OldLink   string         `json:"old_link"`
+	NewLink   string         `json:"new_link"`
+	Redirects sql.NullString `json:"redirects"`
+	Changed   time.Time      `json:"changed"`
+}
+
 type FeedChannelLog struct {
-	ID         int64        `json:"id"`
-	ChannelID  int64        `json:"channel_id"`
-	LastUpdate sql.NullTime `json:"last_update"`
+	ID             int64          `json:"id"`
+	ChannelID      int64          `json:"channel_id"`
+	Started        sql.NullTime   `json:"started"`
+	LastUpdate     sql.NullTime   `json:"last_update"`
+	StatusCode     sql.NullInt64  `json:"status_code"`
+	BytesReceived  int64          `json:"bytes_received"`
+	ErrorClass     sql.NullString `json:"error_class"`
+	ErrorMessage   sql.NullString `json:"error_message"`
+	ItemsNew       int64          `json:"items_new"`
+	ItemsUpdated   int64          `json:"items_updated"`
+	ItemsUnchanged int64          `json:"items_unchanged"`
+	Url            sql.NullString `json:"url"`
+	EffectiveUrl   sql.NullString `json:"effective_url"`
+	Redirects      sql.NullString `json:"redirects"`
+	ItemsMerged    int64          `json:"items_merged"`
+	Merges         sql.NullString `json:"merges"`
+	ItemsFailed    int64          `json:"items_failed"`
+	Failures       sql.NullString `json:"failures"`
+	RunID          sql.NullInt64  `json:"run_id"`
+	DurationMs     sql.NullInt64  `json:"duration_ms"`
 }
 
 type FeedChannelTag struct {
@@ -51,18 +100,97 @@ type FeedGroupChannel struct {
 }
 
 type FeedItem struct {
-	ID              int64        `json:"id"`
-	Guid            null.String  `json:"guid,omitempty" validate:"required"`
-	GuidIsPermalink sql.NullBool `json:"guid_is_permalink"`
-	Title           string       `json:"title"`
-	Description     null.String  `json:"description,omitempty" validate:"required"`
-	Link            string       `json:"link"`
-	Author          *string      `json:"author,omitempty" validate:"required"`
-	Published       null.Time    `json:"published" validate:"required"`
-	Read            bool         `json:"read"`
-	Deleted         bool         `json:"deleted"`
-	Created         time.Time    `json:"created"`
-	Updated         sql.NullTime `json:"updated"`
+	ID               int64        `json:"id"`
+	Guid             null.String  `json:"guid,omitempty" validate:"required"`
+	GuidIsPermalink  sql.NullBool `json:"guid_is_permalink"`
+	Title            string       `json:"title"`
+	Description      null.String  `json:"description,omitempty" validate:"required"`
+	Link             string       `json:"link"`
+	Author           *string      `json:"author,omitempty" validate:"required"`
+	Published        null.Time    `json:"published" validate:"required"`
+	Read             bool         `json:"read"`
+	Deleted          bool         `json:"deleted"`
+	Created          time.Time    `json:"created"`
+	Updated          sql.NullTime `json:"updated"`
+	Content          null.String  `json:"content,omitempty"`
+	SourceUpdated    null.Time    `json:"source_updated"`
+	DescriptionRaw   null.String  `json:"description_raw,omitempty"`
+	ContentRaw       null.String  `json:"content_raw,omitempty"`
+	Text             null.String  `json:"text"`
+	ExtractedContent null.String  `json:"extracted_content,omitempty"`
+	ExtractionStatus null.String  `json:"extraction_status"`
+	ExtractionError  null.String  `json:"extraction_error"`
+	Extracted        null.Time    `json:"extracted"`
+	Edited           bool         `json:"edited"`
+	CanonicalLink    null.String  `json:"canonical_link"`
+	OriginChannelID  null.Int     `json:"origin_channel_id"`
+	Simhash          null.Int     `json:"simhash"`
+	ClusterID        null.Int     `json:"cluster_id"`
+	Starred          bool         `json:"starred"`
+	ReadLater        bool         `json:"read_later"`
+	DeletedAt        null.Time    `json:"deleted_at"`
+}
+
+type FeedItemRevision struct {
+	ID            int64       `json:"id"`
+	ItemID        int64       `json:"item_id"`
+	Title         string      `json:"title"`
+	Description   null.String `json:"description"`
+	Link          string      `json:"link"`
+	Content       null.String `json:"content"`
+	Text          null.String `json:"text"`
+	SourceUpdated null.Time   `json:"source_updated"`
+	ValidFrom     time.Time   `json:"valid_from"`
+	Replaced      time.Time   `json:"replaced"`
+}
+
+type FeedItemTombstone struct {
+	ID            int64       `json:"id"`
+	ChannelID     int64       `json:"channel_id"`
+	Guid          null.String `json:"guid"`
+	CanonicalLink null.String `json:"canonical_link"`
+	Purged        time.Time   `json:"purged"`
+}
+
+type FetchRun struct {
+	ID               int64         `json:"id"`
+	Started          time.Time     `json:"started"`
+	Finished         sql.NullTime  `json:"finished"`
+	Succeeded        int64         `json:"succeeded"`
+	Failed           int64         `json:"failed"`
+	Skipped          int64         `json:"skipped"`
+	FetchTimeMs      int64         `json:"fetch_time_ms"`
+	SlowestChannelID sql.NullInt64 `json:"slowest_channel_id"`
+	SlowestFetchMs   int64         `json:"slowest_fetch_ms"`
+}
+
+type ItemEnclosure struct {
+	ID        int64       `json:"id"`
+	ItemID    int64       `json:"item_id"`
+	Url       string      `json:"url"`
+	MimeType  null.String `json:"mime_type"`
+	Medium    null.String `json:"medium"`
+	Length    null.Int    `json:"length"`
+	Duration  null.Int    `json:"duration"`
+	Thumbnail null.String `json:"thumbnail"`
+}
+
+type ItemSearch struct {
+	Title            string `json:"title"`
+	Text             string `json:"text"`
+	Author           string `json:"author"`
+	Description      string `json:"description"`
+	Content          string `json:"content"`
+	ExtractedContent string `json:"extracted_content"`
+	ItemSearch       string `json:"item_search"`
+}
+
+type StoryCluster struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	ItemCount int64     `json:"item_count"`
+	Created   time.Time `json:"created"`
+	Updated   time.Time `json:"updated"`
 }
 
 type Tag struct {
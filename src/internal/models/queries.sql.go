@@ -8,6 +8,7 @@ package models
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 
 	null "github.com/guregu/null"
@@ -28,17 +29,46 @@ func (q *Queries) AddChannelToGroup(ctx context.Context, arg AddChannelToGroupPa
 	return err
 }
 
+const addItemToStoryCluster = `-- name: AddItemToStoryCluster :exec
+UPDATE feed_item
+SET cluster_id = ?1
+WHERE id = ?2
+`
+
+type AddItemToStoryClusterParams struct {
+	ClusterID null.Int `json:"cluster_id"`
+	ID        int64    `json:"id"`
+}
+
+func (q *Queries) AddItemToStoryCluster(ctx context.Context, arg AddItemToStoryClusterParams) error {
+	_, err := q.db.ExecContext(ctx, addItemToStoryCluster, arg.ClusterID, arg.ID)
+	return err
+}
+
 const createFeedChannel = `-- name: CreateFeedChannel :one
-INSERT INTO feed_channel (title, description, link, host)
-VALUES (?1, ?2, ?3, ?4)
+INSERT INTO feed_channel (
+    title, description, link, original_link, host, update_interval, adaptive_interval, proxy, tls_insecure, metadata_locked,
+    full_text, content_selector
+)
+VALUES (
+    ?1, ?2, ?3, ?3, ?4, ?5, ?6, ?7, ?8, ?9,
+    ?10, ?11
+)
 RETURNING id, title, description, link, host, published
 `
 
 type CreateFeedChannelParams struct {
-	Title       string `json:"title" validate:"required,min=5,max=20"`
-	Description string `json:"description"`
-	Link        string `json:"link" validate:"required,url"`
-	Host        string `json:"host"`
+	Title            string      `json:"title" validate:"required,min=5,max=20"`
+	Description      string      `json:"description"`
+	Link             string      `json:"link" validate:"required,url"`
+	Host             string      `json:"host"`
+	UpdateInterval   null.Int    `json:"update_interval"`
+	AdaptiveInterval bool        `json:"adaptive_interval"`
+	Proxy            null.String `json:"proxy"`
+	TlsInsecure      bool        `json:"tls_insecure"`
+	MetadataLocked   bool        `json:"metadata_locked"`
+	FullText         bool        `json:"full_text"`
+	ContentSelector  null.String `json:"content_selector"`
 }
 
 type CreateFeedChannelRow struct {
@@ -56,6 +86,13 @@ func (q *Queries) CreateFeedChannel(ctx context.Context, arg CreateFeedChannelPa
 		arg.Description,
 		arg.Link,
 		arg.Host,
+		arg.UpdateInterval,
+		arg.AdaptiveInterval,
+		arg.Proxy,
+		arg.TlsInsecure,
+		arg.MetadataLocked,
+		arg.FullText,
+		arg.ContentSelector,
 	)
 	var i CreateFeedChannelRow
 	err := row.Scan(
@@ -85,19 +122,90 @@ func (q *Queries) CreateFeedChannelItem(ctx context.Context, arg CreateFeedChann
 	return err
 }
 
+const createFeedChannelLink = `-- name: CreateFeedChannelLink :exec
+INSERT INTO feed_channel_link (channel_id, old_link, new_link, redirects)
+VALUES (?1, ?2, ?3, ?4)
+`
+
+type CreateFeedChannelLinkParams struct {
+	ChannelID int64          `json:"channel_id"`
+	OldLink   string         `json:"old_link"`
+	NewLink   string         `json:"new_link"`
+	Redirects sql.NullString `json:"redirects"`
+}
+
+func (q *Queries) CreateFeedChannelLink(ctx context.Context, arg CreateFeedChannelLinkParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedChannelLink,
+		arg.ChannelID,
+		arg.OldLink,
+		arg.NewLink,
+		arg.Redirects,
+	)
+	return err
+}
+
 const createFeedChannelLog = `-- name: CreateFeedChannelLog :exec
-INSERT INTO feed_channel_log (channel_id, last_update)
-VALUES (?, datetime('now'))
+INSERT INTO feed_channel_log (
+    channel_id, started, last_update, status_code, bytes_received, error_class, error_message,
+    items_new, items_updated, items_unchanged, url, effective_url, redirects, items_merged, merges,
+    items_failed, failures, run_id, duration_ms
+)
+VALUES (
+    ?1, ?2, datetime('now'), ?3, ?4, ?5, ?6,
+    ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14,
+    ?15, ?16, ?17, ?18
+)
 `
 
-func (q *Queries) CreateFeedChannelLog(ctx context.Context, channelID int64) error {
-	_, err := q.db.ExecContext(ctx, createFeedChannelLog, channelID)
+type CreateFeedChannelLogParams struct {
+	ChannelID      int64          `json:"channel_id"`
+	Started        sql.NullTime   `json:"started"`
+	StatusCode     sql.NullInt64  `json:"status_code"`
+	BytesReceived  int64          `json:"bytes_received"`
+	ErrorClass     sql.NullString `json:"error_class"`
+	ErrorMessage   sql.NullString `json:"error_message"`
+	ItemsNew       int64          `json:"items_new"`
+	ItemsUpdated   int64          `json:"items_updated"`
+	ItemsUnchanged int64          `json:"items_unchanged"`
+	Url            sql.NullString `json:"url"`
+	EffectiveUrl   sql.NullString `json:"effective_url"`
+	Redirects      sql.NullString `json:"redirects"`
+	ItemsMerged    int64          `json:"items_merged"`
+	Merges         sql.NullString `json:"merges"`
+	ItemsFailed    int64          `json:"items_failed"`
+	Failures       sql.NullString `json:"failures"`
+	RunID          sql.NullInt64  `json:"run_id"`
+	DurationMs     sql.NullInt64  `json:"duration_ms"`
+}
+
+func (q *Queries) CreateFeedChannelLog(ctx context.Context, arg CreateFeedChannelLogParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedChannelLog,
+		arg.ChannelID,
+		arg.Started,
+		arg.StatusCode,
+		arg.BytesReceived,
+		arg.ErrorClass,
+		arg.ErrorMessage,
+		arg.ItemsNew,
+		arg.ItemsUpdated,
+		arg.ItemsUnchanged,
+		arg.Url,
+		arg.EffectiveUrl,
+		arg.Redirects,
+		arg.ItemsMerged,
+		arg.Merges,
+		arg.ItemsFailed,
+		arg.Failures,
+		arg.RunID,
+		arg.DurationMs,
+	)
 	return err
 }
 
 const createFeedItem = `-- name: CreateFeedItem :one
-INSERT INTO feed_item (guid, guid_is_permalink, title, description, link, author, published)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO feed_item (guid, guid_is_permalink, title, description, link, author, published, content, source_updated,
+                       description_raw, content_raw, text, canonical_link, origin_channel_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING id, read, created, updated
 `
 
@@ -109,6 +217,13 @@ type CreateFeedItemParams struct {
 	Link            string       `json:"link"`
 	Author          *string      `json:"author,omitempty" validate:"required"`
 	Published       null.Time    `json:"published" validate:"required"`
+	Content         null.String  `json:"content,omitempty"`
+	SourceUpdated   null.Time    `json:"source_updated"`
+	DescriptionRaw  null.String  `json:"description_raw,omitempty"`
+	ContentRaw      null.String  `json:"content_raw,omitempty"`
+	Text            null.String  `json:"text"`
+	CanonicalLink   null.String  `json:"canonical_link"`
+	OriginChannelID null.Int     `json:"origin_channel_id"`
 }
 
 type CreateFeedItemRow struct {
@@ -127,6 +242,13 @@ func (q *Queries) CreateFeedItem(ctx context.Context, arg CreateFeedItemParams)
 		arg.Link,
 		arg.Author,
 		arg.Published,
+		arg.Content,
+		arg.SourceUpdated,
+		arg.DescriptionRaw,
+		arg.ContentRaw,
+		arg.Text,
+		arg.CanonicalLink,
+		arg.OriginChannelID,
 	)
 	var i CreateFeedItemRow
 	err := row.Scan(
@@ -138,6 +260,58 @@ func (q *Queries) CreateFeedItem(ctx context.Context, arg CreateFeedItemParams)
 	return i, err
 }
 
+const createFeedItemRevision = `-- name: CreateFeedItemRevision :exec
+INSERT INTO feed_item_revision (item_id, title, description, link, content, text, source_updated, valid_from)
+SELECT fi.id, fi.title, fi.description, fi.link, fi.content, fi.text, fi.source_updated, COALESCE(fi.updated, fi.created)
+FROM feed_item AS fi
+WHERE fi.id = ?1
+`
+
+// Keeps the current version of the item before it's overwritten
+func (q *Queries) CreateFeedItemRevision(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, createFeedItemRevision, id)
+	return err
+}
+
+const createFeedItemTombstones = `-- name: CreateFeedItemTombstones :exec
+INSERT INTO feed_item_tombstone (channel_id, guid, canonical_link)
+SELECT fci.channel_id, fi.guid, fi.canonical_link
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fi.id IN (/*SLICE:item_ids*/?)
+`
+
+// Remembers the purged items in each of their channels
+func (q *Queries) CreateFeedItemTombstones(ctx context.Context, itemIds []int64) error {
+	query := createFeedItemTombstones
+	var queryParams []interface{}
+	if len(itemIds) > 0 {
+		for _, v := range itemIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", strings.Repeat(",?", len(itemIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const createFetchRun = `-- name: CreateFetchRun :one
+
+INSERT INTO fetch_run (started)
+VALUES (?1)
+RETURNING id
+`
+
+// Fetch Run Queries
+func (q *Queries) CreateFetchRun(ctx context.Context, started time.Time) (int64, error) {
+	row := q.db.QueryRowContext(ctx, createFetchRun, started)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
 const createGroup = `-- name: CreateGroup :exec
 INSERT INTO feed_group (name, parent_id)
 VALUES (?1, ?2)
@@ -153,23 +327,230 @@ func (q *Queries) CreateGroup(ctx context.Context, arg CreateGroupParams) error
 	return err
 }
 
-const deleteFeedChannel = `-- name: DeleteFeedChannel :exec
+const createStoryCluster = `-- name: CreateStoryCluster :one
+INSERT INTO story_cluster (title)
+VALUES (?1)
+RETURNING id
+`
+
+func (q *Queries) CreateStoryCluster(ctx context.Context, title string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, createStoryCluster, title)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const deleteExpiredFeedItemTombstones = `-- name: DeleteExpiredFeedItemTombstones :execrows
+DELETE FROM feed_item_tombstone
+WHERE datetime(purged) < datetime(CAST(?1 AS TEXT))
+`
+
+// Tombstones of the items purged before the date, the feeds have dropped these items by then
+func (q *Queries) DeleteExpiredFeedItemTombstones(ctx context.Context, before string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredFeedItemTombstones, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteFeedChannelItemsByChannels = `-- name: DeleteFeedChannelItemsByChannels :exec
+DELETE FROM feed_channel_item
+WHERE channel_id IN (/*SLICE:channel_ids*/?)
+`
+
+func (q *Queries) DeleteFeedChannelItemsByChannels(ctx context.Context, channelIds []int64) error {
+	query := deleteFeedChannelItemsByChannels
+	var queryParams []interface{}
+	if len(channelIds) > 0 {
+		for _, v := range channelIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", strings.Repeat(",?", len(channelIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteFeedChannelItemsByItems = `-- name: DeleteFeedChannelItemsByItems :exec
+DELETE FROM feed_channel_item
+WHERE item_id IN (/*SLICE:item_ids*/?)
+`
+
+func (q *Queries) DeleteFeedChannelItemsByItems(ctx context.Context, itemIds []int64) error {
+	query := deleteFeedChannelItemsByItems
+	var queryParams []interface{}
+	if len(itemIds) > 0 {
+		for _, v := range itemIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", strings.Repeat(",?", len(itemIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteFeedChannelLinksByChannels = `-- name: DeleteFeedChannelLinksByChannels :exec
+DELETE FROM feed_channel_link
+WHERE channel_id IN (/*SLICE:channel_ids*/?)
+`
+
+func (q *Queries) DeleteFeedChannelLinksByChannels(ctx context.Context, channelIds []int64) error {
+	query := deleteFeedChannelLinksByChannels
+	var queryParams []interface{}
+	if len(channelIds) > 0 {
+		for _, v := range channelIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", strings.Repeat(",?", len(channelIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteFeedChannelLogsByChannels = `-- name: DeleteFeedChannelLogsByChannels :exec
+DELETE FROM feed_channel_log
+WHERE channel_id IN (/*SLICE:channel_ids*/?)
+`
+
+func (q *Queries) DeleteFeedChannelLogsByChannels(ctx context.Context, channelIds []int64) error {
+	query := deleteFeedChannelLogsByChannels
+	var queryParams []interface{}
+	if len(channelIds) > 0 {
+		for _, v := range channelIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", strings.Repeat(",?", len(channelIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteFeedChannelTagsByChannels = `-- name: DeleteFeedChannelTagsByChannels :exec
+DELETE FROM feed_channel_tag
+WHERE channel_id IN (/*SLICE:channel_ids*/?)
+`
+
+func (q *Queries) DeleteFeedChannelTagsByChannels(ctx context.Context, channelIds []int64) error {
+	query := deleteFeedChannelTagsByChannels
+	var queryParams []interface{}
+	if len(channelIds) > 0 {
+		for _, v := range channelIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", strings.Repeat(",?", len(channelIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteFeedChannels = `-- name: DeleteFeedChannels :exec
 DELETE FROM feed_channel
-WHERE id = ?
+WHERE id IN (/*SLICE:channel_ids*/?)
+`
+
+func (q *Queries) DeleteFeedChannels(ctx context.Context, channelIds []int64) error {
+	query := deleteFeedChannels
+	var queryParams []interface{}
+	if len(channelIds) > 0 {
+		for _, v := range channelIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", strings.Repeat(",?", len(channelIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteFeedGroupChannelsByChannels = `-- name: DeleteFeedGroupChannelsByChannels :exec
+DELETE FROM feed_group_channel
+WHERE channel_id IN (/*SLICE:channel_ids*/?)
+`
+
+func (q *Queries) DeleteFeedGroupChannelsByChannels(ctx context.Context, channelIds []int64) error {
+	query := deleteFeedGroupChannelsByChannels
+	var queryParams []interface{}
+	if len(channelIds) > 0 {
+		for _, v := range channelIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", strings.Repeat(",?", len(channelIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteFeedItemRevisionsByItems = `-- name: DeleteFeedItemRevisionsByItems :exec
+DELETE FROM feed_item_revision
+WHERE item_id IN (/*SLICE:item_ids*/?)
+`
+
+func (q *Queries) DeleteFeedItemRevisionsByItems(ctx context.Context, itemIds []int64) error {
+	query := deleteFeedItemRevisionsByItems
+	var queryParams []interface{}
+	if len(itemIds) > 0 {
+		for _, v := range itemIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", strings.Repeat(",?", len(itemIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const deleteFeedItemTombstonesByChannels = `-- name: DeleteFeedItemTombstonesByChannels :exec
+DELETE FROM feed_item_tombstone
+WHERE channel_id IN (/*SLICE:channel_ids*/?)
 `
 
-func (q *Queries) DeleteFeedChannel(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteFeedChannel, id)
+func (q *Queries) DeleteFeedItemTombstonesByChannels(ctx context.Context, channelIds []int64) error {
+	query := deleteFeedItemTombstonesByChannels
+	var queryParams []interface{}
+	if len(channelIds) > 0 {
+		for _, v := range channelIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", strings.Repeat(",?", len(channelIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
 	return err
 }
 
-const deleteFeedItem = `-- name: DeleteFeedItem :exec
+const deleteFeedItems = `-- name: DeleteFeedItems :exec
 DELETE FROM feed_item
-WHERE id = ?
+WHERE id IN (/*SLICE:item_ids*/?)
 `
 
-func (q *Queries) DeleteFeedItem(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteFeedItem, id)
+func (q *Queries) DeleteFeedItems(ctx context.Context, itemIds []int64) error {
+	query := deleteFeedItems
+	var queryParams []interface{}
+	if len(itemIds) > 0 {
+		for _, v := range itemIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", strings.Repeat(",?", len(itemIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
 	return err
 }
 
@@ -183,10 +564,57 @@ func (q *Queries) DeleteGroup(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteItemEnclosuresByItems = `-- name: DeleteItemEnclosuresByItems :exec
+DELETE FROM item_enclosure
+WHERE item_id IN (/*SLICE:item_ids*/?)
+`
+
+func (q *Queries) DeleteItemEnclosuresByItems(ctx context.Context, itemIds []int64) error {
+	query := deleteItemEnclosuresByItems
+	var queryParams []interface{}
+	if len(itemIds) > 0 {
+		for _, v := range itemIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", strings.Repeat(",?", len(itemIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", "NULL", 1)
+	}
+	_, err := q.db.ExecContext(ctx, query, queryParams...)
+	return err
+}
+
+const disableFeedChannel = `-- name: DisableFeedChannel :exec
+UPDATE feed_channel
+SET enabled = 0, disabled_reason = ?1
+WHERE id = ?2
+`
+
+type DisableFeedChannelParams struct {
+	DisabledReason sql.NullString `json:"disabled_reason"`
+	ID             int64          `json:"id"`
+}
+
+func (q *Queries) DisableFeedChannel(ctx context.Context, arg DisableFeedChannelParams) error {
+	_, err := q.db.ExecContext(ctx, disableFeedChannel, arg.DisabledReason, arg.ID)
+	return err
+}
+
+const enableFeedChannel = `-- name: EnableFeedChannel :exec
+UPDATE feed_channel
+SET enabled = 1, gone = 0, disabled_reason = NULL, failure_count = 0, next_fetch_at = NULL
+WHERE id = ?1
+`
+
+func (q *Queries) EnableFeedChannel(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, enableFeedChannel, id)
+	return err
+}
+
 const getFeedChannel = `-- name: GetFeedChannel :one
 SELECT fc.id, fc.title, fc.description, fc.link, fc.host, fc.published
 FROM feed_channel AS fc
-WHERE fc.id = ?1
+WHERE fc.id = ?1 AND fc.deleted_at IS NULL
 LIMIT 1
 `
 
@@ -213,6 +641,151 @@ func (q *Queries) GetFeedChannel(ctx context.Context, id int64) (GetFeedChannelR
 	return i, err
 }
 
+const getFeedChannelForFetch = `-- name: GetFeedChannelForFetch :one
+SELECT id, link, host, etag, last_modified, failure_count, update_interval, adaptive_interval, learned_interval,
+       proxy, tls_insecure, moved_to, moved_count, full_text, content_selector
+FROM feed_channel
+WHERE id = ?1 AND deleted_at IS NULL
+`
+
+type GetFeedChannelForFetchRow struct {
+	ID               int64          `json:"id"`
+	Link             string         `json:"link" validate:"required,url"`
+	Host             string         `json:"host"`
+	Etag             sql.NullString `json:"etag"`
+	LastModified     sql.NullString `json:"last_modified"`
+	FailureCount     int64          `json:"failure_count"`
+	UpdateInterval   null.Int       `json:"update_interval"`
+	AdaptiveInterval bool           `json:"adaptive_interval"`
+	LearnedInterval  sql.NullInt64  `json:"learned_interval"`
+	Proxy            null.String    `json:"proxy"`
+	TlsInsecure      bool           `json:"tls_insecure"`
+	MovedTo          null.String    `json:"moved_to"`
+	MovedCount       int64          `json:"moved_count"`
+	FullText         bool           `json:"full_text"`
+	ContentSelector  null.String    `json:"content_selector"`
+}
+
+func (q *Queries) GetFeedChannelForFetch(ctx context.Context, id int64) (GetFeedChannelForFetchRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedChannelForFetch, id)
+	var i GetFeedChannelForFetchRow
+	err := row.Scan(
+		&i.ID,
+		&i.Link,
+		&i.Host,
+		&i.Etag,
+		&i.LastModified,
+		&i.FailureCount,
+		&i.UpdateInterval,
+		&i.AdaptiveInterval,
+		&i.LearnedInterval,
+		&i.Proxy,
+		&i.TlsInsecure,
+		&i.MovedTo,
+		&i.MovedCount,
+		&i.FullText,
+		&i.ContentSelector,
+	)
+	return i, err
+}
+
+const getFeedChannelHealth = `-- name: GetFeedChannelHealth :one
+SELECT id, link, original_link, moved_to, enabled, gone, failure_count, next_fetch_at, disabled_reason
+FROM feed_channel
+WHERE id = ?1 AND deleted_at IS NULL
+`
+
+type GetFeedChannelHealthRow struct {
+	ID             int64          `json:"id"`
+	Link           string         `json:"link" validate:"required,url"`
+	OriginalLink   null.String    `json:"original_link"`
+	MovedTo        null.String    `json:"moved_to"`
+	Enabled        bool           `json:"enabled"`
+	Gone           bool           `json:"gone"`
+	FailureCount   int64          `json:"failure_count"`
+	NextFetchAt    sql.NullTime   `json:"next_fetch_at"`
+	DisabledReason sql.NullString `json:"disabled_reason"`
+}
+
+func (q *Queries) GetFeedChannelHealth(ctx context.Context, id int64) (GetFeedChannelHealthRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedChannelHealth, id)
+	var i GetFeedChannelHealthRow
+	err := row.Scan(
+		&i.ID,
+		&i.Link,
+		&i.OriginalLink,
+		&i.MovedTo,
+		&i.Enabled,
+		&i.Gone,
+		&i.FailureCount,
+		&i.NextFetchAt,
+		&i.DisabledReason,
+	)
+	return i, err
+}
+
+const getFeedChannelMetadata = `-- name: GetFeedChannelMetadata :one
+SELECT id, title, description, site_link, language, image_url, generator, last_build_date, metadata_locked
+FROM feed_channel
+WHERE id = ?1 AND deleted_at IS NULL
+`
+
+type GetFeedChannelMetadataRow struct {
+	ID             int64       `json:"id"`
+	Title          string      `json:"title" validate:"required,min=5,max=20"`
+	Description    string      `json:"description"`
+	SiteLink       null.String `json:"site_link"`
+	Language       null.String `json:"language"`
+	ImageUrl       null.String `json:"image_url"`
+	Generator      null.String `json:"generator"`
+	LastBuildDate  null.Time   `json:"last_build_date"`
+	MetadataLocked bool        `json:"metadata_locked"`
+}
+
+func (q *Queries) GetFeedChannelMetadata(ctx context.Context, id int64) (GetFeedChannelMetadataRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedChannelMetadata, id)
+	var i GetFeedChannelMetadataRow
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Description,
+		&i.SiteLink,
+		&i.Language,
+		&i.ImageUrl,
+		&i.Generator,
+		&i.LastBuildDate,
+		&i.MetadataLocked,
+	)
+	return i, err
+}
+
+const getFeedChannelSchedule = `-- name: GetFeedChannelSchedule :one
+SELECT id, link, host, enabled, next_fetch_at
+FROM feed_channel
+WHERE id = ?1 AND deleted_at IS NULL
+`
+
+type GetFeedChannelScheduleRow struct {
+	ID          int64        `json:"id"`
+	Link        string       `json:"link" validate:"required,url"`
+	Host        string       `json:"host"`
+	Enabled     bool         `json:"enabled"`
+	NextFetchAt sql.NullTime `json:"next_fetch_at"`
+}
+
+func (q *Queries) GetFeedChannelSchedule(ctx context.Context, id int64) (GetFeedChannelScheduleRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedChannelSchedule, id)
+	var i GetFeedChannelScheduleRow
+	err := row.Scan(
+		&i.ID,
+		&i.Link,
+		&i.Host,
+		&i.Enabled,
+		&i.NextFetchAt,
+	)
+	return i, err
+}
+
 const getFeedChannelsIDs = `-- name: GetFeedChannelsIDs :many
 SELECT channel_id
 FROM feed_channel_item
@@ -243,12 +816,19 @@ func (q *Queries) GetFeedChannelsIDs(ctx context.Context, itemID int64) ([]int64
 }
 
 const getFeedItemByGuid = `-- name: GetFeedItemByGuid :one
-SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published,
+       fi.content, fi.source_updated, fi.canonical_link, fi.origin_channel_id
 FROM feed_item AS fi
-WHERE fi.guid = ?1
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = ?1 AND fi.guid = ?2
 LIMIT 1
 `
 
+type GetFeedItemByGuidParams struct {
+	ChannelID int64       `json:"channel_id" validate:"required"`
+	Guid      null.String `json:"guid,omitempty" validate:"required"`
+}
+
 type GetFeedItemByGuidRow struct {
 	ID              int64        `json:"id"`
 	Guid            null.String  `json:"guid,omitempty" validate:"required"`
@@ -258,10 +838,15 @@ type GetFeedItemByGuidRow struct {
 	Link            string       `json:"link"`
 	Author          *string      `json:"author,omitempty" validate:"required"`
 	Published       null.Time    `json:"published" validate:"required"`
+	Content         null.String  `json:"content,omitempty"`
+	SourceUpdated   null.Time    `json:"source_updated"`
+	CanonicalLink   null.String  `json:"canonical_link"`
+	OriginChannelID null.Int     `json:"origin_channel_id"`
 }
 
-func (q *Queries) GetFeedItemByGuid(ctx context.Context, guid null.String) (GetFeedItemByGuidRow, error) {
-	row := q.db.QueryRowContext(ctx, getFeedItemByGuid, guid)
+// GUIDs are unique only within a channel
+func (q *Queries) GetFeedItemByGuid(ctx context.Context, arg GetFeedItemByGuidParams) (GetFeedItemByGuidRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedItemByGuid, arg.ChannelID, arg.Guid)
 	var i GetFeedItemByGuidRow
 	err := row.Scan(
 		&i.ID,
@@ -272,40 +857,133 @@ func (q *Queries) GetFeedItemByGuid(ctx context.Context, guid null.String) (GetF
 		&i.Link,
 		&i.Author,
 		&i.Published,
+		&i.Content,
+		&i.SourceUpdated,
+		&i.CanonicalLink,
+		&i.OriginChannelID,
 	)
 	return i, err
 }
 
-const getFeedItemByLink = `-- name: GetFeedItemByLink :one
-SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published
+const getFeedItemRaw = `-- name: GetFeedItemRaw :one
+SELECT fi.id, fi.description_raw, fi.content_raw
 FROM feed_item AS fi
-WHERE fi.link = ?1 AND (fi.guid IS NULL OR fi.guid = '')
-LIMIT 1
+WHERE fi.id = ?1
 `
 
-type GetFeedItemByLinkRow struct {
-	ID              int64        `json:"id"`
-	Guid            null.String  `json:"guid,omitempty" validate:"required"`
-	GuidIsPermalink sql.NullBool `json:"guid_is_permalink"`
-	Title           string       `json:"title"`
-	Description     null.String  `json:"description,omitempty" validate:"required"`
-	Link            string       `json:"link"`
-	Author          *string      `json:"author,omitempty" validate:"required"`
-	Published       null.Time    `json:"published" validate:"required"`
+type GetFeedItemRawRow struct {
+	ID             int64       `json:"id"`
+	DescriptionRaw null.String `json:"description_raw,omitempty"`
+	ContentRaw     null.String `json:"content_raw,omitempty"`
+}
+
+func (q *Queries) GetFeedItemRaw(ctx context.Context, id int64) (GetFeedItemRawRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedItemRaw, id)
+	var i GetFeedItemRawRow
+	err := row.Scan(&i.ID, &i.DescriptionRaw, &i.ContentRaw)
+	return i, err
+}
+
+const getFeedItemVersion = `-- name: GetFeedItemVersion :one
+SELECT id, title, description, link, content, text, source_updated, edited, created, updated
+FROM feed_item
+WHERE id = ?1
+`
+
+type GetFeedItemVersionRow struct {
+	ID            int64        `json:"id"`
+	Title         string       `json:"title"`
+	Description   null.String  `json:"description,omitempty" validate:"required"`
+	Link          string       `json:"link"`
+	Content       null.String  `json:"content,omitempty"`
+	Text          null.String  `json:"text"`
+	SourceUpdated null.Time    `json:"source_updated"`
+	Edited        bool         `json:"edited"`
+	Created       time.Time    `json:"created"`
+	Updated       sql.NullTime `json:"updated"`
 }
 
-func (q *Queries) GetFeedItemByLink(ctx context.Context, link string) (GetFeedItemByLinkRow, error) {
-	row := q.db.QueryRowContext(ctx, getFeedItemByLink, link)
-	var i GetFeedItemByLinkRow
+func (q *Queries) GetFeedItemVersion(ctx context.Context, id int64) (GetFeedItemVersionRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedItemVersion, id)
+	var i GetFeedItemVersionRow
 	err := row.Scan(
 		&i.ID,
-		&i.Guid,
-		&i.GuidIsPermalink,
 		&i.Title,
 		&i.Description,
 		&i.Link,
-		&i.Author,
-		&i.Published,
+		&i.Content,
+		&i.Text,
+		&i.SourceUpdated,
+		&i.Edited,
+		&i.Created,
+		&i.Updated,
+	)
+	return i, err
+}
+
+const getGroup = `-- name: GetGroup :one
+SELECT id, name, parent_id
+FROM feed_group
+WHERE id = ?1
+`
+
+func (q *Queries) GetGroup(ctx context.Context, id int64) (FeedGroup, error) {
+	row := q.db.QueryRowContext(ctx, getGroup, id)
+	var i FeedGroup
+	err := row.Scan(&i.ID, &i.Name, &i.ParentID)
+	return i, err
+}
+
+const getItemCounters = `-- name: GetItemCounters :one
+
+SELECT CAST(COALESCE(SUM(fi.read = 0), 0) AS INTEGER) AS unread,
+       CAST(COALESCE(SUM(fi.starred), 0) AS INTEGER) AS starred,
+       CAST(COALESCE(SUM(fi.read_later), 0) AS INTEGER) AS read_later
+FROM feed_item AS fi
+WHERE fi.deleted = 0
+  AND fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                WHERE fc.deleted_at IS NULL)
+`
+
+type GetItemCountersRow struct {
+	Unread    int64 `json:"unread"`
+	Starred   int64 `json:"starred"`
+	ReadLater int64 `json:"read_later"`
+}
+
+// Counter Queries
+// Items of the channels in the trash are counted if they are published in another channel too
+func (q *Queries) GetItemCounters(ctx context.Context) (GetItemCountersRow, error) {
+	row := q.db.QueryRowContext(ctx, getItemCounters)
+	var i GetItemCountersRow
+	err := row.Scan(&i.Unread, &i.Starred, &i.ReadLater)
+	return i, err
+}
+
+const getLastChannelError = `-- name: GetLastChannelError :one
+SELECT last_update, status_code, error_class, error_message
+FROM feed_channel_log
+WHERE channel_id = ?1 AND error_class IS NOT NULL
+ORDER BY id DESC
+LIMIT 1
+`
+
+type GetLastChannelErrorRow struct {
+	LastUpdate   sql.NullTime   `json:"last_update"`
+	StatusCode   sql.NullInt64  `json:"status_code"`
+	ErrorClass   sql.NullString `json:"error_class"`
+	ErrorMessage sql.NullString `json:"error_message"`
+}
+
+func (q *Queries) GetLastChannelError(ctx context.Context, id int64) (GetLastChannelErrorRow, error) {
+	row := q.db.QueryRowContext(ctx, getLastChannelError, id)
+	var i GetLastChannelErrorRow
+	err := row.Scan(
+		&i.LastUpdate,
+		&i.StatusCode,
+		&i.ErrorClass,
+		&i.ErrorMessage,
 	)
 	return i, err
 }
@@ -313,7 +991,7 @@ func (q *Queries) GetFeedItemByLink(ctx context.Context, link string) (GetFeedIt
 const getLastChannelUpdateDate = `-- name: GetLastChannelUpdateDate :one
 SELECT last_update
 FROM feed_channel_log
-WHERE channel_id = ?1
+WHERE channel_id = ?1 AND error_class IS NULL
 ORDER BY last_update DESC
 LIMIT 1
 `
@@ -325,39 +1003,1744 @@ func (q *Queries) GetLastChannelUpdateDate(ctx context.Context, id int64) (sql.N
 	return last_update, err
 }
 
+const getTag = `-- name: GetTag :one
+SELECT id, name, description
+FROM tag
+WHERE id = ?1
+`
+
+func (q *Queries) GetTag(ctx context.Context, id int64) (Tag, error) {
+	row := q.db.QueryRowContext(ctx, getTag, id)
+	var i Tag
+	err := row.Scan(&i.ID, &i.Name, &i.Description)
+	return i, err
+}
+
 const listAllFeedChannel = `-- name: ListAllFeedChannel :many
-SELECT id, title, description, link, host, published, enabled
+SELECT id, title, description, link, host, published, enabled, failure_count, next_fetch_at, disabled_reason,
+       update_interval, adaptive_interval, learned_interval, proxy, tls_insecure, original_link, gone,
+       site_link, language, image_url, generator, last_build_date, metadata_locked, full_text, content_selector
 FROM feed_channel
+WHERE deleted_at IS NULL
 ORDER BY title
 `
 
 type ListAllFeedChannelRow struct {
-	ID          int64     `json:"id"`
+	ID               int64          `json:"id"`
+	Title            string         `json:"title" validate:"required,min=5,max=20"`
+	Description      string         `json:"description"`
+	Link             string         `json:"link" validate:"required,url"`
+	Host             string         `json:"host"`
+	Published        null.Time      `json:"published" validate:"required"`
+	Enabled          bool           `json:"enabled"`
+	FailureCount     int64          `json:"failure_count"`
+	NextFetchAt      sql.NullTime   `json:"next_fetch_at"`
+	DisabledReason   sql.NullString `json:"disabled_reason"`
+	UpdateInterval   null.Int       `json:"update_interval"`
+	AdaptiveInterval bool           `json:"adaptive_interval"`
+	LearnedInterval  sql.NullInt64  `json:"learned_interval"`
+	Proxy            null.String    `json:"proxy"`
+	TlsInsecure      bool           `json:"tls_insecure"`
+	OriginalLink     null.String    `json:"original_link"`
+	Gone             bool           `json:"gone"`
+	SiteLink         null.String    `json:"site_link"`
+	Language         null.String    `json:"language"`
+	ImageUrl         null.String    `json:"image_url"`
+	Generator        null.String    `json:"generator"`
+	LastBuildDate    null.Time      `json:"last_build_date"`
+	MetadataLocked   bool           `json:"metadata_locked"`
+	FullText         bool           `json:"full_text"`
+	ContentSelector  null.String    `json:"content_selector"`
+}
+
+func (q *Queries) ListAllFeedChannel(ctx context.Context) ([]ListAllFeedChannelRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAllFeedChannel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAllFeedChannelRow
+	for rows.Next() {
+		var i ListAllFeedChannelRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Description,
+			&i.Link,
+			&i.Host,
+			&i.Published,
+			&i.Enabled,
+			&i.FailureCount,
+			&i.NextFetchAt,
+			&i.DisabledReason,
+			&i.UpdateInterval,
+			&i.AdaptiveInterval,
+			&i.LearnedInterval,
+			&i.Proxy,
+			&i.TlsInsecure,
+			&i.OriginalLink,
+			&i.Gone,
+			&i.SiteLink,
+			&i.Language,
+			&i.ImageUrl,
+			&i.Generator,
+			&i.LastBuildDate,
+			&i.MetadataLocked,
+			&i.FullText,
+			&i.ContentSelector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChannelUnreadCounts = `-- name: ListChannelUnreadCounts :many
+SELECT fc.id AS channel_id, COUNT(fi.id) AS unread
+FROM feed_channel AS fc
+LEFT JOIN feed_channel_item AS fci ON fci.channel_id = fc.id
+LEFT JOIN feed_item AS fi ON fi.id = fci.item_id AND fi.read = 0 AND fi.deleted = 0
+WHERE fc.deleted_at IS NULL
+GROUP BY fc.id
+ORDER BY fc.id
+`
+
+type ListChannelUnreadCountsRow struct {
+	ChannelID int64 `json:"channel_id"`
+	Unread    int64 `json:"unread"`
+}
+
+func (q *Queries) ListChannelUnreadCounts(ctx context.Context) ([]ListChannelUnreadCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChannelUnreadCounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListChannelUnreadCountsRow
+	for rows.Next() {
+		var i ListChannelUnreadCountsRow
+		if err := rows.Scan(&i.ChannelID, &i.Unread); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedChannel = `-- name: ListFeedChannel :many
+
+SELECT id, link, host, next_fetch_at
+FROM feed_channel
+WHERE enabled = 1 AND deleted_at IS NULL
+ORDER BY next_fetch_at
+`
+
+type ListFeedChannelRow struct {
+	ID          int64        `json:"id"`
+	Link        string       `json:"link" validate:"required,url"`
+	Host        string       `json:"host"`
+	NextFetchAt sql.NullTime `json:"next_fetch_at"`
+}
+
+// Feed Channel Queries
+func (q *Queries) ListFeedChannel(ctx context.Context) ([]ListFeedChannelRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedChannel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedChannelRow
+	for rows.Next() {
+		var i ListFeedChannelRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.Host,
+			&i.NextFetchAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedChannelIDs = `-- name: ListFeedChannelIDs :many
+SELECT id
+FROM feed_channel
+WHERE deleted_at IS NULL AND (enabled = 1 OR CAST(?1 AS BOOLEAN) = 0)
+ORDER BY id
+`
+
+// Only the enabled channels with enabled_only, channels in the trash are left out
+func (q *Queries) ListFeedChannelIDs(ctx context.Context, enabledOnly bool) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedChannelIDs, enabledOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedChannelLink = `-- name: ListFeedChannelLink :many
+SELECT id, channel_id, old_link, new_link, redirects, changed
+FROM feed_channel_link
+WHERE channel_id = ?1
+ORDER BY id DESC
+`
+
+func (q *Queries) ListFeedChannelLink(ctx context.Context, channelID int64) ([]FeedChannelLink, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedChannelLink, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedChannelLink
+	for rows.Next() {
+		var i FeedChannelLink
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChannelID,
+			&i.OldLink,
+			&i.NewLink,
+			&i.Redirects,
+			&i.Changed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedChannelLog = `-- name: ListFeedChannelLog :many
+SELECT id, channel_id, started, last_update, status_code, bytes_received, error_class, error_message,
+       items_new, items_updated, items_unchanged, url, effective_url, redirects, items_merged, merges,
+       items_failed, failures, run_id, duration_ms
+FROM feed_channel_log
+WHERE channel_id = ?1
+ORDER BY id DESC
+LIMIT ?3 OFFSET ?2
+`
+
+type ListFeedChannelLogParams struct {
+	ChannelID int64 `json:"channel_id"`
+	Offset    int64 `json:"offset"`
+	Limit     int64 `json:"limit"`
+}
+
+func (q *Queries) ListFeedChannelLog(ctx context.Context, arg ListFeedChannelLogParams) ([]FeedChannelLog, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedChannelLog, arg.ChannelID, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedChannelLog
+	for rows.Next() {
+		var i FeedChannelLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChannelID,
+			&i.Started,
+			&i.LastUpdate,
+			&i.StatusCode,
+			&i.BytesReceived,
+			&i.ErrorClass,
+			&i.ErrorMessage,
+			&i.ItemsNew,
+			&i.ItemsUpdated,
+			&i.ItemsUnchanged,
+			&i.Url,
+			&i.EffectiveUrl,
+			&i.Redirects,
+			&i.ItemsMerged,
+			&i.Merges,
+			&i.ItemsFailed,
+			&i.Failures,
+			&i.RunID,
+			&i.DurationMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedChannelPublished = `-- name: ListFeedChannelPublished :many
+SELECT fi.published
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = ?1 AND fi.published IS NOT NULL
+ORDER BY fi.published DESC
+LIMIT ?2
+`
+
+type ListFeedChannelPublishedParams struct {
+	ChannelID int64 `json:"channel_id" validate:"required"`
+	Limit     int64 `json:"limit"`
+}
+
+func (q *Queries) ListFeedChannelPublished(ctx context.Context, arg ListFeedChannelPublishedParams) ([]null.Time, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedChannelPublished, arg.ChannelID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []null.Time
+	for rows.Next() {
+		var published null.Time
+		if err := rows.Scan(&published); err != nil {
+			return nil, err
+		}
+		items = append(items, published)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItem = `-- name: ListFeedItem :many
+
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.text, fi.link, fi.canonical_link, fi.author, fi.published,
+       fi.source_updated, fi.extraction_status, fi.edited, fi.cluster_id
+FROM feed_item AS fi
+LEFT JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = ?1
+ORDER BY published DESC
+`
+
+type ListFeedItemRow struct {
+	ID               int64        `json:"id"`
+	Guid             null.String  `json:"guid,omitempty" validate:"required"`
+	GuidIsPermalink  sql.NullBool `json:"guid_is_permalink"`
+	Title            string       `json:"title"`
+	Description      null.String  `json:"description,omitempty" validate:"required"`
+	Text             null.String  `json:"text"`
+	Link             string       `json:"link"`
+	CanonicalLink    null.String  `json:"canonical_link"`
+	Author           *string      `json:"author,omitempty" validate:"required"`
+	Published        null.Time    `json:"published" validate:"required"`
+	SourceUpdated    null.Time    `json:"source_updated"`
+	ExtractionStatus null.String  `json:"extraction_status"`
+	Edited           bool         `json:"edited"`
+	ClusterID        null.Int     `json:"cluster_id"`
+}
+
+// Feed Data Queries
+func (q *Queries) ListFeedItem(ctx context.Context, id int64) ([]ListFeedItemRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedItem, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemRow
+	for rows.Next() {
+		var i ListFeedItemRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Guid,
+			&i.GuidIsPermalink,
+			&i.Title,
+			&i.Description,
+			&i.Text,
+			&i.Link,
+			&i.CanonicalLink,
+			&i.Author,
+			&i.Published,
+			&i.SourceUpdated,
+			&i.ExtractionStatus,
+			&i.Edited,
+			&i.ClusterID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemByCanonicalLinks = `-- name: ListFeedItemByCanonicalLinks :many
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published,
+       fi.content, fi.description_raw, fi.content_raw, fi.source_updated, fi.canonical_link, fi.origin_channel_id,
+       EXISTS (
+           SELECT 1 FROM feed_channel_item AS fci WHERE fci.item_id = fi.id AND fci.channel_id = ?1
+       ) AS in_channel
+FROM feed_item AS fi
+WHERE fi.canonical_link IN (/*SLICE:canonical_links*/?)
+ORDER BY fi.id
+`
+
+type ListFeedItemByCanonicalLinksParams struct {
+	ChannelID      int64         `json:"channel_id" validate:"required"`
+	CanonicalLinks []null.String `json:"canonical_link"`
+}
+
+type ListFeedItemByCanonicalLinksRow struct {
+	ID              int64        `json:"id"`
+	Guid            null.String  `json:"guid,omitempty" validate:"required"`
+	GuidIsPermalink sql.NullBool `json:"guid_is_permalink"`
+	Title           string       `json:"title"`
+	Description     null.String  `json:"description,omitempty" validate:"required"`
+	Link            string       `json:"link"`
+	Author          *string      `json:"author,omitempty" validate:"required"`
+	Published       null.Time    `json:"published" validate:"required"`
+	Content         null.String  `json:"content,omitempty"`
+	DescriptionRaw  null.String  `json:"description_raw,omitempty"`
+	ContentRaw      null.String  `json:"content_raw,omitempty"`
+	SourceUpdated   null.Time    `json:"source_updated"`
+	CanonicalLink   null.String  `json:"canonical_link"`
+	OriginChannelID null.Int     `json:"origin_channel_id"`
+	InChannel       int64        `json:"in_channel"`
+}
+
+// Items of any channel with any of the canonical links, looked up at once for the whole feed
+func (q *Queries) ListFeedItemByCanonicalLinks(ctx context.Context, arg ListFeedItemByCanonicalLinksParams) ([]ListFeedItemByCanonicalLinksRow, error) {
+	query := listFeedItemByCanonicalLinks
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.ChannelID)
+	if len(arg.CanonicalLinks) > 0 {
+		for _, v := range arg.CanonicalLinks {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:canonical_links*/?", strings.Repeat(",?", len(arg.CanonicalLinks))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:canonical_links*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemByCanonicalLinksRow
+	for rows.Next() {
+		var i ListFeedItemByCanonicalLinksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Guid,
+			&i.GuidIsPermalink,
+			&i.Title,
+			&i.Description,
+			&i.Link,
+			&i.Author,
+			&i.Published,
+			&i.Content,
+			&i.DescriptionRaw,
+			&i.ContentRaw,
+			&i.SourceUpdated,
+			&i.CanonicalLink,
+			&i.OriginChannelID,
+			&i.InChannel,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemByGuids = `-- name: ListFeedItemByGuids :many
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published,
+       fi.content, fi.description_raw, fi.content_raw, fi.source_updated, fi.canonical_link, fi.origin_channel_id
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = ?1 AND fi.guid IN (/*SLICE:guids*/?)
+ORDER BY fi.id
+`
+
+type ListFeedItemByGuidsParams struct {
+	ChannelID int64         `json:"channel_id" validate:"required"`
+	Guids     []null.String `json:"guid,omitempty" validate:"required"`
+}
+
+type ListFeedItemByGuidsRow struct {
+	ID              int64        `json:"id"`
+	Guid            null.String  `json:"guid,omitempty" validate:"required"`
+	GuidIsPermalink sql.NullBool `json:"guid_is_permalink"`
+	Title           string       `json:"title"`
+	Description     null.String  `json:"description,omitempty" validate:"required"`
+	Link            string       `json:"link"`
+	Author          *string      `json:"author,omitempty" validate:"required"`
+	Published       null.Time    `json:"published" validate:"required"`
+	Content         null.String  `json:"content,omitempty"`
+	DescriptionRaw  null.String  `json:"description_raw,omitempty"`
+	ContentRaw      null.String  `json:"content_raw,omitempty"`
+	SourceUpdated   null.Time    `json:"source_updated"`
+	CanonicalLink   null.String  `json:"canonical_link"`
+	OriginChannelID null.Int     `json:"origin_channel_id"`
+}
+
+// Items of the channel with any of the GUIDs, looked up at once for the whole feed
+func (q *Queries) ListFeedItemByGuids(ctx context.Context, arg ListFeedItemByGuidsParams) ([]ListFeedItemByGuidsRow, error) {
+	query := listFeedItemByGuids
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.ChannelID)
+	if len(arg.Guids) > 0 {
+		for _, v := range arg.Guids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:guids*/?", strings.Repeat(",?", len(arg.Guids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:guids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemByGuidsRow
+	for rows.Next() {
+		var i ListFeedItemByGuidsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Guid,
+			&i.GuidIsPermalink,
+			&i.Title,
+			&i.Description,
+			&i.Link,
+			&i.Author,
+			&i.Published,
+			&i.Content,
+			&i.DescriptionRaw,
+			&i.ContentRaw,
+			&i.SourceUpdated,
+			&i.CanonicalLink,
+			&i.OriginChannelID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemContent = `-- name: ListFeedItemContent :many
+SELECT fi.id, fi.content, fi.extracted_content
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = ?1 AND (fi.content IS NOT NULL OR fi.extracted_content IS NOT NULL)
+`
+
+type ListFeedItemContentRow struct {
+	ID               int64       `json:"id"`
+	Content          null.String `json:"content,omitempty"`
+	ExtractedContent null.String `json:"extracted_content,omitempty"`
+}
+
+func (q *Queries) ListFeedItemContent(ctx context.Context, id int64) ([]ListFeedItemContentRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedItemContent, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemContentRow
+	for rows.Next() {
+		var i ListFeedItemContentRow
+		if err := rows.Scan(&i.ID, &i.Content, &i.ExtractedContent); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemContentByIDs = `-- name: ListFeedItemContentByIDs :many
+SELECT fi.id, fi.content, fi.extracted_content
+FROM feed_item AS fi
+WHERE fi.id IN (/*SLICE:ids*/?) AND (fi.content IS NOT NULL OR fi.extracted_content IS NOT NULL)
+`
+
+type ListFeedItemContentByIDsRow struct {
+	ID               int64       `json:"id"`
+	Content          null.String `json:"content,omitempty"`
+	ExtractedContent null.String `json:"extracted_content,omitempty"`
+}
+
+func (q *Queries) ListFeedItemContentByIDs(ctx context.Context, ids []int64) ([]ListFeedItemContentByIDsRow, error) {
+	query := listFeedItemContentByIDs
+	var queryParams []interface{}
+	if len(ids) > 0 {
+		for _, v := range ids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:ids*/?", strings.Repeat(",?", len(ids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemContentByIDsRow
+	for rows.Next() {
+		var i ListFeedItemContentByIDsRow
+		if err := rows.Scan(&i.ID, &i.Content, &i.ExtractedContent); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemFingerprint = `-- name: ListFeedItemFingerprint :many
+
+SELECT id, title, simhash, cluster_id
+FROM feed_item
+WHERE simhash IS NOT NULL AND created >= ?1 AND id != ?2
+  AND (origin_channel_id != ?3 OR origin_channel_id IS NULL)
+`
+
+type ListFeedItemFingerprintParams struct {
+	Since           time.Time `json:"since"`
+	ID              int64     `json:"id"`
+	OriginChannelID null.Int  `json:"origin_channel_id"`
+}
+
+type ListFeedItemFingerprintRow struct {
+	ID        int64    `json:"id"`
+	Title     string   `json:"title"`
+	Simhash   null.Int `json:"simhash"`
+	ClusterID null.Int `json:"cluster_id"`
+}
+
+// Story Cluster Queries
+// Candidates for near-duplicates of a new item. Items first published by the same channel are left out,
+// a channel repeating itself, such as a daily digest, doesn't make a story.
+func (q *Queries) ListFeedItemFingerprint(ctx context.Context, arg ListFeedItemFingerprintParams) ([]ListFeedItemFingerprintRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedItemFingerprint, arg.Since, arg.ID, arg.OriginChannelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemFingerprintRow
+	for rows.Next() {
+		var i ListFeedItemFingerprintRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Simhash,
+			&i.ClusterID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemForExtraction = `-- name: ListFeedItemForExtraction :many
+SELECT fi.id, fi.link
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = ?1 AND fi.extraction_status = 'pending'
+ORDER BY fi.id
+LIMIT ?2
+`
+
+type ListFeedItemForExtractionParams struct {
+	ChannelID int64 `json:"channel_id" validate:"required"`
+	Limit     int64 `json:"limit"`
+}
+
+type ListFeedItemForExtractionRow struct {
+	ID   int64  `json:"id"`
+	Link string `json:"link"`
+}
+
+// Oldest first, the rest waits for the next fetch
+func (q *Queries) ListFeedItemForExtraction(ctx context.Context, arg ListFeedItemForExtractionParams) ([]ListFeedItemForExtractionRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedItemForExtraction, arg.ChannelID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemForExtractionRow
+	for rows.Next() {
+		var i ListFeedItemForExtractionRow
+		if err := rows.Scan(&i.ID, &i.Link); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemLinksAsCanonical = `-- name: ListFeedItemLinksAsCanonical :many
+SELECT fi.id, fi.link
+FROM feed_item AS fi
+WHERE fi.canonical_link = fi.link AND fi.id > ?1
+ORDER BY fi.id
+LIMIT ?2
+`
+
+type ListFeedItemLinksAsCanonicalParams struct {
+	AfterID int64 `json:"after_id"`
+	Limit   int64 `json:"limit"`
+}
+
+type ListFeedItemLinksAsCanonicalRow struct {
+	ID   int64  `json:"id"`
+	Link string `json:"link"`
+}
+
+// Items whose canonical link is their link as published, the next batch after the id
+func (q *Queries) ListFeedItemLinksAsCanonical(ctx context.Context, arg ListFeedItemLinksAsCanonicalParams) ([]ListFeedItemLinksAsCanonicalRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedItemLinksAsCanonical, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemLinksAsCanonicalRow
+	for rows.Next() {
+		var i ListFeedItemLinksAsCanonicalRow
+		if err := rows.Scan(&i.ID, &i.Link); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemPage = `-- name: ListFeedItemPage :many
+WITH RECURSIVE subgroup (id) AS (
+    SELECT fg.id FROM feed_group AS fg WHERE fg.id = ?12
+    UNION
+    SELECT fg.id FROM feed_group AS fg JOIN subgroup AS sg ON fg.parent_id = sg.id
+),
+scope_channel AS (
+    SELECT fc.id AS channel_id
+    FROM feed_channel AS fc
+    WHERE CASE
+        WHEN CAST(?13 AS INTEGER) IS NOT NULL THEN fc.id = ?13
+        ELSE fc.enabled = 1 AND fc.deleted_at IS NULL
+            AND (?12 IS NULL
+                 OR fc.id IN (SELECT fgc.channel_id FROM feed_group_channel AS fgc JOIN subgroup AS sg ON fgc.group_id = sg.id))
+            AND (CAST(?14 AS INTEGER) IS NULL
+                 OR fc.id IN (SELECT fct.channel_id FROM feed_channel_tag AS fct WHERE fct.tag_id = ?14))
+    END
+)
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.text, fi.link, fi.canonical_link, fi.author, fi.published,
+       fi.source_updated, fi.extraction_status, fi.edited, fi.cluster_id, fi.read, fi.starred, fi.read_later, fi.deleted, fi.created,
+       CAST(COALESCE(fi.published, fi.created) AS TEXT) AS sort_key
+FROM feed_item AS fi
+WHERE EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN scope_channel AS sc ON fci.channel_id = sc.channel_id
+              WHERE fci.item_id = fi.id)
+  AND (fi.read = CAST(?1 AS BOOLEAN) OR ?1 IS NULL)
+  AND (fi.starred = CAST(?2 AS BOOLEAN) OR ?2 IS NULL)
+  AND (fi.read_later = CAST(?3 AS BOOLEAN) OR ?3 IS NULL)
+  AND (fi.deleted = CAST(?4 AS BOOLEAN) OR ?4 IS NULL)
+  AND (datetime(fi.published) >= datetime(CAST(?5 AS TEXT)) OR ?5 IS NULL)
+  AND (datetime(fi.published) < datetime(CAST(?6 AS TEXT)) OR ?6 IS NULL)
+  AND (fi.author LIKE '%' || CAST(?7 AS TEXT) || '%' OR ?7 IS NULL)
+  AND (EXISTS (SELECT 1 FROM item_enclosure AS ie WHERE ie.item_id = fi.id) = CAST(?8 AS BOOLEAN)
+       OR ?8 IS NULL)
+  AND CAST(COALESCE(fi.published, fi.created) AS TEXT) <= CAST(?9 AS TEXT)
+  AND (CAST(COALESCE(fi.published, fi.created) AS TEXT) < ?9 OR fi.id < ?10)
+ORDER BY CAST(COALESCE(fi.published, fi.created) AS TEXT) DESC, fi.id DESC
+LIMIT ?11
+`
+
+type ListFeedItemPageParams struct {
+	Read          sql.NullBool   `json:"read"`
+	Starred       sql.NullBool   `json:"starred"`
+	ReadLater     sql.NullBool   `json:"read_later"`
+	Deleted       sql.NullBool   `json:"deleted"`
+	PublishedFrom sql.NullString `json:"published_from"`
+	PublishedTo   sql.NullString `json:"published_to"`
+	Author        sql.NullString `json:"author"`
+	HasEnclosures sql.NullBool   `json:"has_enclosures"`
+	CursorKey     string         `json:"cursor_key"`
+	CursorID      int64          `json:"cursor_id"`
+	Limit         int64          `json:"limit"`
+	GroupID       sql.NullInt64  `json:"group_id"`
+	ChannelID     sql.NullInt64  `json:"channel_id"`
+	TagID         sql.NullInt64  `json:"tag_id"`
+}
+
+type ListFeedItemPageRow struct {
+	ID               int64        `json:"id"`
+	Guid             null.String  `json:"guid,omitempty" validate:"required"`
+	GuidIsPermalink  sql.NullBool `json:"guid_is_permalink"`
+	Title            string       `json:"title"`
+	Description      null.String  `json:"description,omitempty" validate:"required"`
+	Text             null.String  `json:"text"`
+	Link             string       `json:"link"`
+	CanonicalLink    null.String  `json:"canonical_link"`
+	Author           *string      `json:"author,omitempty" validate:"required"`
+	Published        null.Time    `json:"published" validate:"required"`
+	SourceUpdated    null.Time    `json:"source_updated"`
+	ExtractionStatus null.String  `json:"extraction_status"`
+	Edited           bool         `json:"edited"`
+	ClusterID        null.Int     `json:"cluster_id"`
+	Read             bool         `json:"read"`
+	Starred          bool         `json:"starred"`
+	ReadLater        bool         `json:"read_later"`
+	Deleted          bool         `json:"deleted"`
+	Created          time.Time    `json:"created"`
+	SortKey          string       `json:"sort_key"`
+}
+
+// A page of the items of a channel, or of the enabled channels of a group and its subgroups, of a tag or of all enabled channels,
+// the newest published first. An item of several of these channels is listed once. Items without a publication date are
+// sorted by their creation date. The page is read in the order of an index from the cursor on: the sort key and the id
+// of the last item of the previous page, or the bounds of the sort key for the first page. The keys are compared
+// as stored, the dates are stored in UTC. The other sorts replace the sort key and the direction of this query,
+// see api.sortedItemPages.
+func (q *Queries) ListFeedItemPage(ctx context.Context, arg ListFeedItemPageParams) ([]ListFeedItemPageRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedItemPage,
+		arg.Read,
+		arg.Starred,
+		arg.ReadLater,
+		arg.Deleted,
+		arg.PublishedFrom,
+		arg.PublishedTo,
+		arg.Author,
+		arg.HasEnclosures,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.Limit,
+		arg.GroupID,
+		arg.ChannelID,
+		arg.TagID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemPageRow
+	for rows.Next() {
+		var i ListFeedItemPageRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Guid,
+			&i.GuidIsPermalink,
+			&i.Title,
+			&i.Description,
+			&i.Text,
+			&i.Link,
+			&i.CanonicalLink,
+			&i.Author,
+			&i.Published,
+			&i.SourceUpdated,
+			&i.ExtractionStatus,
+			&i.Edited,
+			&i.ClusterID,
+			&i.Read,
+			&i.Starred,
+			&i.ReadLater,
+			&i.Deleted,
+			&i.Created,
+			&i.SortKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemRevision = `-- name: ListFeedItemRevision :many
+SELECT id, item_id, title, description, link, content, text, source_updated, valid_from, replaced
+FROM feed_item_revision
+WHERE item_id = ?1
+ORDER BY id
+`
+
+func (q *Queries) ListFeedItemRevision(ctx context.Context, itemID int64) ([]FeedItemRevision, error) {
+	rows, err := q.db.QueryContext(ctx, listFeedItemRevision, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FeedItemRevision
+	for rows.Next() {
+		var i FeedItemRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.ItemID,
+			&i.Title,
+			&i.Description,
+			&i.Link,
+			&i.Content,
+			&i.Text,
+			&i.SourceUpdated,
+			&i.ValidFrom,
+			&i.Replaced,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFeedItemTombstones = `-- name: ListFeedItemTombstones :many
+
+SELECT channel_id, guid, canonical_link
+FROM feed_item_tombstone
+WHERE (channel_id = ?1 AND guid IN (/*SLICE:guids*/?)) OR canonical_link IN (/*SLICE:canonical_links*/?)
+`
+
+type ListFeedItemTombstonesParams struct {
+	ChannelID      int64         `json:"channel_id"`
+	Guids          []null.String `json:"guid"`
+	CanonicalLinks []null.String `json:"canonical_link"`
+}
+
+type ListFeedItemTombstonesRow struct {
+	ChannelID     int64       `json:"channel_id"`
+	Guid          null.String `json:"guid"`
+	CanonicalLink null.String `json:"canonical_link"`
+}
+
+// Trash Queries
+// Purged items with any of the GUIDs or canonical links, looked up at once for the whole feed. GUIDs are scoped
+// to their channel like in ListFeedItemByGuids, while the canonical links match the items purged from any channel
+// like in ListFeedItemByCanonicalLinks: another channel publishing the link publishes the same item.
+func (q *Queries) ListFeedItemTombstones(ctx context.Context, arg ListFeedItemTombstonesParams) ([]ListFeedItemTombstonesRow, error) {
+	query := listFeedItemTombstones
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.ChannelID)
+	if len(arg.Guids) > 0 {
+		for _, v := range arg.Guids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:guids*/?", strings.Repeat(",?", len(arg.Guids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:guids*/?", "NULL", 1)
+	}
+	if len(arg.CanonicalLinks) > 0 {
+		for _, v := range arg.CanonicalLinks {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:canonical_links*/?", strings.Repeat(",?", len(arg.CanonicalLinks))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:canonical_links*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFeedItemTombstonesRow
+	for rows.Next() {
+		var i ListFeedItemTombstonesRow
+		if err := rows.Scan(&i.ChannelID, &i.Guid, &i.CanonicalLink); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFetchRun = `-- name: ListFetchRun :many
+SELECT id, started, finished, succeeded, failed, skipped, fetch_time_ms, slowest_channel_id, slowest_fetch_ms
+FROM fetch_run
+ORDER BY id DESC
+LIMIT ?2 OFFSET ?1
+`
+
+type ListFetchRunParams struct {
+	Offset int64 `json:"offset"`
+	Limit  int64 `json:"limit"`
+}
+
+func (q *Queries) ListFetchRun(ctx context.Context, arg ListFetchRunParams) ([]FetchRun, error) {
+	rows, err := q.db.QueryContext(ctx, listFetchRun, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FetchRun
+	for rows.Next() {
+		var i FetchRun
+		if err := rows.Scan(
+			&i.ID,
+			&i.Started,
+			&i.Finished,
+			&i.Succeeded,
+			&i.Failed,
+			&i.Skipped,
+			&i.FetchTimeMs,
+			&i.SlowestChannelID,
+			&i.SlowestFetchMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFetchRunChannels = `-- name: ListFetchRunChannels :many
+SELECT fcl.channel_id, fc.title AS channel_title, fcl.started, fcl.duration_ms, fcl.status_code, fcl.error_class,
+       fcl.error_message, fcl.items_new, fcl.items_updated, fcl.items_unchanged, fcl.items_merged, fcl.items_failed
+FROM feed_channel_log AS fcl
+LEFT JOIN feed_channel AS fc ON fc.id = fcl.channel_id
+WHERE fcl.run_id = ?1
+ORDER BY fcl.error_class IS NULL, fcl.id
+`
+
+type ListFetchRunChannelsRow struct {
+	ChannelID      int64          `json:"channel_id"`
+	ChannelTitle   sql.NullString `json:"channel_title" validate:"required,min=5,max=20"`
+	Started        sql.NullTime   `json:"started"`
+	DurationMs     sql.NullInt64  `json:"duration_ms"`
+	StatusCode     sql.NullInt64  `json:"status_code"`
+	ErrorClass     sql.NullString `json:"error_class"`
+	ErrorMessage   sql.NullString `json:"error_message"`
+	ItemsNew       int64          `json:"items_new"`
+	ItemsUpdated   int64          `json:"items_updated"`
+	ItemsUnchanged int64          `json:"items_unchanged"`
+	ItemsMerged    int64          `json:"items_merged"`
+	ItemsFailed    int64          `json:"items_failed"`
+}
+
+// Fetch attempts of the run, the failed ones first
+func (q *Queries) ListFetchRunChannels(ctx context.Context, runID sql.NullInt64) ([]ListFetchRunChannelsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listFetchRunChannels, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListFetchRunChannelsRow
+	for rows.Next() {
+		var i ListFetchRunChannelsRow
+		if err := rows.Scan(
+			&i.ChannelID,
+			&i.ChannelTitle,
+			&i.Started,
+			&i.DurationMs,
+			&i.StatusCode,
+			&i.ErrorClass,
+			&i.ErrorMessage,
+			&i.ItemsNew,
+			&i.ItemsUpdated,
+			&i.ItemsUnchanged,
+			&i.ItemsMerged,
+			&i.ItemsFailed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGroup = `-- name: ListGroup :many
+
+WITH sorting AS (
+    SELECT CAST(?1 AS TEXT) AS sort, CAST(?2 AS BOOLEAN) AS descending
+)
+SELECT fg.id, fg.name
+FROM feed_group AS fg
+CROSS JOIN sorting
+ORDER BY CASE WHEN sorting.descending = 1 AND sorting.sort = 'name' THEN fg.name END DESC,
+         CASE sorting.descending WHEN 1 THEN fg.id END DESC,
+         CASE sorting.sort WHEN 'name' THEN fg.name END, fg.id
+`
+
+type ListGroupParams struct {
+	Sort       string `json:"sort"`
+	Descending bool   `json:"descending"`
+}
+
+type ListGroupRow struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// Feed Group Queries
+// Groups sorted by name or id. sqlc doesn't bind parameters in ORDER BY, so they are passed through the CTE.
+func (q *Queries) ListGroup(ctx context.Context, arg ListGroupParams) ([]ListGroupRow, error) {
+	rows, err := q.db.QueryContext(ctx, listGroup, arg.Sort, arg.Descending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListGroupRow
+	for rows.Next() {
+		var i ListGroupRow
+		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGroupChannelIDs = `-- name: ListGroupChannelIDs :many
+WITH RECURSIVE subgroup (id) AS (
+    SELECT fg.id FROM feed_group AS fg WHERE fg.id = ?2
+    UNION
+    SELECT fg.id FROM feed_group AS fg JOIN subgroup AS sg ON fg.parent_id = sg.id
+)
+SELECT DISTINCT fgc.channel_id
+FROM feed_group_channel AS fgc
+JOIN subgroup AS sg ON fgc.group_id = sg.id
+JOIN feed_channel AS fc ON fc.id = fgc.channel_id
+WHERE fc.deleted_at IS NULL AND (fc.enabled = 1 OR CAST(?1 AS BOOLEAN) = 0)
+ORDER BY fgc.channel_id
+`
+
+type ListGroupChannelIDsParams struct {
+	EnabledOnly bool  `json:"enabled_only"`
+	GroupID     int64 `json:"group_id"`
+}
+
+// Channels of the group and its subgroups, only the enabled ones with enabled_only. Channels in the trash are left out.
+func (q *Queries) ListGroupChannelIDs(ctx context.Context, arg ListGroupChannelIDsParams) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listGroupChannelIDs, arg.EnabledOnly, arg.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var channel_id int64
+		if err := rows.Scan(&channel_id); err != nil {
+			return nil, err
+		}
+		items = append(items, channel_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGroupUnreadCounts = `-- name: ListGroupUnreadCounts :many
+WITH RECURSIVE group_tree AS (
+    SELECT fg.id AS group_id, fg.id AS subgroup_id FROM feed_group AS fg
+    UNION
+    SELECT gt.group_id, fg.id FROM feed_group AS fg JOIN group_tree AS gt ON fg.parent_id = gt.subgroup_id
+)
+SELECT gt.group_id, COUNT(DISTINCT fi.id) AS unread
+FROM group_tree AS gt
+LEFT JOIN feed_group_channel AS fgc ON fgc.group_id = gt.subgroup_id
+    AND fgc.channel_id IN (SELECT fc.id FROM feed_channel AS fc WHERE fc.deleted_at IS NULL)
+LEFT JOIN feed_channel_item AS fci ON fci.channel_id = fgc.channel_id
+LEFT JOIN feed_item AS fi ON fi.id = fci.item_id AND fi.read = 0 AND fi.deleted = 0
+GROUP BY gt.group_id
+ORDER BY gt.group_id
+`
+
+type ListGroupUnreadCountsRow struct {
+	GroupID int64 `json:"group_id"`
+	Unread  int64 `json:"unread"`
+}
+
+// The unread items of the channels of each group and its subgroups, an item of several channels counts once
+func (q *Queries) ListGroupUnreadCounts(ctx context.Context) ([]ListGroupUnreadCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listGroupUnreadCounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListGroupUnreadCountsRow
+	for rows.Next() {
+		var i ListGroupUnreadCountsRow
+		if err := rows.Scan(&i.GroupID, &i.Unread); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listItemChannels = `-- name: ListItemChannels :many
+SELECT fci.item_id, fc.id AS channel_id, fc.title
+FROM feed_channel_item AS fci
+JOIN feed_channel AS fc ON fc.id = fci.channel_id
+WHERE fc.deleted_at IS NULL AND fci.item_id IN (/*SLICE:item_ids*/?)
+ORDER BY fci.item_id, fc.id
+`
+
+type ListItemChannelsRow struct {
+	ItemID    int64  `json:"item_id" validate:"required"`
+	ChannelID int64  `json:"channel_id"`
+	Title     string `json:"title" validate:"required,min=5,max=20"`
+}
+
+// Channels the items are published in, the channels in the trash are left out
+func (q *Queries) ListItemChannels(ctx context.Context, itemIds []int64) ([]ListItemChannelsRow, error) {
+	query := listItemChannels
+	var queryParams []interface{}
+	if len(itemIds) > 0 {
+		for _, v := range itemIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", strings.Repeat(",?", len(itemIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListItemChannelsRow
+	for rows.Next() {
+		var i ListItemChannelsRow
+		if err := rows.Scan(&i.ItemID, &i.ChannelID, &i.Title); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listItemEnclosuresByItems = `-- name: ListItemEnclosuresByItems :many
+SELECT ie.id, ie.item_id, ie.url, ie.mime_type, ie.medium, ie.length, ie.duration, ie.thumbnail
+FROM item_enclosure AS ie
+WHERE ie.item_id IN (/*SLICE:item_ids*/?)
+ORDER BY ie.item_id, ie.id
+`
+
+func (q *Queries) ListItemEnclosuresByItems(ctx context.Context, itemIds []int64) ([]ItemEnclosure, error) {
+	query := listItemEnclosuresByItems
+	var queryParams []interface{}
+	if len(itemIds) > 0 {
+		for _, v := range itemIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", strings.Repeat(",?", len(itemIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:item_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ItemEnclosure
+	for rows.Next() {
+		var i ItemEnclosure
+		if err := rows.Scan(
+			&i.ID,
+			&i.ItemID,
+			&i.Url,
+			&i.MimeType,
+			&i.Medium,
+			&i.Length,
+			&i.Duration,
+			&i.Thumbnail,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPurgeableChannelItemIDs = `-- name: ListPurgeableChannelItemIDs :many
+SELECT fi.id
+FROM feed_item AS fi
+WHERE fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                WHERE datetime(fc.deleted_at) < datetime(CAST(?1 AS TEXT)))
+  AND fi.id NOT IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                    JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                    WHERE fc.deleted_at IS NULL OR datetime(fc.deleted_at) >= datetime(CAST(?1 AS TEXT)))
+ORDER BY fi.id
+LIMIT ?2
+`
+
+type ListPurgeableChannelItemIDsParams struct {
+	Before string `json:"before"`
+	Limit  int64  `json:"limit"`
+}
+
+// Items published only in the channels moved to the trash before the date, they are purged with the channels
+func (q *Queries) ListPurgeableChannelItemIDs(ctx context.Context, arg ListPurgeableChannelItemIDsParams) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listPurgeableChannelItemIDs, arg.Before, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPurgeableFeedChannelIDs = `-- name: ListPurgeableFeedChannelIDs :many
+SELECT id
+FROM feed_channel
+WHERE datetime(deleted_at) < datetime(CAST(?1 AS TEXT))
+ORDER BY id
+`
+
+// Channels moved to the trash before the date
+func (q *Queries) ListPurgeableFeedChannelIDs(ctx context.Context, before string) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listPurgeableFeedChannelIDs, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPurgeableFeedItemIDs = `-- name: ListPurgeableFeedItemIDs :many
+SELECT id
+FROM feed_item
+WHERE deleted = 1 AND datetime(deleted_at) < datetime(CAST(?1 AS TEXT))
+ORDER BY id
+LIMIT ?2
+`
+
+type ListPurgeableFeedItemIDsParams struct {
+	Before string `json:"before"`
+	Limit  int64  `json:"limit"`
+}
+
+// Items moved to the trash before the date
+func (q *Queries) ListPurgeableFeedItemIDs(ctx context.Context, arg ListPurgeableFeedItemIDsParams) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, listPurgeableFeedItemIDs, arg.Before, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStoryCluster = `-- name: ListStoryCluster :many
+SELECT id, title, item_count, created, updated
+FROM story_cluster
+WHERE item_count >= ?1
+ORDER BY updated DESC, id DESC
+LIMIT ?3 OFFSET ?2
+`
+
+type ListStoryClusterParams struct {
+	MinItems int64 `json:"min_items"`
+	Offset   int64 `json:"offset"`
+	Limit    int64 `json:"limit"`
+}
+
+func (q *Queries) ListStoryCluster(ctx context.Context, arg ListStoryClusterParams) ([]StoryCluster, error) {
+	rows, err := q.db.QueryContext(ctx, listStoryCluster, arg.MinItems, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []StoryCluster
+	for rows.Next() {
+		var i StoryCluster
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.ItemCount,
+			&i.Created,
+			&i.Updated,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStoryClusterItems = `-- name: ListStoryClusterItems :many
+SELECT fi.cluster_id, fi.id, fi.title, fi.link, fi.published, fc.id AS channel_id, fc.title AS channel_title
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+JOIN feed_channel AS fc ON fc.id = fci.channel_id
+WHERE fi.cluster_id IN (/*SLICE:cluster_ids*/?) AND fi.deleted = 0 AND fc.deleted_at IS NULL
+ORDER BY fi.cluster_id, fi.published, fi.id, fc.id
+`
+
+type ListStoryClusterItemsRow struct {
+	ClusterID    null.Int  `json:"cluster_id"`
+	ID           int64     `json:"id"`
+	Title        string    `json:"title"`
+	Link         string    `json:"link"`
+	Published    null.Time `json:"published" validate:"required"`
+	ChannelID    int64     `json:"channel_id"`
+	ChannelTitle string    `json:"channel_title" validate:"required,min=5,max=20"`
+}
+
+func (q *Queries) ListStoryClusterItems(ctx context.Context, clusterIds []null.Int) ([]ListStoryClusterItemsRow, error) {
+	query := listStoryClusterItems
+	var queryParams []interface{}
+	if len(clusterIds) > 0 {
+		for _, v := range clusterIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:cluster_ids*/?", strings.Repeat(",?", len(clusterIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:cluster_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListStoryClusterItemsRow
+	for rows.Next() {
+		var i ListStoryClusterItemsRow
+		if err := rows.Scan(
+			&i.ClusterID,
+			&i.ID,
+			&i.Title,
+			&i.Link,
+			&i.Published,
+			&i.ChannelID,
+			&i.ChannelTitle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrashedFeedChannel = `-- name: ListTrashedFeedChannel :many
+SELECT id, title, description, link, host, enabled, deleted_at
+FROM feed_channel
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC
+`
+
+type ListTrashedFeedChannelRow struct {
+	ID          int64     `json:"id"`
 	Title       string    `json:"title" validate:"required,min=5,max=20"`
 	Description string    `json:"description"`
 	Link        string    `json:"link" validate:"required,url"`
 	Host        string    `json:"host"`
-	Published   null.Time `json:"published" validate:"required"`
 	Enabled     bool      `json:"enabled"`
+	DeletedAt   null.Time `json:"deleted_at"`
 }
 
-func (q *Queries) ListAllFeedChannel(ctx context.Context) ([]ListAllFeedChannelRow, error) {
-	rows, err := q.db.QueryContext(ctx, listAllFeedChannel)
+func (q *Queries) ListTrashedFeedChannel(ctx context.Context) ([]ListTrashedFeedChannelRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTrashedFeedChannel)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListAllFeedChannelRow
+	var items []ListTrashedFeedChannelRow
 	for rows.Next() {
-		var i ListAllFeedChannelRow
+		var i ListTrashedFeedChannelRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Title,
 			&i.Description,
 			&i.Link,
 			&i.Host,
-			&i.Published,
 			&i.Enabled,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrashedFeedItem = `-- name: ListTrashedFeedItem :many
+SELECT fi.id, fi.title, fi.link, fi.author, fi.published, fi.deleted_at
+FROM feed_item AS fi
+WHERE fi.deleted = 1
+ORDER BY fi.deleted_at DESC, fi.id DESC
+LIMIT ?2 OFFSET ?1
+`
+
+type ListTrashedFeedItemParams struct {
+	Offset int64 `json:"offset"`
+	Limit  int64 `json:"limit"`
+}
+
+type ListTrashedFeedItemRow struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Link      string    `json:"link"`
+	Author    *string   `json:"author,omitempty" validate:"required"`
+	Published null.Time `json:"published" validate:"required"`
+	DeletedAt null.Time `json:"deleted_at"`
+}
+
+func (q *Queries) ListTrashedFeedItem(ctx context.Context, arg ListTrashedFeedItemParams) ([]ListTrashedFeedItemRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTrashedFeedItem, arg.Offset, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTrashedFeedItemRow
+	for rows.Next() {
+		var i ListTrashedFeedItemRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Link,
+			&i.Author,
+			&i.Published,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnsanitizedFeedItems = `-- name: ListUnsanitizedFeedItems :many
+SELECT fi.id, fi.link, fi.description, fi.content, COALESCE(fc.link, '') AS channel_link
+FROM feed_item AS fi
+LEFT JOIN feed_channel AS fc ON fc.id = fi.origin_channel_id
+WHERE fi.description_raw IS NULL AND fi.content_raw IS NULL AND fi.text IS NULL
+  AND (fi.description != '' OR fi.content IS NOT NULL) AND fi.id > ?1
+ORDER BY fi.id
+LIMIT ?2
+`
+
+type ListUnsanitizedFeedItemsParams struct {
+	AfterID int64 `json:"after_id"`
+	Limit   int64 `json:"limit"`
+}
+
+type ListUnsanitizedFeedItemsRow struct {
+	ID          int64       `json:"id"`
+	Link        string      `json:"link"`
+	Description null.String `json:"description,omitempty" validate:"required"`
+	Content     null.String `json:"content,omitempty"`
+	ChannelLink string      `json:"channel_link" validate:"required,url"`
+}
+
+// Items stored before the HTML was sanitized, they have HTML but neither the HTML as published nor the plain text.
+// The next batch after the id, with the link of the channel which relative URLs of items without a link point to.
+func (q *Queries) ListUnsanitizedFeedItems(ctx context.Context, arg ListUnsanitizedFeedItemsParams) ([]ListUnsanitizedFeedItemsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listUnsanitizedFeedItems, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUnsanitizedFeedItemsRow
+	for rows.Next() {
+		var i ListUnsanitizedFeedItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Link,
+			&i.Description,
+			&i.Content,
+			&i.ChannelLink,
 		); err != nil {
 			return nil, err
 		}
@@ -372,99 +2755,282 @@ func (q *Queries) ListAllFeedChannel(ctx context.Context) ([]ListAllFeedChannelR
 	return items, nil
 }
 
-const listFeedChannel = `-- name: ListFeedChannel :many
+const markFeedChannelGone = `-- name: MarkFeedChannelGone :exec
+UPDATE feed_channel
+SET enabled = 0, gone = 1, disabled_reason = ?1
+WHERE id = ?2
+`
+
+type MarkFeedChannelGoneParams struct {
+	DisabledReason sql.NullString `json:"disabled_reason"`
+	ID             int64          `json:"id"`
+}
+
+func (q *Queries) MarkFeedChannelGone(ctx context.Context, arg MarkFeedChannelGoneParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedChannelGone, arg.DisabledReason, arg.ID)
+	return err
+}
+
+const markFeedItemForExtraction = `-- name: MarkFeedItemForExtraction :exec
+UPDATE feed_item
+SET extraction_status = 'pending'
+WHERE id = ?1 AND extraction_status IS NULL
+`
+
+func (q *Queries) MarkFeedItemForExtraction(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markFeedItemForExtraction, id)
+	return err
+}
+
+const markFeedItemsRead = `-- name: MarkFeedItemsRead :execrows
+UPDATE feed_item
+SET read = 1
+WHERE read = 0
+  AND (EXISTS (
+      SELECT 1
+      FROM feed_item AS seen
+      WHERE seen.id = CAST(?1 AS INTEGER)
+        AND (feed_item.created < seen.created OR (feed_item.created = seen.created AND feed_item.id <= seen.id))
+  ) OR ?1 IS NULL)
+  AND (datetime(COALESCE(published, created)) < datetime(CAST(?2 AS TEXT)) OR ?2 IS NULL)
+  AND id IN (SELECT fci.item_id FROM feed_channel_item AS fci WHERE fci.channel_id IN (/*SLICE:channel_ids*/?))
+`
+
+type MarkFeedItemsReadParams struct {
+	MaxItemID  sql.NullInt64  `json:"max_item_id"`
+	Before     sql.NullString `json:"before"`
+	ChannelIds []int64        `json:"channel_id" validate:"required"`
+}
+
+// Marks the unread items of the channels as read. Only the items stored up to the newest one the client has seen
+// and published before the date are marked, so the items arriving in the meantime stay unread. The items are
+// compared by when they were stored rather than by id, SQLite reuses the id of the newest item once it's purged.
+// The channels come last, the numbered parameters before them would be shifted by the expanded slice.
+func (q *Queries) MarkFeedItemsRead(ctx context.Context, arg MarkFeedItemsReadParams) (int64, error) {
+	query := markFeedItemsRead
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.MaxItemID)
+	queryParams = append(queryParams, arg.Before)
+	if len(arg.ChannelIds) > 0 {
+		for _, v := range arg.ChannelIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", strings.Repeat(",?", len(arg.ChannelIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:channel_ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const moveFeedChannel = `-- name: MoveFeedChannel :exec
+UPDATE feed_channel
+SET link = ?1, host = ?2, etag = NULL, last_modified = NULL, moved_to = NULL, moved_count = 0
+WHERE id = ?3
+`
+
+type MoveFeedChannelParams struct {
+	Link string `json:"link" validate:"required,url"`
+	Host string `json:"host"`
+	ID   int64  `json:"id"`
+}
+
+// The validators of the old link don't apply to the new one, so the next fetch is a full one
+func (q *Queries) MoveFeedChannel(ctx context.Context, arg MoveFeedChannelParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedChannel, arg.Link, arg.Host, arg.ID)
+	return err
+}
+
+const recountItemStoryCluster = `-- name: RecountItemStoryCluster :exec
+UPDATE story_cluster
+SET item_count = (SELECT COUNT(*) FROM feed_item AS fi
+                  WHERE fi.cluster_id = story_cluster.id AND fi.deleted = 0
+                    AND EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                                WHERE fci.item_id = fi.id AND fc.deleted_at IS NULL))
+WHERE story_cluster.id = (SELECT fi.cluster_id FROM feed_item AS fi WHERE fi.id = ?1)
+`
+
+// Counts the items of the story of an item again after it's moved to the trash or restored
+func (q *Queries) RecountItemStoryCluster(ctx context.Context, itemID int64) error {
+	_, err := q.db.ExecContext(ctx, recountItemStoryCluster, itemID)
+	return err
+}
+
+const recountStoryClusters = `-- name: RecountStoryClusters :exec
+UPDATE story_cluster
+SET item_count = (SELECT COUNT(*) FROM feed_item AS fi
+                  WHERE fi.cluster_id = story_cluster.id AND fi.deleted = 0
+                    AND EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                                WHERE fci.item_id = fi.id AND fc.deleted_at IS NULL))
+WHERE item_count != (SELECT COUNT(*) FROM feed_item AS fi
+                     WHERE fi.cluster_id = story_cluster.id AND fi.deleted = 0
+                       AND EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                                   WHERE fci.item_id = fi.id AND fc.deleted_at IS NULL))
+`
+
+// Counts the items of the stories again after items are purged or channels are moved to the trash or restored
+func (q *Queries) RecountStoryClusters(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, recountStoryClusters)
+	return err
+}
+
+const refreshStoryCluster = `-- name: RefreshStoryCluster :exec
+UPDATE story_cluster
+SET item_count = (SELECT COUNT(*) FROM feed_item AS fi
+                  WHERE fi.cluster_id = story_cluster.id AND fi.deleted = 0
+                    AND EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                                WHERE fci.item_id = fi.id AND fc.deleted_at IS NULL)),
+    updated = datetime('now')
+WHERE story_cluster.id = ?1
+`
+
+// A story counts the items which are not in the trash and are published in a channel which is not in the trash,
+// the items ListStoryClusterItems lists
+func (q *Queries) RefreshStoryCluster(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, refreshStoryCluster, id)
+	return err
+}
+
+const removeChannelFromGroup = `-- name: RemoveChannelFromGroup :exec
+DELETE FROM feed_group_channel
+WHERE group_id = ?1 AND channel_id = ?2
+`
+
+type RemoveChannelFromGroupParams struct {
+	GroupID   int64 `json:"group_id"`
+	ChannelID int64 `json:"channel_id"`
+}
+
+func (q *Queries) RemoveChannelFromGroup(ctx context.Context, arg RemoveChannelFromGroupParams) error {
+	_, err := q.db.ExecContext(ctx, removeChannelFromGroup, arg.GroupID, arg.ChannelID)
+	return err
+}
 
-SELECT id, link, host, last_update
-FROM (
-    SELECT
-        fc.id,
-        fc.link,
-        fc.host,
-        MAX(fl.last_update) AS last_update
-    FROM feed_channel AS fc
-    LEFT JOIN feed_channel_log fl ON fc.id = fl.channel_id
-    WHERE fc.enabled = 1
-    GROUP BY fc.id, fc.link, fc.host
-) AS LatestUpdates
-WHERE datetime('now', '-' || ?1 || ' minutes') > COALESCE(last_update, '1970-01-01')
-ORDER BY host, last_update DESC
+const removeFeedItemFromChannel = `-- name: RemoveFeedItemFromChannel :exec
+DELETE FROM feed_channel_item
+WHERE channel_id = ?1 AND item_id = ?2
 `
 
-type ListFeedChannelRow struct {
-	ID         int64       `json:"id"`
-	Link       string      `json:"link" validate:"required,url"`
-	Host       string      `json:"host"`
-	LastUpdate interface{} `json:"last_update"`
+type RemoveFeedItemFromChannelParams struct {
+	ChannelID int64 `json:"channel_id" validate:"required"`
+	ItemID    int64 `json:"item_id" validate:"required"`
 }
 
-// Feed Channel Queries
-func (q *Queries) ListFeedChannel(ctx context.Context, minutes sql.NullString) ([]ListFeedChannelRow, error) {
-	rows, err := q.db.QueryContext(ctx, listFeedChannel, minutes)
+func (q *Queries) RemoveFeedItemFromChannel(ctx context.Context, arg RemoveFeedItemFromChannelParams) error {
+	_, err := q.db.ExecContext(ctx, removeFeedItemFromChannel, arg.ChannelID, arg.ItemID)
+	return err
+}
+
+const restoreFeedChannel = `-- name: RestoreFeedChannel :execrows
+UPDATE feed_channel
+SET deleted_at = NULL
+WHERE id = ?1 AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) RestoreFeedChannel(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreFeedChannel, id)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []ListFeedChannelRow
-	for rows.Next() {
-		var i ListFeedChannelRow
-		if err := rows.Scan(
-			&i.ID,
-			&i.Link,
-			&i.Host,
-			&i.LastUpdate,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return result.RowsAffected()
+}
+
+const restoreFeedItem = `-- name: RestoreFeedItem :execrows
+UPDATE feed_item
+SET deleted = 0, deleted_at = NULL
+WHERE id = ?1 AND deleted = 1
+`
+
+func (q *Queries) RestoreFeedItem(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreFeedItem, id)
+	if err != nil {
+		return 0, err
 	}
-	return items, nil
+	return result.RowsAffected()
 }
 
-const listFeedItem = `-- name: ListFeedItem :many
+const searchFeedItem = `-- name: SearchFeedItem :many
 
-SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published
-FROM feed_item AS fi
-LEFT JOIN feed_channel_item AS fci ON fi.id = fci.item_id
-WHERE fci.channel_id = ?1
-ORDER BY published DESC
+WITH RECURSIVE subgroup (id) AS (
+    SELECT fg.id FROM feed_group AS fg WHERE fg.id = ?3
+    UNION
+    SELECT fg.id FROM feed_group AS fg JOIN subgroup AS sg ON fg.parent_id = sg.id
+)
+SELECT fi.id, fi.link, fi.author, fi.published,
+       highlight(item_search, 0, char(2), char(3)) AS title_highlight,
+       snippet(item_search, 1, char(2), char(3), '...', 24) AS snippet,
+       bm25(item_search, 10.0, 1.0, 5.0, 1.0, 0.5, 0.5) AS score
+FROM item_search
+JOIN feed_item AS fi ON fi.id = item_search.rowid
+WHERE item_search MATCH ?1
+  AND fi.deleted = 0
+  AND fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                WHERE fc.deleted_at IS NULL)
+  AND (CAST(?2 AS INTEGER) IS NULL
+       OR fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci WHERE fci.channel_id = ?2))
+  AND (CAST(?3 AS INTEGER) IS NULL
+       OR fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                    JOIN feed_group_channel AS fgc ON fgc.channel_id = fci.channel_id
+                    JOIN subgroup AS sg ON fgc.group_id = sg.id))
+  AND (datetime(fi.published) >= datetime(CAST(?4 AS TEXT)) OR ?4 IS NULL)
+  AND (datetime(fi.published) < datetime(CAST(?5 AS TEXT)) OR ?5 IS NULL)
+ORDER BY score, fi.id
+LIMIT ?7 OFFSET ?6
 `
 
-type ListFeedItemRow struct {
-	ID              int64        `json:"id"`
-	Guid            null.String  `json:"guid,omitempty" validate:"required"`
-	GuidIsPermalink sql.NullBool `json:"guid_is_permalink"`
-	Title           string       `json:"title"`
-	Description     null.String  `json:"description,omitempty" validate:"required"`
-	Link            string       `json:"link"`
-	Author          *string      `json:"author,omitempty" validate:"required"`
-	Published       null.Time    `json:"published" validate:"required"`
+type SearchFeedItemParams struct {
+	Query         string         `json:"query"`
+	ChannelID     sql.NullInt64  `json:"channel_id"`
+	GroupID       sql.NullInt64  `json:"group_id"`
+	PublishedFrom sql.NullString `json:"published_from"`
+	PublishedTo   sql.NullString `json:"published_to"`
+	Offset        int64          `json:"offset"`
+	Limit         int64          `json:"limit"`
 }
 
-// Feed Data Queries
-func (q *Queries) ListFeedItem(ctx context.Context, id int64) ([]ListFeedItemRow, error) {
-	rows, err := q.db.QueryContext(ctx, listFeedItem, id)
+type SearchFeedItemRow struct {
+	ID             int64     `json:"id"`
+	Link           string    `json:"link"`
+	Author         *string   `json:"author,omitempty" validate:"required"`
+	Published      null.Time `json:"published" validate:"required"`
+	TitleHighlight string    `json:"title_highlight"`
+	Snippet        string    `json:"snippet"`
+	Score          float64   `json:"score"`
+}
+
+// Search Queries
+// Items matching the FTS5 query, the best first. Matches in titles rank higher than in authors and in the text,
+// the description, the content and the extracted article. The snippet is taken from the plain text.
+// Highlighted terms are marked with the control characters 0x02 and 0x03, so they can be told from the item text.
+func (q *Queries) SearchFeedItem(ctx context.Context, arg SearchFeedItemParams) ([]SearchFeedItemRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchFeedItem,
+		arg.Query,
+		arg.ChannelID,
+		arg.GroupID,
+		arg.PublishedFrom,
+		arg.PublishedTo,
+		arg.Offset,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListFeedItemRow
+	var items []SearchFeedItemRow
 	for rows.Next() {
-		var i ListFeedItemRow
+		var i SearchFeedItemRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.Guid,
-			&i.GuidIsPermalink,
-			&i.Title,
-			&i.Description,
 			&i.Link,
 			&i.Author,
 			&i.Published,
+			&i.TitleHighlight,
+			&i.Snippet,
+			&i.Score,
 		); err != nil {
 			return nil, err
 		}
@@ -479,94 +3045,171 @@ func (q *Queries) ListFeedItem(ctx context.Context, id int64) ([]ListFeedItemRow
 	return items, nil
 }
 
-const listGroup = `-- name: ListGroup :many
+const setFeedChannelMove = `-- name: SetFeedChannelMove :exec
+UPDATE feed_channel
+SET moved_to = ?1, moved_count = ?2
+WHERE id = ?3
+`
 
-SELECT id, name
-FROM feed_group
-ORDER BY @order || ' ' || @direction
+type SetFeedChannelMoveParams struct {
+	MovedTo    null.String `json:"moved_to"`
+	MovedCount int64       `json:"moved_count"`
+	ID         int64       `json:"id"`
+}
+
+func (q *Queries) SetFeedChannelMove(ctx context.Context, arg SetFeedChannelMoveParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedChannelMove, arg.MovedTo, arg.MovedCount, arg.ID)
+	return err
+}
+
+const setFeedItemRead = `-- name: SetFeedItemRead :execrows
+UPDATE feed_item
+SET read = ?1
+WHERE id = ?2
 `
 
-type ListGroupRow struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+type SetFeedItemReadParams struct {
+	Read bool  `json:"read"`
+	ID   int64 `json:"id"`
 }
 
-// Feed Group Queries
-func (q *Queries) ListGroup(ctx context.Context) ([]ListGroupRow, error) {
-	rows, err := q.db.QueryContext(ctx, listGroup)
+func (q *Queries) SetFeedItemRead(ctx context.Context, arg SetFeedItemReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedItemRead, arg.Read, arg.ID)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []ListGroupRow
-	for rows.Next() {
-		var i ListGroupRow
-		if err := rows.Scan(&i.ID, &i.Name); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return result.RowsAffected()
+}
+
+const setFeedItemReadLater = `-- name: SetFeedItemReadLater :execrows
+UPDATE feed_item
+SET read_later = ?1
+WHERE id = ?2
+`
+
+type SetFeedItemReadLaterParams struct {
+	ReadLater bool  `json:"read_later"`
+	ID        int64 `json:"id"`
+}
+
+func (q *Queries) SetFeedItemReadLater(ctx context.Context, arg SetFeedItemReadLaterParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedItemReadLater, arg.ReadLater, arg.ID)
+	if err != nil {
+		return 0, err
 	}
-	return items, nil
+	return result.RowsAffected()
 }
 
-const removeChannelFromGroup = `-- name: RemoveChannelFromGroup :exec
-DELETE FROM feed_group_channel
-WHERE group_id = ?1 AND channel_id = ?2
+const setFeedItemStarred = `-- name: SetFeedItemStarred :execrows
+UPDATE feed_item
+SET starred = ?1
+WHERE id = ?2
 `
 
-type RemoveChannelFromGroupParams struct {
-	GroupID   int64 `json:"group_id"`
-	ChannelID int64 `json:"channel_id"`
+type SetFeedItemStarredParams struct {
+	Starred bool  `json:"starred"`
+	ID      int64 `json:"id"`
 }
 
-func (q *Queries) RemoveChannelFromGroup(ctx context.Context, arg RemoveChannelFromGroupParams) error {
-	_, err := q.db.ExecContext(ctx, removeChannelFromGroup, arg.GroupID, arg.ChannelID)
-	return err
+func (q *Queries) SetFeedItemStarred(ctx context.Context, arg SetFeedItemStarredParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedItemStarred, arg.Starred, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const removeFeedItemFromChannel = `-- name: RemoveFeedItemFromChannel :exec
-DELETE FROM feed_channel_item
-WHERE channel_id = ?1 AND item_id = ?2
+const trashFeedChannel = `-- name: TrashFeedChannel :execrows
+UPDATE feed_channel
+SET deleted_at = COALESCE(deleted_at, datetime('now'))
+WHERE id = ?1
 `
 
-type RemoveFeedItemFromChannelParams struct {
-	ChannelID int64 `json:"channel_id" validate:"required"`
-	ItemID    int64 `json:"item_id" validate:"required"`
+// A channel in the trash is not fetched and its items are left out of the lists of several channels
+func (q *Queries) TrashFeedChannel(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, trashFeedChannel, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (q *Queries) RemoveFeedItemFromChannel(ctx context.Context, arg RemoveFeedItemFromChannelParams) error {
-	_, err := q.db.ExecContext(ctx, removeFeedItemFromChannel, arg.ChannelID, arg.ItemID)
-	return err
+const trashFeedItem = `-- name: TrashFeedItem :execrows
+UPDATE feed_item
+SET deleted = 1, deleted_at = COALESCE(deleted_at, datetime('now'))
+WHERE id = ?1
+`
+
+// The item stays in the database until it's purged, so the gatherer finds it and doesn't store it again
+func (q *Queries) TrashFeedItem(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, trashFeedItem, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const updateFeedChannel = `-- name: UpdateFeedChannel :exec
+const updateFeedChannel = `-- name: UpdateFeedChannel :execrows
 UPDATE feed_channel
-SET title = ?1, description = ?2, link = ?3, host = ?4
-WHERE id = ?5
+SET title = ?1, description = ?2, link = ?3, host = ?4,
+    original_link = CASE WHEN link = ?3 THEN original_link ELSE ?3 END,
+    update_interval = ?5, adaptive_interval = ?6,
+    proxy = ?7, tls_insecure = ?8, metadata_locked = ?9,
+    full_text = ?10, content_selector = ?11
+WHERE id = ?12 AND deleted_at IS NULL
 `
 
 type UpdateFeedChannelParams struct {
-	Title       string `json:"title" validate:"required,min=5,max=20"`
-	Description string `json:"description"`
-	Link        string `json:"link" validate:"required,url"`
-	Host        string `json:"host"`
-	ID          int64  `json:"id"`
+	Title            string      `json:"title" validate:"required,min=5,max=20"`
+	Description      string      `json:"description"`
+	Link             string      `json:"link" validate:"required,url"`
+	Host             string      `json:"host"`
+	UpdateInterval   null.Int    `json:"update_interval"`
+	AdaptiveInterval bool        `json:"adaptive_interval"`
+	Proxy            null.String `json:"proxy"`
+	TlsInsecure      bool        `json:"tls_insecure"`
+	MetadataLocked   bool        `json:"metadata_locked"`
+	FullText         bool        `json:"full_text"`
+	ContentSelector  null.String `json:"content_selector"`
+	ID               int64       `json:"id"`
 }
 
-func (q *Queries) UpdateFeedChannel(ctx context.Context, arg UpdateFeedChannelParams) error {
-	_, err := q.db.ExecContext(ctx, updateFeedChannel,
+// A link changed by hand starts a new redirect history. The channels in the trash are restored before they are edited
+func (q *Queries) UpdateFeedChannel(ctx context.Context, arg UpdateFeedChannelParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateFeedChannel,
 		arg.Title,
 		arg.Description,
 		arg.Link,
 		arg.Host,
+		arg.UpdateInterval,
+		arg.AdaptiveInterval,
+		arg.Proxy,
+		arg.TlsInsecure,
+		arg.MetadataLocked,
+		arg.FullText,
+		arg.ContentSelector,
 		arg.ID,
 	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeedChannelCacheHeaders = `-- name: UpdateFeedChannelCacheHeaders :exec
+UPDATE feed_channel
+SET etag = ?1, last_modified = ?2
+WHERE id = ?3
+`
+
+type UpdateFeedChannelCacheHeadersParams struct {
+	Etag         sql.NullString `json:"etag"`
+	LastModified sql.NullString `json:"last_modified"`
+	ID           int64          `json:"id"`
+}
+
+func (q *Queries) UpdateFeedChannelCacheHeaders(ctx context.Context, arg UpdateFeedChannelCacheHeadersParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedChannelCacheHeaders, arg.Etag, arg.LastModified, arg.ID)
 	return err
 }
 
@@ -588,7 +3231,7 @@ func (q *Queries) UpdateFeedChannelFDescription(ctx context.Context, arg UpdateF
 
 const updateFeedChannelFLink = `-- name: UpdateFeedChannelFLink :exec
 UPDATE feed_channel
-SET link = ?1
+SET link = ?1, original_link = CASE WHEN link = ?1 THEN original_link ELSE ?1 END
 WHERE id = ?2
 `
 
@@ -635,6 +3278,72 @@ func (q *Queries) UpdateFeedChannelFTitleAndDescription(ctx context.Context, arg
 	return err
 }
 
+const updateFeedChannelFailed = `-- name: UpdateFeedChannelFailed :exec
+UPDATE feed_channel
+SET failure_count = ?1, next_fetch_at = datetime('now', '+' || CAST(?2 AS INTEGER) || ' seconds')
+WHERE id = ?3
+`
+
+type UpdateFeedChannelFailedParams struct {
+	FailureCount int64 `json:"failure_count"`
+	DelaySeconds int64 `json:"delay_seconds"`
+	ID           int64 `json:"id"`
+}
+
+func (q *Queries) UpdateFeedChannelFailed(ctx context.Context, arg UpdateFeedChannelFailedParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedChannelFailed, arg.FailureCount, arg.DelaySeconds, arg.ID)
+	return err
+}
+
+const updateFeedChannelMetadata = `-- name: UpdateFeedChannelMetadata :exec
+UPDATE feed_channel
+SET title = ?1, description = ?2, site_link = ?3, language = ?4,
+    image_url = ?5, generator = ?6, last_build_date = ?7, updated = datetime('now')
+WHERE id = ?8
+`
+
+type UpdateFeedChannelMetadataParams struct {
+	Title         string      `json:"title" validate:"required,min=5,max=20"`
+	Description   string      `json:"description"`
+	SiteLink      null.String `json:"site_link"`
+	Language      null.String `json:"language"`
+	ImageUrl      null.String `json:"image_url"`
+	Generator     null.String `json:"generator"`
+	LastBuildDate null.Time   `json:"last_build_date"`
+	ID            int64       `json:"id"`
+}
+
+func (q *Queries) UpdateFeedChannelMetadata(ctx context.Context, arg UpdateFeedChannelMetadataParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedChannelMetadata,
+		arg.Title,
+		arg.Description,
+		arg.SiteLink,
+		arg.Language,
+		arg.ImageUrl,
+		arg.Generator,
+		arg.LastBuildDate,
+		arg.ID,
+	)
+	return err
+}
+
+const updateFeedChannelSucceeded = `-- name: UpdateFeedChannelSucceeded :exec
+UPDATE feed_channel
+SET failure_count = 0, learned_interval = ?1, next_fetch_at = datetime('now', '+' || CAST(?2 AS INTEGER) || ' seconds')
+WHERE id = ?3
+`
+
+type UpdateFeedChannelSucceededParams struct {
+	LearnedInterval sql.NullInt64 `json:"learned_interval"`
+	DelaySeconds    int64         `json:"delay_seconds"`
+	ID              int64         `json:"id"`
+}
+
+func (q *Queries) UpdateFeedChannelSucceeded(ctx context.Context, arg UpdateFeedChannelSucceededParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedChannelSucceeded, arg.LearnedInterval, arg.DelaySeconds, arg.ID)
+	return err
+}
+
 const updateFeedItem = `-- name: UpdateFeedItem :exec
 UPDATE feed_item
 SET guid = ?, guid_is_permalink = ?, title = ?, description = ?, link = ?, author = ?, published = ?
@@ -666,35 +3375,160 @@ func (q *Queries) UpdateFeedItem(ctx context.Context, arg UpdateFeedItemParams)
 	return err
 }
 
-const updateFeedItemFDeleted = `-- name: UpdateFeedItemFDeleted :exec
+const updateFeedItemCanonicalLink = `-- name: UpdateFeedItemCanonicalLink :exec
 UPDATE feed_item
-SET deleted = 1
-WHERE id = ?
+SET canonical_link = ?1
+WHERE id = ?2
+`
+
+type UpdateFeedItemCanonicalLinkParams struct {
+	CanonicalLink null.String `json:"canonical_link"`
+	ID            int64       `json:"id"`
+}
+
+func (q *Queries) UpdateFeedItemCanonicalLink(ctx context.Context, arg UpdateFeedItemCanonicalLinkParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedItemCanonicalLink, arg.CanonicalLink, arg.ID)
+	return err
+}
+
+const updateFeedItemExtraction = `-- name: UpdateFeedItemExtraction :exec
+UPDATE feed_item
+SET extracted_content = ?1, extraction_status = ?2, extraction_error = ?3,
+    extracted = datetime('now')
+WHERE id = ?4
+`
+
+type UpdateFeedItemExtractionParams struct {
+	ExtractedContent null.String `json:"extracted_content,omitempty"`
+	ExtractionStatus null.String `json:"extraction_status"`
+	ExtractionError  null.String `json:"extraction_error"`
+	ID               int64       `json:"id"`
+}
+
+func (q *Queries) UpdateFeedItemExtraction(ctx context.Context, arg UpdateFeedItemExtractionParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedItemExtraction,
+		arg.ExtractedContent,
+		arg.ExtractionStatus,
+		arg.ExtractionError,
+		arg.ID,
+	)
+	return err
+}
+
+const updateFeedItemFingerprint = `-- name: UpdateFeedItemFingerprint :exec
+UPDATE feed_item
+SET simhash = ?1
+WHERE id = ?2
+`
+
+type UpdateFeedItemFingerprintParams struct {
+	Simhash null.Int `json:"simhash"`
+	ID      int64    `json:"id"`
+}
+
+func (q *Queries) UpdateFeedItemFingerprint(ctx context.Context, arg UpdateFeedItemFingerprintParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedItemFingerprint, arg.Simhash, arg.ID)
+	return err
+}
+
+const updateFeedItemSanitized = `-- name: UpdateFeedItemSanitized :exec
+UPDATE feed_item
+SET description = ?1, content = ?2, description_raw = ?3, content_raw = ?4,
+    text = ?5
+WHERE id = ?6
 `
 
-func (q *Queries) UpdateFeedItemFDeleted(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, updateFeedItemFDeleted, id)
+type UpdateFeedItemSanitizedParams struct {
+	Description    null.String `json:"description,omitempty" validate:"required"`
+	Content        null.String `json:"content,omitempty"`
+	DescriptionRaw null.String `json:"description_raw,omitempty"`
+	ContentRaw     null.String `json:"content_raw,omitempty"`
+	Text           null.String `json:"text"`
+	ID             int64       `json:"id"`
+}
+
+// Keeps the HTML as published and stores it sanitized
+func (q *Queries) UpdateFeedItemSanitized(ctx context.Context, arg UpdateFeedItemSanitizedParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedItemSanitized,
+		arg.Description,
+		arg.Content,
+		arg.DescriptionRaw,
+		arg.ContentRaw,
+		arg.Text,
+		arg.ID,
+	)
 	return err
 }
 
 const updateFeedItemShort = `-- name: UpdateFeedItemShort :exec
 UPDATE feed_item
-SET title = ?, description = ?, link = ?
-WHERE id = ?
+SET title = ?1, description = ?2, link = ?3, content = ?4, source_updated = ?5,
+    description_raw = ?6, content_raw = ?7, text = ?8,
+    canonical_link = CASE WHEN link = ?3 THEN canonical_link ELSE ?9 END,
+    edited = edited OR ?10, updated = datetime('now')
+WHERE id = ?11
 `
 
 type UpdateFeedItemShortParams struct {
-	Title       string      `json:"title"`
-	Description null.String `json:"description,omitempty" validate:"required"`
-	Link        string      `json:"link"`
-	ID          int64       `json:"id"`
+	Title          string      `json:"title"`
+	Description    null.String `json:"description,omitempty" validate:"required"`
+	Link           string      `json:"link"`
+	Content        null.String `json:"content,omitempty"`
+	SourceUpdated  null.Time   `json:"source_updated"`
+	DescriptionRaw null.String `json:"description_raw,omitempty"`
+	ContentRaw     null.String `json:"content_raw,omitempty"`
+	Text           null.String `json:"text"`
+	CanonicalLink  null.String `json:"canonical_link"`
+	Edited         bool        `json:"edited"`
+	ID             int64       `json:"id"`
 }
 
+// The canonical link found on the item page is kept while the link is the same. The item stays edited once
+// a revision of it is recorded, a new update time alone doesn't make it edited.
 func (q *Queries) UpdateFeedItemShort(ctx context.Context, arg UpdateFeedItemShortParams) error {
 	_, err := q.db.ExecContext(ctx, updateFeedItemShort,
 		arg.Title,
 		arg.Description,
 		arg.Link,
+		arg.Content,
+		arg.SourceUpdated,
+		arg.DescriptionRaw,
+		arg.ContentRaw,
+		arg.Text,
+		arg.CanonicalLink,
+		arg.Edited,
+		arg.ID,
+	)
+	return err
+}
+
+const updateFetchRun = `-- name: UpdateFetchRun :exec
+UPDATE fetch_run
+SET finished = ?1, succeeded = ?2, failed = ?3, skipped = ?4, fetch_time_ms = ?5,
+    slowest_channel_id = ?6, slowest_fetch_ms = ?7
+WHERE id = ?8
+`
+
+type UpdateFetchRunParams struct {
+	Finished         sql.NullTime  `json:"finished"`
+	Succeeded        int64         `json:"succeeded"`
+	Failed           int64         `json:"failed"`
+	Skipped          int64         `json:"skipped"`
+	FetchTimeMs      int64         `json:"fetch_time_ms"`
+	SlowestChannelID sql.NullInt64 `json:"slowest_channel_id"`
+	SlowestFetchMs   int64         `json:"slowest_fetch_ms"`
+	ID               int64         `json:"id"`
+}
+
+func (q *Queries) UpdateFetchRun(ctx context.Context, arg UpdateFetchRunParams) error {
+	_, err := q.db.ExecContext(ctx, updateFetchRun,
+		arg.Finished,
+		arg.Succeeded,
+		arg.Failed,
+		arg.Skipped,
+		arg.FetchTimeMs,
+		arg.SlowestChannelID,
+		arg.SlowestFetchMs,
 		arg.ID,
 	)
 	return err
@@ -715,3 +3549,34 @@ func (q *Queries) UpdateGroup(ctx context.Context, arg UpdateGroupParams) error
 	_, err := q.db.ExecContext(ctx, updateGroup, arg.Name, arg.ID)
 	return err
 }
+
+const upsertItemEnclosure = `-- name: UpsertItemEnclosure :exec
+INSERT INTO item_enclosure (item_id, url, mime_type, medium, length, duration, thumbnail)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+ON CONFLICT (item_id, url) DO UPDATE
+SET mime_type = excluded.mime_type, medium = excluded.medium, length = excluded.length,
+    duration = excluded.duration, thumbnail = excluded.thumbnail
+`
+
+type UpsertItemEnclosureParams struct {
+	ItemID    int64       `json:"item_id"`
+	Url       string      `json:"url"`
+	MimeType  null.String `json:"mime_type"`
+	Medium    null.String `json:"medium"`
+	Length    null.Int    `json:"length"`
+	Duration  null.Int    `json:"duration"`
+	Thumbnail null.String `json:"thumbnail"`
+}
+
+func (q *Queries) UpsertItemEnclosure(ctx context.Context, arg UpsertItemEnclosureParams) error {
+	_, err := q.db.ExecContext(ctx, upsertItemEnclosure,
+		arg.ItemID,
+		arg.Url,
+		arg.MimeType,
+		arg.Medium,
+		arg.Length,
+		arg.Duration,
+		arg.Thumbnail,
+	)
+	return err
+}
@@ -2,24 +2,35 @@
 -- Feed Channel Queries
 
 -- name: ListFeedChannel :many
-SELECT id, link, host, last_update
-FROM (
-    SELECT
-        fc.id,
-        fc.link,
-        fc.host,
-        MAX(fl.last_update) AS last_update
-    FROM feed_channel AS fc
-    LEFT JOIN feed_channel_log fl ON fc.id = fl.channel_id
-    WHERE fc.enabled = 1
-    GROUP BY fc.id, fc.link, fc.host
-) AS LatestUpdates
-WHERE datetime('now', '-' || @minutes || ' minutes') > COALESCE(last_update, '1970-01-01')
-ORDER BY host, last_update DESC;
+SELECT id, link, host, next_fetch_at
+FROM feed_channel
+WHERE enabled = 1 AND deleted_at IS NULL
+ORDER BY next_fetch_at;
+
+-- name: ListFeedChannelIDs :many
+-- Only the enabled channels with enabled_only, channels in the trash are left out
+SELECT id
+FROM feed_channel
+WHERE deleted_at IS NULL AND (enabled = 1 OR CAST(sqlc.arg(enabled_only) AS BOOLEAN) = 0)
+ORDER BY id;
+
+-- name: GetFeedChannelSchedule :one
+SELECT id, link, host, enabled, next_fetch_at
+FROM feed_channel
+WHERE id = @id AND deleted_at IS NULL;
+
+-- name: GetFeedChannelForFetch :one
+SELECT id, link, host, etag, last_modified, failure_count, update_interval, adaptive_interval, learned_interval,
+       proxy, tls_insecure, moved_to, moved_count, full_text, content_selector
+FROM feed_channel
+WHERE id = @id AND deleted_at IS NULL;
 
 -- name: ListAllFeedChannel :many
-SELECT id, title, description, link, host, published, enabled
+SELECT id, title, description, link, host, published, enabled, failure_count, next_fetch_at, disabled_reason,
+       update_interval, adaptive_interval, learned_interval, proxy, tls_insecure, original_link, gone,
+       site_link, language, image_url, generator, last_build_date, metadata_locked, full_text, content_selector
 FROM feed_channel
+WHERE deleted_at IS NULL
 ORDER BY title;
 
 -- name: GetFeedChannelsIDs :many
@@ -30,18 +41,29 @@ WHERE item_id = ?;
 -- name: GetFeedChannel :one
 SELECT fc.id, fc.title, fc.description, fc.link, fc.host, fc.published
 FROM feed_channel AS fc
-WHERE fc.id = @id
+WHERE fc.id = @id AND fc.deleted_at IS NULL
 LIMIT 1;
 
 -- name: CreateFeedChannel :one
-INSERT INTO feed_channel (title, description, link, host)
-VALUES (@title, @description, @link, @host)
+INSERT INTO feed_channel (
+    title, description, link, original_link, host, update_interval, adaptive_interval, proxy, tls_insecure, metadata_locked,
+    full_text, content_selector
+)
+VALUES (
+    @title, @description, @link, @link, @host, @update_interval, @adaptive_interval, @proxy, @tls_insecure, @metadata_locked,
+    @full_text, @content_selector
+)
 RETURNING id, title, description, link, host, published;
 
--- name: UpdateFeedChannel :exec
+-- name: UpdateFeedChannel :execrows
+-- A link changed by hand starts a new redirect history. The channels in the trash are restored before they are edited
 UPDATE feed_channel
-SET title = @title, description = @description, link = @link, host = @host
-WHERE id = @id;
+SET title = @title, description = @description, link = @link, host = @host,
+    original_link = CASE WHEN link = @link THEN original_link ELSE @link END,
+    update_interval = @update_interval, adaptive_interval = @adaptive_interval,
+    proxy = @proxy, tls_insecure = @tls_insecure, metadata_locked = @metadata_locked,
+    full_text = @full_text, content_selector = @content_selector
+WHERE id = @id AND deleted_at IS NULL;
 
 -- name: UpdateFeedChannelFTitle :exec
 UPDATE feed_channel
@@ -60,48 +82,322 @@ WHERE id = @id;
 
 -- name: UpdateFeedChannelFLink :exec
 UPDATE feed_channel
-SET link = @link
+SET link = @link, original_link = CASE WHEN link = @link THEN original_link ELSE @link END
 WHERE id = @id;
 
--- name: DeleteFeedChannel :exec
-DELETE FROM feed_channel
-WHERE id = ?;
+-- name: GetFeedChannelMetadata :one
+SELECT id, title, description, site_link, language, image_url, generator, last_build_date, metadata_locked
+FROM feed_channel
+WHERE id = @id AND deleted_at IS NULL;
+
+-- name: UpdateFeedChannelMetadata :exec
+UPDATE feed_channel
+SET title = @title, description = @description, site_link = @site_link, language = @language,
+    image_url = @image_url, generator = @generator, last_build_date = @last_build_date, updated = datetime('now')
+WHERE id = @id;
+
+-- name: UpdateFeedChannelCacheHeaders :exec
+UPDATE feed_channel
+SET etag = @etag, last_modified = @last_modified
+WHERE id = @id;
+
+-- name: UpdateFeedChannelSucceeded :exec
+UPDATE feed_channel
+SET failure_count = 0, learned_interval = @learned_interval, next_fetch_at = datetime('now', '+' || CAST(@delay_seconds AS INTEGER) || ' seconds')
+WHERE id = @id;
+
+-- name: UpdateFeedChannelFailed :exec
+UPDATE feed_channel
+SET failure_count = @failure_count, next_fetch_at = datetime('now', '+' || CAST(@delay_seconds AS INTEGER) || ' seconds')
+WHERE id = @id;
+
+-- name: DisableFeedChannel :exec
+UPDATE feed_channel
+SET enabled = 0, disabled_reason = @disabled_reason
+WHERE id = @id;
+
+-- name: MarkFeedChannelGone :exec
+UPDATE feed_channel
+SET enabled = 0, gone = 1, disabled_reason = @disabled_reason
+WHERE id = @id;
+
+-- name: SetFeedChannelMove :exec
+UPDATE feed_channel
+SET moved_to = @moved_to, moved_count = @moved_count
+WHERE id = @id;
+
+-- The validators of the old link don't apply to the new one, so the next fetch is a full one
+-- name: MoveFeedChannel :exec
+UPDATE feed_channel
+SET link = @link, host = @host, etag = NULL, last_modified = NULL, moved_to = NULL, moved_count = 0
+WHERE id = @id;
+
+-- name: CreateFeedChannelLink :exec
+INSERT INTO feed_channel_link (channel_id, old_link, new_link, redirects)
+VALUES (@channel_id, @old_link, @new_link, @redirects);
+
+-- name: ListFeedChannelLink :many
+SELECT id, channel_id, old_link, new_link, redirects, changed
+FROM feed_channel_link
+WHERE channel_id = @channel_id
+ORDER BY id DESC;
+
+-- name: EnableFeedChannel :exec
+UPDATE feed_channel
+SET enabled = 1, gone = 0, disabled_reason = NULL, failure_count = 0, next_fetch_at = NULL
+WHERE id = @id;
+
+-- name: GetFeedChannelHealth :one
+SELECT id, link, original_link, moved_to, enabled, gone, failure_count, next_fetch_at, disabled_reason
+FROM feed_channel
+WHERE id = @id AND deleted_at IS NULL;
+
+-- name: TrashFeedChannel :execrows
+-- A channel in the trash is not fetched and its items are left out of the lists of several channels
+UPDATE feed_channel
+SET deleted_at = COALESCE(deleted_at, datetime('now'))
+WHERE id = @id;
+
+-- name: RestoreFeedChannel :execrows
+UPDATE feed_channel
+SET deleted_at = NULL
+WHERE id = @id AND deleted_at IS NOT NULL;
+
+-- name: ListTrashedFeedChannel :many
+SELECT id, title, description, link, host, enabled, deleted_at
+FROM feed_channel
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC;
 
 -- name: CreateFeedChannelLog :exec
-INSERT INTO feed_channel_log (channel_id, last_update)
-VALUES (?, datetime('now'));
+INSERT INTO feed_channel_log (
+    channel_id, started, last_update, status_code, bytes_received, error_class, error_message,
+    items_new, items_updated, items_unchanged, url, effective_url, redirects, items_merged, merges,
+    items_failed, failures, run_id, duration_ms
+)
+VALUES (
+    @channel_id, @started, datetime('now'), @status_code, @bytes_received, @error_class, @error_message,
+    @items_new, @items_updated, @items_unchanged, @url, @effective_url, @redirects, @items_merged, @merges,
+    @items_failed, @failures, @run_id, @duration_ms
+);
+
+-- name: ListFeedChannelLog :many
+SELECT id, channel_id, started, last_update, status_code, bytes_received, error_class, error_message,
+       items_new, items_updated, items_unchanged, url, effective_url, redirects, items_merged, merges,
+       items_failed, failures, run_id, duration_ms
+FROM feed_channel_log
+WHERE channel_id = @channel_id
+ORDER BY id DESC
+LIMIT @limit OFFSET @offset;
 
 -- name: GetLastChannelUpdateDate :one
 SELECT last_update
 FROM feed_channel_log
-WHERE channel_id = @id
+WHERE channel_id = @id AND error_class IS NULL
 ORDER BY last_update DESC
 LIMIT 1;
 
+-- name: GetLastChannelError :one
+SELECT last_update, status_code, error_class, error_message
+FROM feed_channel_log
+WHERE channel_id = @id AND error_class IS NOT NULL
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: ListFeedChannelPublished :many
+SELECT fi.published
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = @channel_id AND fi.published IS NOT NULL
+ORDER BY fi.published DESC
+LIMIT @limit;
+
 -- Feed Data Queries
 
 -- name: ListFeedItem :many
-SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.text, fi.link, fi.canonical_link, fi.author, fi.published,
+       fi.source_updated, fi.extraction_status, fi.edited, fi.cluster_id
 FROM feed_item AS fi
 LEFT JOIN feed_channel_item AS fci ON fi.id = fci.item_id
 WHERE fci.channel_id = @id
 ORDER BY published DESC;
 
+-- name: ListFeedItemPage :many
+-- A page of the items of a channel, or of the enabled channels of a group and its subgroups, of a tag or of all enabled channels,
+-- the newest published first. An item of several of these channels is listed once. Items without a publication date are
+-- sorted by their creation date. The page is read in the order of an index from the cursor on: the sort key and the id
+-- of the last item of the previous page, or the bounds of the sort key for the first page. The keys are compared
+-- as stored, the dates are stored in UTC. The other sorts replace the sort key and the direction of this query,
+-- see api.sortedItemPages.
+WITH RECURSIVE subgroup (id) AS (
+    SELECT fg.id FROM feed_group AS fg WHERE fg.id = sqlc.narg(group_id)
+    UNION
+    SELECT fg.id FROM feed_group AS fg JOIN subgroup AS sg ON fg.parent_id = sg.id
+),
+scope_channel AS (
+    SELECT fc.id AS channel_id
+    FROM feed_channel AS fc
+    WHERE CASE
+        WHEN CAST(sqlc.narg(channel_id) AS INTEGER) IS NOT NULL THEN fc.id = sqlc.narg(channel_id)
+        ELSE fc.enabled = 1 AND fc.deleted_at IS NULL
+            AND (sqlc.narg(group_id) IS NULL
+                 OR fc.id IN (SELECT fgc.channel_id FROM feed_group_channel AS fgc JOIN subgroup AS sg ON fgc.group_id = sg.id))
+            AND (CAST(sqlc.narg(tag_id) AS INTEGER) IS NULL
+                 OR fc.id IN (SELECT fct.channel_id FROM feed_channel_tag AS fct WHERE fct.tag_id = sqlc.narg(tag_id)))
+    END
+)
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.text, fi.link, fi.canonical_link, fi.author, fi.published,
+       fi.source_updated, fi.extraction_status, fi.edited, fi.cluster_id, fi.read, fi.starred, fi.read_later, fi.deleted, fi.created,
+       CAST(COALESCE(fi.published, fi.created) AS TEXT) AS sort_key
+FROM feed_item AS fi
+WHERE EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN scope_channel AS sc ON fci.channel_id = sc.channel_id
+              WHERE fci.item_id = fi.id)
+  AND (fi.read = CAST(sqlc.narg(read) AS BOOLEAN) OR sqlc.narg(read) IS NULL)
+  AND (fi.starred = CAST(sqlc.narg(starred) AS BOOLEAN) OR sqlc.narg(starred) IS NULL)
+  AND (fi.read_later = CAST(sqlc.narg(read_later) AS BOOLEAN) OR sqlc.narg(read_later) IS NULL)
+  AND (fi.deleted = CAST(sqlc.narg(deleted) AS BOOLEAN) OR sqlc.narg(deleted) IS NULL)
+  AND (datetime(fi.published) >= datetime(CAST(sqlc.narg(published_from) AS TEXT)) OR sqlc.narg(published_from) IS NULL)
+  AND (datetime(fi.published) < datetime(CAST(sqlc.narg(published_to) AS TEXT)) OR sqlc.narg(published_to) IS NULL)
+  AND (fi.author LIKE '%' || CAST(sqlc.narg(author) AS TEXT) || '%' OR sqlc.narg(author) IS NULL)
+  AND (EXISTS (SELECT 1 FROM item_enclosure AS ie WHERE ie.item_id = fi.id) = CAST(sqlc.narg(has_enclosures) AS BOOLEAN)
+       OR sqlc.narg(has_enclosures) IS NULL)
+  AND CAST(COALESCE(fi.published, fi.created) AS TEXT) <= CAST(@cursor_key AS TEXT)
+  AND (CAST(COALESCE(fi.published, fi.created) AS TEXT) < @cursor_key OR fi.id < @cursor_id)
+ORDER BY CAST(COALESCE(fi.published, fi.created) AS TEXT) DESC, fi.id DESC
+LIMIT @limit;
+
+-- name: ListFeedItemContent :many
+SELECT fi.id, fi.content, fi.extracted_content
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = @id AND (fi.content IS NOT NULL OR fi.extracted_content IS NOT NULL);
+
+-- name: ListFeedItemContentByIDs :many
+SELECT fi.id, fi.content, fi.extracted_content
+FROM feed_item AS fi
+WHERE fi.id IN (sqlc.slice('ids')) AND (fi.content IS NOT NULL OR fi.extracted_content IS NOT NULL);
+
+-- name: SetFeedItemRead :execrows
+UPDATE feed_item
+SET read = @read
+WHERE id = @id;
+
+-- name: SetFeedItemStarred :execrows
+UPDATE feed_item
+SET starred = @starred
+WHERE id = @id;
+
+-- name: SetFeedItemReadLater :execrows
+UPDATE feed_item
+SET read_later = @read_later
+WHERE id = @id;
+
+-- name: MarkFeedItemsRead :execrows
+-- Marks the unread items of the channels as read. Only the items stored up to the newest one the client has seen
+-- and published before the date are marked, so the items arriving in the meantime stay unread. The items are
+-- compared by when they were stored rather than by id, SQLite reuses the id of the newest item once it's purged.
+UPDATE feed_item
+SET read = 1
+-- The channels come last, the numbered parameters before them would be shifted by the expanded slice.
+WHERE read = 0
+  AND (EXISTS (
+      SELECT 1
+      FROM feed_item AS seen
+      WHERE seen.id = CAST(sqlc.narg(max_item_id) AS INTEGER)
+        AND (feed_item.created < seen.created OR (feed_item.created = seen.created AND feed_item.id <= seen.id))
+  ) OR sqlc.narg(max_item_id) IS NULL)
+  AND (datetime(COALESCE(published, created)) < datetime(CAST(sqlc.narg(before) AS TEXT)) OR sqlc.narg(before) IS NULL)
+  AND id IN (SELECT fci.item_id FROM feed_channel_item AS fci WHERE fci.channel_id IN (sqlc.slice('channel_ids')));
+
+-- name: MarkFeedItemForExtraction :exec
+UPDATE feed_item
+SET extraction_status = 'pending'
+WHERE id = @id AND extraction_status IS NULL;
+
+-- name: ListFeedItemForExtraction :many
+-- Oldest first, the rest waits for the next fetch
+SELECT fi.id, fi.link
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = @channel_id AND fi.extraction_status = 'pending'
+ORDER BY fi.id
+LIMIT @limit;
+
+-- name: UpdateFeedItemExtraction :exec
+UPDATE feed_item
+SET extracted_content = @extracted_content, extraction_status = @extraction_status, extraction_error = @extraction_error,
+    extracted = datetime('now')
+WHERE id = @id;
+
+-- name: GetFeedItemRaw :one
+SELECT fi.id, fi.description_raw, fi.content_raw
+FROM feed_item AS fi
+WHERE fi.id = @id;
+
 -- name: GetFeedItemByGuid :one
-SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published
+-- GUIDs are unique only within a channel
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published,
+       fi.content, fi.source_updated, fi.canonical_link, fi.origin_channel_id
 FROM feed_item AS fi
-WHERE fi.guid = @guid
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = @channel_id AND fi.guid = @guid
 LIMIT 1;
 
--- name: GetFeedItemByLink :one
-SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published
+-- name: ListFeedItemByGuids :many
+-- Items of the channel with any of the GUIDs, looked up at once for the whole feed
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published,
+       fi.content, fi.description_raw, fi.content_raw, fi.source_updated, fi.canonical_link, fi.origin_channel_id
 FROM feed_item AS fi
-WHERE fi.link = @link AND (fi.guid IS NULL OR fi.guid = '')
-LIMIT 1;
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fci.channel_id = @channel_id AND fi.guid IN (sqlc.slice(guids))
+ORDER BY fi.id;
+
+-- name: ListFeedItemByCanonicalLinks :many
+-- Items of any channel with any of the canonical links, looked up at once for the whole feed
+SELECT fi.id, fi.guid, fi.guid_is_permalink, fi.title, fi.description, fi.link, fi.author, fi.published,
+       fi.content, fi.description_raw, fi.content_raw, fi.source_updated, fi.canonical_link, fi.origin_channel_id,
+       EXISTS (
+           SELECT 1 FROM feed_channel_item AS fci WHERE fci.item_id = fi.id AND fci.channel_id = @channel_id
+       ) AS in_channel
+FROM feed_item AS fi
+WHERE fi.canonical_link IN (sqlc.slice(canonical_links))
+ORDER BY fi.id;
+
+-- name: ListFeedItemLinksAsCanonical :many
+-- Items whose canonical link is their link as published, the next batch after the id
+SELECT fi.id, fi.link
+FROM feed_item AS fi
+WHERE fi.canonical_link = fi.link AND fi.id > @after_id
+ORDER BY fi.id
+LIMIT @limit;
+
+-- name: UpdateFeedItemCanonicalLink :exec
+UPDATE feed_item
+SET canonical_link = @canonical_link
+WHERE id = @id;
+
+-- name: ListUnsanitizedFeedItems :many
+-- Items stored before the HTML was sanitized, they have HTML but neither the HTML as published nor the plain text.
+-- The next batch after the id, with the link of the channel which relative URLs of items without a link point to.
+SELECT fi.id, fi.link, fi.description, fi.content, COALESCE(fc.link, '') AS channel_link
+FROM feed_item AS fi
+LEFT JOIN feed_channel AS fc ON fc.id = fi.origin_channel_id
+WHERE fi.description_raw IS NULL AND fi.content_raw IS NULL AND fi.text IS NULL
+  AND (fi.description != '' OR fi.content IS NOT NULL) AND fi.id > @after_id
+ORDER BY fi.id
+LIMIT @limit;
+
+-- name: UpdateFeedItemSanitized :exec
+-- Keeps the HTML as published and stores it sanitized
+UPDATE feed_item
+SET description = @description, content = @content, description_raw = @description_raw, content_raw = @content_raw,
+    text = @text
+WHERE id = @id;
 
 -- name: CreateFeedItem :one
-INSERT INTO feed_item (guid, guid_is_permalink, title, description, link, author, published)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO feed_item (guid, guid_is_permalink, title, description, link, author, published, content, source_updated,
+                       description_raw, content_raw, text, canonical_link, origin_channel_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING id, read, created, updated;
 
 -- name: CreateFeedChannelItem :exec
@@ -115,30 +411,115 @@ SET guid = ?, guid_is_permalink = ?, title = ?, description = ?, link = ?, autho
 WHERE id = ?;
 
 -- name: UpdateFeedItemShort :exec
+-- The canonical link found on the item page is kept while the link is the same. The item stays edited once
+-- a revision of it is recorded, a new update time alone doesn't make it edited.
 UPDATE feed_item
-SET title = ?, description = ?, link = ?
-WHERE id = ?;
+SET title = @title, description = @description, link = @link, content = @content, source_updated = @source_updated,
+    description_raw = @description_raw, content_raw = @content_raw, text = @text,
+    canonical_link = CASE WHEN link = @link THEN canonical_link ELSE @canonical_link END,
+    edited = edited OR @edited, updated = datetime('now')
+WHERE id = @id;
+
+-- name: CreateFeedItemRevision :exec
+-- Keeps the current version of the item before it's overwritten
+INSERT INTO feed_item_revision (item_id, title, description, link, content, text, source_updated, valid_from)
+SELECT fi.id, fi.title, fi.description, fi.link, fi.content, fi.text, fi.source_updated, COALESCE(fi.updated, fi.created)
+FROM feed_item AS fi
+WHERE fi.id = @id;
 
--- name: UpdateFeedItemFDeleted :exec
+-- name: ListFeedItemRevision :many
+SELECT id, item_id, title, description, link, content, text, source_updated, valid_from, replaced
+FROM feed_item_revision
+WHERE item_id = @item_id
+ORDER BY id;
+
+-- name: GetFeedItemVersion :one
+SELECT id, title, description, link, content, text, source_updated, edited, created, updated
+FROM feed_item
+WHERE id = @id;
+
+-- name: TrashFeedItem :execrows
+-- The item stays in the database until it's purged, so the gatherer finds it and doesn't store it again
 UPDATE feed_item
-SET deleted = 1
-WHERE id = ?;
+SET deleted = 1, deleted_at = COALESCE(deleted_at, datetime('now'))
+WHERE id = @id;
+
+-- name: RestoreFeedItem :execrows
+UPDATE feed_item
+SET deleted = 0, deleted_at = NULL
+WHERE id = @id AND deleted = 1;
+
+-- name: ListTrashedFeedItem :many
+SELECT fi.id, fi.title, fi.link, fi.author, fi.published, fi.deleted_at
+FROM feed_item AS fi
+WHERE fi.deleted = 1
+ORDER BY fi.deleted_at DESC, fi.id DESC
+LIMIT @limit OFFSET @offset;
 
 -- name: RemoveFeedItemFromChannel :exec
 DELETE FROM feed_channel_item
 WHERE channel_id = @channel_id AND item_id = @item_id;
 
--- name: DeleteFeedItem :exec
-DELETE FROM feed_item
-WHERE id = ?;
+
+-- name: UpsertItemEnclosure :exec
+INSERT INTO item_enclosure (item_id, url, mime_type, medium, length, duration, thumbnail)
+VALUES (@item_id, @url, @mime_type, @medium, @length, @duration, @thumbnail)
+ON CONFLICT (item_id, url) DO UPDATE
+SET mime_type = excluded.mime_type, medium = excluded.medium, length = excluded.length,
+    duration = excluded.duration, thumbnail = excluded.thumbnail;
+
+-- name: ListItemChannels :many
+-- Channels the items are published in, the channels in the trash are left out
+SELECT fci.item_id, fc.id AS channel_id, fc.title
+FROM feed_channel_item AS fci
+JOIN feed_channel AS fc ON fc.id = fci.channel_id
+WHERE fc.deleted_at IS NULL AND fci.item_id IN (sqlc.slice('item_ids'))
+ORDER BY fci.item_id, fc.id;
+
+-- name: ListItemEnclosuresByItems :many
+SELECT ie.id, ie.item_id, ie.url, ie.mime_type, ie.medium, ie.length, ie.duration, ie.thumbnail
+FROM item_enclosure AS ie
+WHERE ie.item_id IN (sqlc.slice('item_ids'))
+ORDER BY ie.item_id, ie.id;
 
 
 -- Feed Group Queries
 
 -- name: ListGroup :many
-SELECT id, name
+-- Groups sorted by name or id. sqlc doesn't bind parameters in ORDER BY, so they are passed through the CTE.
+WITH sorting AS (
+    SELECT CAST(@sort AS TEXT) AS sort, CAST(sqlc.arg(descending) AS BOOLEAN) AS descending
+)
+SELECT fg.id, fg.name
+FROM feed_group AS fg
+CROSS JOIN sorting
+ORDER BY CASE WHEN sorting.descending = 1 AND sorting.sort = 'name' THEN fg.name END DESC,
+         CASE sorting.descending WHEN 1 THEN fg.id END DESC,
+         CASE sorting.sort WHEN 'name' THEN fg.name END, fg.id;
+
+-- name: GetGroup :one
+SELECT id, name, parent_id
 FROM feed_group
-ORDER BY @order || ' ' || @direction;
+WHERE id = @id;
+
+-- name: ListGroupChannelIDs :many
+-- Channels of the group and its subgroups, only the enabled ones with enabled_only. Channels in the trash are left out.
+WITH RECURSIVE subgroup (id) AS (
+    SELECT fg.id FROM feed_group AS fg WHERE fg.id = @group_id
+    UNION
+    SELECT fg.id FROM feed_group AS fg JOIN subgroup AS sg ON fg.parent_id = sg.id
+)
+SELECT DISTINCT fgc.channel_id
+FROM feed_group_channel AS fgc
+JOIN subgroup AS sg ON fgc.group_id = sg.id
+JOIN feed_channel AS fc ON fc.id = fgc.channel_id
+WHERE fc.deleted_at IS NULL AND (fc.enabled = 1 OR CAST(sqlc.arg(enabled_only) AS BOOLEAN) = 0)
+ORDER BY fgc.channel_id;
+
+-- name: GetTag :one
+SELECT id, name, description
+FROM tag
+WHERE id = @id;
 
 -- name: CreateGroup :exec
 INSERT INTO feed_group (name, parent_id)
@@ -160,3 +541,269 @@ VALUES (@group_id, @channel_id);
 -- name: RemoveChannelFromGroup :exec
 DELETE FROM feed_group_channel
 WHERE group_id = @group_id AND channel_id = @channel_id;
+
+-- Story Cluster Queries
+
+-- name: ListFeedItemFingerprint :many
+-- Candidates for near-duplicates of a new item. Items first published by the same channel are left out,
+-- a channel repeating itself, such as a daily digest, doesn't make a story.
+SELECT id, title, simhash, cluster_id
+FROM feed_item
+WHERE simhash IS NOT NULL AND created >= @since AND id != @id
+  AND (origin_channel_id != @origin_channel_id OR origin_channel_id IS NULL);
+
+-- name: UpdateFeedItemFingerprint :exec
+UPDATE feed_item
+SET simhash = @simhash
+WHERE id = @id;
+
+-- name: CreateStoryCluster :one
+INSERT INTO story_cluster (title)
+VALUES (@title)
+RETURNING id;
+
+-- name: AddItemToStoryCluster :exec
+UPDATE feed_item
+SET cluster_id = @cluster_id
+WHERE id = @id;
+
+-- name: RefreshStoryCluster :exec
+-- A story counts the items which are not in the trash and are published in a channel which is not in the trash,
+-- the items ListStoryClusterItems lists
+UPDATE story_cluster
+SET item_count = (SELECT COUNT(*) FROM feed_item AS fi
+                  WHERE fi.cluster_id = story_cluster.id AND fi.deleted = 0
+                    AND EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                                WHERE fci.item_id = fi.id AND fc.deleted_at IS NULL)),
+    updated = datetime('now')
+WHERE story_cluster.id = @id;
+
+-- name: RecountItemStoryCluster :exec
+-- Counts the items of the story of an item again after it's moved to the trash or restored
+UPDATE story_cluster
+SET item_count = (SELECT COUNT(*) FROM feed_item AS fi
+                  WHERE fi.cluster_id = story_cluster.id AND fi.deleted = 0
+                    AND EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                                WHERE fci.item_id = fi.id AND fc.deleted_at IS NULL))
+WHERE story_cluster.id = (SELECT fi.cluster_id FROM feed_item AS fi WHERE fi.id = @item_id);
+
+-- name: ListStoryCluster :many
+SELECT id, title, item_count, created, updated
+FROM story_cluster
+WHERE item_count >= @min_items
+ORDER BY updated DESC, id DESC
+LIMIT @limit OFFSET @offset;
+
+-- name: ListStoryClusterItems :many
+SELECT fi.cluster_id, fi.id, fi.title, fi.link, fi.published, fc.id AS channel_id, fc.title AS channel_title
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+JOIN feed_channel AS fc ON fc.id = fci.channel_id
+WHERE fi.cluster_id IN (sqlc.slice('cluster_ids')) AND fi.deleted = 0 AND fc.deleted_at IS NULL
+ORDER BY fi.cluster_id, fi.published, fi.id, fc.id;
+
+-- Fetch Run Queries
+
+-- name: CreateFetchRun :one
+INSERT INTO fetch_run (started)
+VALUES (@started)
+RETURNING id;
+
+-- name: UpdateFetchRun :exec
+UPDATE fetch_run
+SET finished = @finished, succeeded = @succeeded, failed = @failed, skipped = @skipped, fetch_time_ms = @fetch_time_ms,
+    slowest_channel_id = @slowest_channel_id, slowest_fetch_ms = @slowest_fetch_ms
+WHERE id = @id;
+
+-- name: ListFetchRun :many
+SELECT *
+FROM fetch_run
+ORDER BY id DESC
+LIMIT @limit OFFSET @offset;
+
+-- name: ListFetchRunChannels :many
+-- Fetch attempts of the run, the failed ones first
+SELECT fcl.channel_id, fc.title AS channel_title, fcl.started, fcl.duration_ms, fcl.status_code, fcl.error_class,
+       fcl.error_message, fcl.items_new, fcl.items_updated, fcl.items_unchanged, fcl.items_merged, fcl.items_failed
+FROM feed_channel_log AS fcl
+LEFT JOIN feed_channel AS fc ON fc.id = fcl.channel_id
+WHERE fcl.run_id = @run_id
+ORDER BY fcl.error_class IS NULL, fcl.id;
+
+-- Counter Queries
+
+-- name: GetItemCounters :one
+-- Items of the channels in the trash are counted if they are published in another channel too
+SELECT CAST(COALESCE(SUM(fi.read = 0), 0) AS INTEGER) AS unread,
+       CAST(COALESCE(SUM(fi.starred), 0) AS INTEGER) AS starred,
+       CAST(COALESCE(SUM(fi.read_later), 0) AS INTEGER) AS read_later
+FROM feed_item AS fi
+WHERE fi.deleted = 0
+  AND fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                WHERE fc.deleted_at IS NULL);
+
+-- name: ListChannelUnreadCounts :many
+SELECT fc.id AS channel_id, COUNT(fi.id) AS unread
+FROM feed_channel AS fc
+LEFT JOIN feed_channel_item AS fci ON fci.channel_id = fc.id
+LEFT JOIN feed_item AS fi ON fi.id = fci.item_id AND fi.read = 0 AND fi.deleted = 0
+WHERE fc.deleted_at IS NULL
+GROUP BY fc.id
+ORDER BY fc.id;
+
+-- name: ListGroupUnreadCounts :many
+-- The unread items of the channels of each group and its subgroups, an item of several channels counts once
+WITH RECURSIVE group_tree AS (
+    SELECT fg.id AS group_id, fg.id AS subgroup_id FROM feed_group AS fg
+    UNION
+    SELECT gt.group_id, fg.id FROM feed_group AS fg JOIN group_tree AS gt ON fg.parent_id = gt.subgroup_id
+)
+SELECT gt.group_id, COUNT(DISTINCT fi.id) AS unread
+FROM group_tree AS gt
+LEFT JOIN feed_group_channel AS fgc ON fgc.group_id = gt.subgroup_id
+    AND fgc.channel_id IN (SELECT fc.id FROM feed_channel AS fc WHERE fc.deleted_at IS NULL)
+LEFT JOIN feed_channel_item AS fci ON fci.channel_id = fgc.channel_id
+LEFT JOIN feed_item AS fi ON fi.id = fci.item_id AND fi.read = 0 AND fi.deleted = 0
+GROUP BY gt.group_id
+ORDER BY gt.group_id;
+
+-- Trash Queries
+
+-- name: ListFeedItemTombstones :many
+-- Purged items with any of the GUIDs or canonical links, looked up at once for the whole feed. GUIDs are scoped
+-- to their channel like in ListFeedItemByGuids, while the canonical links match the items purged from any channel
+-- like in ListFeedItemByCanonicalLinks: another channel publishing the link publishes the same item.
+SELECT channel_id, guid, canonical_link
+FROM feed_item_tombstone
+WHERE (channel_id = @channel_id AND guid IN (sqlc.slice(guids))) OR canonical_link IN (sqlc.slice(canonical_links));
+
+-- name: ListPurgeableFeedItemIDs :many
+-- Items moved to the trash before the date
+SELECT id
+FROM feed_item
+WHERE deleted = 1 AND datetime(deleted_at) < datetime(CAST(@before AS TEXT))
+ORDER BY id
+LIMIT @limit;
+
+-- name: ListPurgeableChannelItemIDs :many
+-- Items published only in the channels moved to the trash before the date, they are purged with the channels
+SELECT fi.id
+FROM feed_item AS fi
+WHERE fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                WHERE datetime(fc.deleted_at) < datetime(CAST(@before AS TEXT)))
+  AND fi.id NOT IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                    JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                    WHERE fc.deleted_at IS NULL OR datetime(fc.deleted_at) >= datetime(CAST(@before AS TEXT)))
+ORDER BY fi.id
+LIMIT @limit;
+
+-- name: ListPurgeableFeedChannelIDs :many
+-- Channels moved to the trash before the date
+SELECT id
+FROM feed_channel
+WHERE datetime(deleted_at) < datetime(CAST(@before AS TEXT))
+ORDER BY id;
+
+-- name: DeleteExpiredFeedItemTombstones :execrows
+-- Tombstones of the items purged before the date, the feeds have dropped these items by then
+DELETE FROM feed_item_tombstone
+WHERE datetime(purged) < datetime(CAST(@before AS TEXT));
+
+-- name: CreateFeedItemTombstones :exec
+-- Remembers the purged items in each of their channels
+INSERT INTO feed_item_tombstone (channel_id, guid, canonical_link)
+SELECT fci.channel_id, fi.guid, fi.canonical_link
+FROM feed_item AS fi
+JOIN feed_channel_item AS fci ON fi.id = fci.item_id
+WHERE fi.id IN (sqlc.slice('item_ids'));
+
+-- name: DeleteFeedChannelItemsByItems :exec
+DELETE FROM feed_channel_item
+WHERE item_id IN (sqlc.slice('item_ids'));
+
+-- name: DeleteItemEnclosuresByItems :exec
+DELETE FROM item_enclosure
+WHERE item_id IN (sqlc.slice('item_ids'));
+
+-- name: DeleteFeedItemRevisionsByItems :exec
+DELETE FROM feed_item_revision
+WHERE item_id IN (sqlc.slice('item_ids'));
+
+-- name: DeleteFeedItems :exec
+DELETE FROM feed_item
+WHERE id IN (sqlc.slice('item_ids'));
+
+-- name: RecountStoryClusters :exec
+-- Counts the items of the stories again after items are purged or channels are moved to the trash or restored
+UPDATE story_cluster
+SET item_count = (SELECT COUNT(*) FROM feed_item AS fi
+                  WHERE fi.cluster_id = story_cluster.id AND fi.deleted = 0
+                    AND EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                                WHERE fci.item_id = fi.id AND fc.deleted_at IS NULL))
+WHERE item_count != (SELECT COUNT(*) FROM feed_item AS fi
+                     WHERE fi.cluster_id = story_cluster.id AND fi.deleted = 0
+                       AND EXISTS (SELECT 1 FROM feed_channel_item AS fci JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                                   WHERE fci.item_id = fi.id AND fc.deleted_at IS NULL));
+
+-- name: DeleteFeedChannelItemsByChannels :exec
+DELETE FROM feed_channel_item
+WHERE channel_id IN (sqlc.slice('channel_ids'));
+
+-- name: DeleteFeedChannelLogsByChannels :exec
+DELETE FROM feed_channel_log
+WHERE channel_id IN (sqlc.slice('channel_ids'));
+
+-- name: DeleteFeedChannelLinksByChannels :exec
+DELETE FROM feed_channel_link
+WHERE channel_id IN (sqlc.slice('channel_ids'));
+
+-- name: DeleteFeedGroupChannelsByChannels :exec
+DELETE FROM feed_group_channel
+WHERE channel_id IN (sqlc.slice('channel_ids'));
+
+-- name: DeleteFeedChannelTagsByChannels :exec
+DELETE FROM feed_channel_tag
+WHERE channel_id IN (sqlc.slice('channel_ids'));
+
+-- name: DeleteFeedItemTombstonesByChannels :exec
+DELETE FROM feed_item_tombstone
+WHERE channel_id IN (sqlc.slice('channel_ids'));
+
+-- name: DeleteFeedChannels :exec
+DELETE FROM feed_channel
+WHERE id IN (sqlc.slice('channel_ids'));
+
+-- Search Queries
+
+-- name: SearchFeedItem :many
+-- Items matching the FTS5 query, the best first. Matches in titles rank higher than in authors and in the text,
+-- the description, the content and the extracted article. The snippet is taken from the plain text.
+-- Highlighted terms are marked with the control characters 0x02 and 0x03, so they can be told from the item text.
+WITH RECURSIVE subgroup (id) AS (
+    SELECT fg.id FROM feed_group AS fg WHERE fg.id = sqlc.narg(group_id)
+    UNION
+    SELECT fg.id FROM feed_group AS fg JOIN subgroup AS sg ON fg.parent_id = sg.id
+)
+SELECT fi.id, fi.link, fi.author, fi.published,
+       highlight(item_search, 0, char(2), char(3)) AS title_highlight,
+       snippet(item_search, 1, char(2), char(3), '...', 24) AS snippet,
+       bm25(item_search, 10.0, 1.0, 5.0, 1.0, 0.5, 0.5) AS score
+FROM item_search
+JOIN feed_item AS fi ON fi.id = item_search.rowid
+WHERE item_search MATCH @query
+  AND fi.deleted = 0
+  AND fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                JOIN feed_channel AS fc ON fc.id = fci.channel_id
+                WHERE fc.deleted_at IS NULL)
+  AND (CAST(sqlc.narg(channel_id) AS INTEGER) IS NULL
+       OR fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci WHERE fci.channel_id = sqlc.narg(channel_id)))
+  AND (CAST(sqlc.narg(group_id) AS INTEGER) IS NULL
+       OR fi.id IN (SELECT fci.item_id FROM feed_channel_item AS fci
+                    JOIN feed_group_channel AS fgc ON fgc.channel_id = fci.channel_id
+                    JOIN subgroup AS sg ON fgc.group_id = sg.id))
+  AND (datetime(fi.published) >= datetime(CAST(sqlc.narg(published_from) AS TEXT)) OR sqlc.narg(published_from) IS NULL)
+  AND (datetime(fi.published) < datetime(CAST(sqlc.narg(published_to) AS TEXT)) OR sqlc.narg(published_to) IS NULL)
+ORDER BY score, fi.id
+LIMIT @limit OFFSET @offset;
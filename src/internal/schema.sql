@@ -9,7 +9,30 @@ CREATE TABLE feed_channel (
     published DATETIME NOT NULL DEFAULT (datetime('now')),
     enabled INTEGER NOT NULL DEFAULT (1),
     created DATETIME NOT NULL DEFAULT (datetime('now')),
-    updated DATETIME
+    updated DATETIME,
+    etag TEXT, -- ETag header of the last successful response
+    last_modified TEXT, -- Last-Modified header of the last successful response
+    failure_count INTEGER NOT NULL DEFAULT (0), -- Consecutive failed fetches
+    next_fetch_at DATETIME, -- NULL means the channel is due right away
+    disabled_reason TEXT, -- Why the gatherer disabled the channel
+    update_interval INTEGER, -- Minutes between fetches, NULL means feeds_update_interval
+    adaptive_interval INTEGER NOT NULL DEFAULT (0), -- Learn the interval from the feed's publishing cadence
+    learned_interval INTEGER, -- Minutes between fetches picked by the adaptive mode
+    proxy TEXT, -- Proxy URL for this channel, NULL means http_client.proxy
+    tls_insecure INTEGER NOT NULL DEFAULT (0), -- Skip verification of the TLS certificate
+    original_link TEXT, -- Link the channel was subscribed with, before any permanent redirects
+    moved_to TEXT, -- Target of a permanent redirect that is not yet confirmed
+    moved_count INTEGER NOT NULL DEFAULT (0), -- Consecutive fetches redirected to moved_to
+    gone INTEGER NOT NULL DEFAULT (0), -- The publisher answered 410 Gone
+    site_link TEXT, -- Link to the website, metadata columns are synced from the feed on every fetch
+    language TEXT,
+    image_url TEXT,
+    generator TEXT,
+    last_build_date DATETIME,
+    metadata_locked INTEGER NOT NULL DEFAULT (0), -- Keep the title and description set by the user
+    full_text INTEGER NOT NULL DEFAULT (0), -- Download the page of every new item and extract the article
+    content_selector TEXT, -- CSS selector of the article on the site, NULL means automatic extraction
+    deleted_at DATETIME -- When the channel was moved to the trash, NULL unless it's in the trash
 );
 
 CREATE TABLE feed_item (
@@ -24,7 +47,72 @@ CREATE TABLE feed_item (
     read INTEGER NOT NULL DEFAULT (0),
     deleted INTEGER NOT NULL DEFAULT (0),
     created DATETIME NOT NULL DEFAULT (datetime('now')),
-    updated DATETIME
+    updated DATETIME,
+    content TEXT, -- Full content (content:encoded, Atom content), description keeps the summary
+    source_updated DATETIME, -- When the publisher last updated the item
+    description_raw TEXT, -- description and content as published, they are stored sanitized
+    content_raw TEXT,
+    text TEXT, -- Plain text of the content or description for previews and search
+    extracted_content TEXT, -- Article extracted from the item page, sanitized
+    extraction_status TEXT, -- pending, extracted or failed, NULL if the channel doesn't extract articles
+    extraction_error TEXT,
+    extracted DATETIME, -- When the extraction was last attempted
+    edited INTEGER NOT NULL DEFAULT (0), -- The publisher changed the item after we first saw it
+    canonical_link TEXT, -- Link without tracking parameters or the page's rel=canonical, duplicates share it
+    origin_channel_id INTEGER, -- Channel which first published the item, only its feed updates the item
+    simhash INTEGER, -- SimHash of the normalized title and text, near-duplicates differ in a few bits
+    cluster_id INTEGER REFERENCES story_cluster(id) ON DELETE SET NULL, -- Story the item is a near-duplicate in
+    starred INTEGER NOT NULL DEFAULT (0), -- Marked by the user to keep
+    read_later INTEGER NOT NULL DEFAULT (0), -- Saved by the user to read later
+    deleted_at DATETIME -- When the item was moved to the trash, set along with deleted
+);
+
+CREATE INDEX IF NOT EXISTS feed_item_guid ON feed_item (guid);
+CREATE INDEX IF NOT EXISTS feed_item_canonical_link ON feed_item (canonical_link);
+CREATE INDEX IF NOT EXISTS feed_item_cluster_id ON feed_item (cluster_id);
+CREATE INDEX IF NOT EXISTS feed_item_created ON feed_item (created, id);
+CREATE INDEX IF NOT EXISTS feed_item_published ON feed_item (CAST(COALESCE(published, created) AS TEXT), id);
+CREATE INDEX IF NOT EXISTS feed_item_title ON feed_item (title, id);
+CREATE INDEX IF NOT EXISTS feed_item_deleted_at ON feed_item (deleted_at);
+
+-- Near-duplicate items of different channels covering the same story
+CREATE TABLE IF NOT EXISTS story_cluster (
+    id INTEGER PRIMARY KEY,
+    title TEXT NOT NULL, -- Title of the first item of the story
+    item_count INTEGER NOT NULL DEFAULT (0),
+    created DATETIME NOT NULL DEFAULT (datetime('now')),
+    updated DATETIME NOT NULL DEFAULT (datetime('now')) -- When the last item joined the story
+);
+
+-- Previous versions of items edited by the publisher
+CREATE TABLE IF NOT EXISTS feed_item_revision (
+    id INTEGER PRIMARY KEY,
+    item_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    link TEXT NOT NULL,
+    content TEXT,
+    text TEXT,
+    source_updated DATETIME,
+    valid_from DATETIME NOT NULL, -- When we first saw this version
+    replaced DATETIME NOT NULL DEFAULT (datetime('now')), -- When the next version replaced it
+    FOREIGN KEY (item_id) REFERENCES feed_item(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS feed_item_revision_item_id ON feed_item_revision (item_id);
+
+-- Media attached to items: RSS enclosures, Media RSS content and item images
+CREATE TABLE IF NOT EXISTS item_enclosure (
+    id INTEGER PRIMARY KEY,
+    item_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    mime_type TEXT,
+    medium TEXT, -- audio, video, image or document
+    length INTEGER, -- Size in bytes
+    duration INTEGER, -- Duration in seconds
+    thumbnail TEXT, -- URL of the preview image
+    FOREIGN KEY (item_id) REFERENCES feed_item(id) ON DELETE CASCADE,
+    UNIQUE (item_id, url)
 );
 
 CREATE TABLE feed_channel_item (
@@ -35,6 +123,22 @@ CREATE TABLE feed_channel_item (
     PRIMARY KEY (channel_id, item_id)
 );
 
+CREATE INDEX IF NOT EXISTS feed_channel_item_item_id ON feed_channel_item (item_id);
+
+-- Items purged from the trash. The gatherer doesn't store an item again while its channel still publishes it,
+-- nor another channel under the same canonical link, until the tombstone expires.
+CREATE TABLE IF NOT EXISTS feed_item_tombstone (
+    id INTEGER PRIMARY KEY,
+    channel_id INTEGER NOT NULL,
+    guid TEXT,
+    canonical_link TEXT,
+    purged DATETIME NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (channel_id) REFERENCES feed_channel(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS feed_item_tombstone_channel_id ON feed_item_tombstone (channel_id);
+CREATE INDEX IF NOT EXISTS feed_item_tombstone_canonical_link ON feed_item_tombstone (canonical_link);
+
 CREATE TABLE IF NOT EXISTS feed_group (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
@@ -53,10 +157,53 @@ CREATE TABLE IF NOT EXISTS feed_group_channel (
 CREATE TABLE feed_channel_log (
     id INTEGER PRIMARY KEY,
     channel_id INTEGER NOT NULL,
-    last_update DATETIME DEFAULT (datetime('now')), -- Uses SQLite function for current timestamp
+    started DATETIME, -- When the fetch attempt started
+    last_update DATETIME DEFAULT (datetime('now')), -- When the fetch attempt finished
+    status_code INTEGER, -- HTTP status of the response (200, 304, ...)
+    bytes_received INTEGER NOT NULL DEFAULT (0),
+    error_class TEXT, -- NULL on success, otherwise timeout, dns, tls, network, http, size, parse or database
+    error_message TEXT,
+    items_new INTEGER NOT NULL DEFAULT (0),
+    items_updated INTEGER NOT NULL DEFAULT (0),
+    items_unchanged INTEGER NOT NULL DEFAULT (0),
+    url TEXT, -- Requested URL
+    effective_url TEXT, -- URL of the final response after redirects
+    redirects TEXT, -- Redirect chain, e.g. "301 http://a/feed, 302 http://b/feed"
+    items_merged INTEGER NOT NULL DEFAULT (0), -- Items found in other channels and shared with this one
+    merges TEXT, -- Merged items, e.g. "#12 http://a/post, #15 http://b/post"
+    items_failed INTEGER NOT NULL DEFAULT (0), -- Items skipped because storing them failed
+    failures TEXT, -- Errors of the skipped items, e.g. "guid-1: constraint failed; guid-2: ..."
+    run_id INTEGER REFERENCES fetch_run(id) ON DELETE SET NULL, -- Fetch run of the scheduler, NULL for other fetches
+    duration_ms INTEGER,
     FOREIGN KEY (channel_id) REFERENCES feed_channel(id)
 );
 
+CREATE INDEX IF NOT EXISTS feed_channel_log_run_id ON feed_channel_log (run_id);
+
+-- A fetch run lasts from the moment the scheduler starts fetching while idle until all fetches are done
+CREATE TABLE IF NOT EXISTS fetch_run (
+    id INTEGER PRIMARY KEY,
+    started DATETIME NOT NULL,
+    finished DATETIME, -- NULL while the run is in progress
+    succeeded INTEGER NOT NULL DEFAULT (0),
+    failed INTEGER NOT NULL DEFAULT (0),
+    skipped INTEGER NOT NULL DEFAULT (0), -- Channels deleted before their fetch started
+    fetch_time_ms INTEGER NOT NULL DEFAULT (0), -- Total time of the fetches, they overlap with parallel workers
+    slowest_channel_id INTEGER,
+    slowest_fetch_ms INTEGER NOT NULL DEFAULT (0)
+);
+
+-- History of channel links changed by permanent redirects
+CREATE TABLE IF NOT EXISTS feed_channel_link (
+    id INTEGER PRIMARY KEY,
+    channel_id INTEGER NOT NULL,
+    old_link TEXT NOT NULL,
+    new_link TEXT NOT NULL,
+    redirects TEXT,
+    changed DATETIME NOT NULL DEFAULT (datetime('now')),
+    FOREIGN KEY (channel_id) REFERENCES feed_channel(id) ON DELETE CASCADE
+);
+
 CREATE TABLE IF NOT EXISTS tag (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
@@ -70,3 +217,12 @@ CREATE TABLE IF NOT EXISTS feed_channel_tag (
     FOREIGN KEY (tag_id) REFERENCES tag(id) ON DELETE CASCADE,
     PRIMARY KEY (channel_id, tag_id)
 );
+
+-- Full-text index of the plain text of the items, the markup is left out. It's only created in builds with
+-- the sqlite_fts5 tag, by the migrations in db/search_migrations. The item_search column stands for the hidden
+-- column FTS5 matches the whole row with, sqlc doesn't know it.
+CREATE VIRTUAL TABLE IF NOT EXISTS item_search USING fts5(
+    title, text, author, description, content, extracted_content, item_search UNINDEXED,
+    content = 'feed_item', content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3'
+);
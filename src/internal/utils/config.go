@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -14,7 +15,8 @@ import (
 
 type Config struct {
 	Database struct {
-		Path string `yaml:"path"`
+		Path        string        `yaml:"path"`
+		BusyTimeout time.Duration `yaml:"busy_timeout"` // How long a write waits for the database lock
 	} `yaml:"database"`
 	FeedsUpdateInterval time.Duration `yaml:"feeds_update_interval"`
 	Logging             struct {
@@ -24,15 +26,83 @@ type Config struct {
 	Server struct {
 		Port string `yaml:"port"`
 	} `yaml:"server"`
+	Retry struct {
+		MaxFailures int           `yaml:"max_failures"` // Consecutive failures before the channel is disabled
+		BackoffBase time.Duration `yaml:"backoff_base"` // Delay after the first failure, doubled after each next one
+		BackoffMax  time.Duration `yaml:"backoff_max"`
+	} `yaml:"retry"`
+	AdaptiveInterval struct {
+		Min time.Duration `yaml:"min"` // Busiest feeds are never fetched more often than this
+		Max time.Duration `yaml:"max"` // Dormant feeds are still fetched at least this often
+	} `yaml:"adaptive_interval"`
+	Scheduler struct {
+		Workers         int           `yaml:"workers"`          // Number of feeds fetched in parallel
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // In-flight fetches are aborted after this timeout
+	} `yaml:"scheduler"`
+	Politeness struct {
+		HostConcurrency int                   `yaml:"host_concurrency"` // Parallel requests to the same host
+		HostDelay       time.Duration         `yaml:"host_delay"`       // Minimum delay between requests to the same host
+		Hosts           map[string]HostLimits `yaml:"hosts"`            // Overrides for particular hosts
+	} `yaml:"politeness"`
+	HTTPClient struct {
+		UserAgent      string        `yaml:"user_agent"`
+		Proxy          string        `yaml:"proxy"`           // HTTP or SOCKS proxy, the environment proxy is used if empty
+		CABundle       string        `yaml:"ca_bundle"`       // PEM file with certificates trusted in addition to the system ones
+		ConnectTimeout time.Duration `yaml:"connect_timeout"` // Timeout for the connection and the TLS handshake
+		ReadTimeout    time.Duration `yaml:"read_timeout"`    // Timeout for receiving the response once connected
+		MaxBodySize    int64         `yaml:"max_body_size"`   // Maximum size of the decoded response body in bytes
+	} `yaml:"http_client"`
+	Redirects struct {
+		Max            int `yaml:"max"`             // Redirects followed in a single fetch
+		PermanentAfter int `yaml:"permanent_after"` // Fetches redirected permanently to the same URL before the channel link is changed
+	} `yaml:"redirects"`
+	FullText struct {
+		MaxItems int `yaml:"max_items"` // Articles extracted per fetch of a channel, the rest wait for the next fetch
+	} `yaml:"full_text"`
+	Clustering struct {
+		Window      time.Duration `yaml:"window"`       // New items are compared with the items collected during this period
+		MaxDistance int           `yaml:"max_distance"` // Most differing SimHash bits of near-duplicates, -1 disables clustering
+	} `yaml:"clustering"`
+	Trash struct {
+		Retention          time.Duration `yaml:"retention"`           // Deleted items and channels are purged after this period
+		TombstoneRetention time.Duration `yaml:"tombstone_retention"` // Purged items are not collected again during this period
+	} `yaml:"trash"`
+}
+
+// HostLimits overrides the global politeness settings for a host, zero values keep the global ones
+type HostLimits struct {
+	Concurrency int           `yaml:"concurrency"`
+	Delay       time.Duration `yaml:"delay"`
 }
 
 // Default config values
 const (
 	databasePath        = "db/feeds.db"
+	busyTimeout         = "5s"
 	feedsUpdateInterval = "40m"
 	port                = "8080"
 	infoLog             = "info.log"
 	errorLog            = "error.log"
+	retryMaxFailures    = 10
+	retryBackoffBase    = "5m"
+	retryBackoffMax     = "24h"
+	adaptiveIntervalMin = "5m"
+	adaptiveIntervalMax = "24h"
+	shutdownTimeout     = "30s"
+	schedulerWorkers    = 10
+	hostConcurrency     = 2
+	hostDelay           = "1s"
+	userAgent           = "FeedsCollector/1.0"
+	connectTimeout      = "10s"
+	readTimeout         = "30s"
+	maxBodySize         = 10 << 20
+	maxRedirects        = 10
+	permanentAfter      = 3
+	fullTextMaxItems    = 10
+	clusteringWindow    = "72h"
+	clusteringDistance  = 10
+	trashRetention      = "720h"
+	tombstoneRetention  = "2160h"
 )
 
 func ValidateConfig(config *Config) error {
@@ -47,9 +117,168 @@ func ValidateConfig(config *Config) error {
 	if port < 1 || port > 65535 {
 		return fmt.Errorf("server.port must be between 1 and 65535")
 	}
+
+	if config.Database.BusyTimeout == 0 {
+		config.Database.BusyTimeout, _ = time.ParseDuration(busyTimeout)
+	}
+	if config.Database.BusyTimeout < 0 {
+		return fmt.Errorf("database.busy_timeout must be positive")
+	}
+
+	if config.FeedsUpdateInterval == 0 {
+		config.FeedsUpdateInterval, _ = time.ParseDuration(feedsUpdateInterval)
+	}
+	if config.FeedsUpdateInterval < time.Minute {
+		return fmt.Errorf("feeds_update_interval must be at least 1m")
+	}
+
+	if config.Retry.MaxFailures == 0 {
+		config.Retry.MaxFailures = retryMaxFailures
+	}
+	if config.Retry.MaxFailures < 0 {
+		return fmt.Errorf("retry.max_failures must be positive")
+	}
+	if config.Retry.BackoffBase == 0 {
+		config.Retry.BackoffBase, _ = time.ParseDuration(retryBackoffBase)
+	}
+	if config.Retry.BackoffMax == 0 {
+		config.Retry.BackoffMax, _ = time.ParseDuration(retryBackoffMax)
+	}
+	if config.Retry.BackoffBase < 0 || config.Retry.BackoffMax < config.Retry.BackoffBase {
+		return fmt.Errorf("retry.backoff_max must not be less than retry.backoff_base")
+	}
+
+	if config.AdaptiveInterval.Min == 0 {
+		config.AdaptiveInterval.Min, _ = time.ParseDuration(adaptiveIntervalMin)
+	}
+	if config.AdaptiveInterval.Max == 0 {
+		config.AdaptiveInterval.Max, _ = time.ParseDuration(adaptiveIntervalMax)
+	}
+	if config.AdaptiveInterval.Min < time.Minute || config.AdaptiveInterval.Max < config.AdaptiveInterval.Min {
+		return fmt.Errorf("adaptive_interval.min must be at least 1m and not greater than adaptive_interval.max")
+	}
+
+	if config.Scheduler.ShutdownTimeout == 0 {
+		config.Scheduler.ShutdownTimeout, _ = time.ParseDuration(shutdownTimeout)
+	}
+	if config.Scheduler.ShutdownTimeout < 0 {
+		return fmt.Errorf("scheduler.shutdown_timeout must be positive")
+	}
+	if config.Scheduler.Workers == 0 {
+		config.Scheduler.Workers = schedulerWorkers
+	}
+	if config.Scheduler.Workers < 0 {
+		return fmt.Errorf("scheduler.workers must be positive")
+	}
+
+	if config.FullText.MaxItems == 0 {
+		config.FullText.MaxItems = fullTextMaxItems
+	}
+	if config.FullText.MaxItems < 0 {
+		return fmt.Errorf("full_text.max_items must be positive")
+	}
+
+	if config.Clustering.Window == 0 {
+		config.Clustering.Window, _ = time.ParseDuration(clusteringWindow)
+	}
+	if config.Clustering.MaxDistance == 0 {
+		config.Clustering.MaxDistance = clusteringDistance
+	}
+	if config.Clustering.Window < 0 || config.Clustering.MaxDistance < -1 || config.Clustering.MaxDistance > 32 {
+		return fmt.Errorf("clustering.window must be positive and clustering.max_distance between -1 and 32")
+	}
+
+	if config.Trash.Retention == 0 {
+		config.Trash.Retention, _ = time.ParseDuration(trashRetention)
+	}
+	if config.Trash.Retention < 0 {
+		return fmt.Errorf("trash.retention must be positive")
+	}
+	if config.Trash.TombstoneRetention == 0 {
+		config.Trash.TombstoneRetention, _ = time.ParseDuration(tombstoneRetention)
+	}
+	if config.Trash.TombstoneRetention < 0 {
+		return fmt.Errorf("trash.tombstone_retention must be positive")
+	}
+
+	if err := validatePoliteness(config); err != nil {
+		return err
+	}
+	return validateHTTPClient(config)
+}
+
+func validatePoliteness(config *Config) error {
+	if config.Politeness.HostConcurrency == 0 {
+		config.Politeness.HostConcurrency = hostConcurrency
+	}
+	if config.Politeness.HostDelay == 0 {
+		config.Politeness.HostDelay, _ = time.ParseDuration(hostDelay)
+	}
+	if config.Politeness.HostConcurrency < 0 || config.Politeness.HostDelay < 0 {
+		return fmt.Errorf("politeness.host_concurrency and politeness.host_delay must be positive")
+	}
+	for host, limits := range config.Politeness.Hosts {
+		if limits.Concurrency < 0 || limits.Delay < 0 {
+			return fmt.Errorf("politeness.hosts.%s: concurrency and delay must be positive", host)
+		}
+	}
+	return nil
+}
+
+func validateHTTPClient(config *Config) error {
+	if config.HTTPClient.UserAgent == "" {
+		config.HTTPClient.UserAgent = userAgent
+	}
+	if config.HTTPClient.Proxy != "" {
+		if _, err := ParseProxyURL(config.HTTPClient.Proxy); err != nil {
+			return fmt.Errorf("http_client.proxy: %v", err)
+		}
+	}
+	if config.HTTPClient.ConnectTimeout == 0 {
+		config.HTTPClient.ConnectTimeout, _ = time.ParseDuration(connectTimeout)
+	}
+	if config.HTTPClient.ReadTimeout == 0 {
+		config.HTTPClient.ReadTimeout, _ = time.ParseDuration(readTimeout)
+	}
+	if config.HTTPClient.ConnectTimeout < 0 || config.HTTPClient.ReadTimeout < 0 {
+		return fmt.Errorf("http_client.connect_timeout and http_client.read_timeout must be positive")
+	}
+	if config.HTTPClient.MaxBodySize == 0 {
+		config.HTTPClient.MaxBodySize = maxBodySize
+	}
+	if config.HTTPClient.MaxBodySize < 0 {
+		return fmt.Errorf("http_client.max_body_size must be positive")
+	}
+
+	if config.Redirects.Max == 0 {
+		config.Redirects.Max = maxRedirects
+	}
+	if config.Redirects.PermanentAfter == 0 {
+		config.Redirects.PermanentAfter = permanentAfter
+	}
+	if config.Redirects.Max < 0 || config.Redirects.PermanentAfter < 0 {
+		return fmt.Errorf("redirects.max and redirects.permanent_after must be positive")
+	}
 	return nil
 }
 
+// ParseProxyURL checks that the proxy URL has a scheme supported by the HTTP client
+func ParseProxyURL(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, use http, https, socks5 or socks5h", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", proxy)
+	}
+	return proxyURL, nil
+}
+
 func ReadConfig(configPath string) (*Config, error) {
 	configFile, err := os.Open(filepath.Clean(configPath))
 	if err != nil {
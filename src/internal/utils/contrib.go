@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"unicode/utf8"
 )
 
+// MaxChannelTitle is the longest channel title accepted by the validate tag of feed_channel.title
+const MaxChannelTitle = 20
+
 func CompareFeedChannels(channel1, channel2 *models.FeedChannel, variables []string) (bool, string, error) {
 	if channel1 == nil || channel2 == nil {
 		return false, "", errors.New("one or both channels are nil")
@@ -30,3 +34,11 @@ func CompareFeedChannels(channel1, channel2 *models.FeedChannel, variables []str
 
 	return true, "", nil
 }
+
+// Truncate shortens the string to n runes
+func Truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
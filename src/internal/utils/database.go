@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// searchMigrationsTable holds the version of the full-text index, see MigrateSearch
+const searchMigrationsTable = "search_schema_migrations"
+
+// OpenDatabase opens the SQLite database for concurrent use: readers don't block the writer in WAL mode,
+// and a connection waiting for a lock retries for busyTimeout instead of failing with "database is locked".
+// Transactions take the write lock when they begin, so they never fail to upgrade a read lock halfway.
+func OpenDatabase(path string, busyTimeout time.Duration) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_busy_timeout", fmt.Sprint(busyTimeout.Milliseconds()))
+	params.Set("_txlock", "immediate")
+	db, err := sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	// The pragmas are applied to every new connection, so a broken database is reported right away
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
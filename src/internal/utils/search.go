@@ -0,0 +1,19 @@
+//go:build !sqlite_fts5
+
+package utils
+
+import "database/sql"
+
+// SearchEnabled tells whether SQLite is built with FTS5, which the item search needs
+const SearchEnabled = false
+
+// MigrateSearch drops the triggers of a full-text index created by a build with FTS5, since storing items
+// would fail on them without FTS5. The index is rebuilt from scratch when a build enables the search again.
+func MigrateSearch(db *sql.DB, _ string) error {
+	_, err := db.Exec(`
+DROP TRIGGER IF EXISTS item_search_insert;
+DROP TRIGGER IF EXISTS item_search_delete;
+DROP TRIGGER IF EXISTS item_search_update;
+DROP TABLE IF EXISTS ` + searchMigrationsTable)
+	return err
+}
@@ -0,0 +1,32 @@
+//go:build sqlite_fts5
+
+package utils
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+)
+
+// SearchEnabled tells whether SQLite is built with FTS5, which the item search needs
+const SearchEnabled = true
+
+// MigrateSearch creates or updates the full-text index of the items with the migrations at sourceURL.
+// They are versioned apart from the schema migrations, so the index is built whenever a build enables the search.
+func MigrateSearch(db *sql.DB, sourceURL string) error {
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{MigrationsTable: searchMigrationsTable})
+	if err != nil {
+		return err
+	}
+	m, err := migrate.NewWithDatabaseInstance(sourceURL, "sqlite3", driver)
+	if err != nil {
+		return err
+	}
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}